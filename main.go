@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/algorand/msgpackdiff/msgpackdiff"
 )
@@ -11,10 +16,90 @@ import (
 var brief = flag.Bool("brief", false, "Disable comparison report.")
 var ignoreEmpty = flag.Bool("ignore-empty", false, "Treat missing fields as empty objects for comparison.")
 var ignoreOrder = flag.Bool("ignore-order", false, "Ignore ordering of fields for comparison.")
-var flexibleTypes = flag.Bool("flexible-types", false, "Compare all numerical values regardless of their type. May be inaccurate.")
+var flexibleTypes = flag.Bool("flexible-types", false, "Compare all numerical values regardless of their type. Values of different types are equal if they are exactly the same.")
+var strictTimestamps = flag.Bool("strict-timestamps", false, "Treat equal timestamps encoded with different formats as different.")
+var strictEncoding = flag.Bool("strict-encoding", false, "Treat equal values encoded with different formats as different.")
+var stream = flag.Bool("stream", false, "Read the objects from files incrementally and compare their top-level objects by position.")
+var base64Binary = flag.Bool("base64-binary", false, "Treat strings as equal to binary data if they are its base64 encoding, as in JSON.")
+var binaryStrings = flag.Bool("binary-strings", false, "Treat strings as equal to binary data with the same bytes.")
+var format = flag.String("format", "auto", "The format of the objects: auto, msgpack, json or cbor. With auto, files that end in .json or .cbor are JSON or CBOR.")
+var framing = flag.String("framing", "raw", "How the top-level objects are framed: raw, length or rpc. With rpc, msgpack-RPC messages are aligned by their msgid.")
+var prefixWidth = flag.Int("prefix-width", msgpackdiff.DefaultPrefixWidth, "The number of bytes in each length prefix with --framing length: 1, 2, 4 or 8.")
+var littleEndian = flag.Bool("little-endian", false, "Read length prefixes as little-endian instead of big-endian.")
+var alignBy = flag.String("align-by", "", "Align the top-level objects by the value at this path, like txn.note, or by their Algorand transaction ID with @txid, instead of by position.")
+var arrayKeys arrayKeyFlags
+var unorderedArrays = flag.Bool("unordered-arrays", false, "Compare every array as a multiset, ignoring the order of its elements.")
+var unordered pathFlags
+var ignore pathFlags
+var absTolerance = flag.Float64("abs-tolerance", 0, "Treat floating-point numbers as equal if they differ by at most this much.")
+var relTolerance = flag.Float64("rel-tolerance", 0, "Treat floating-point numbers as equal if they differ by at most this fraction of the larger one.")
+var ulpTolerance = flag.Uint64("ulp-tolerance", 0, "Treat floating-point numbers as equal if they are at most this many units in the last place apart.")
+var floatEquality = flag.String("float-equality", "ieee", "How floating-point numbers are compared: ieee, nan or bitwise. With nan, NaNs are equal to each other. With bitwise, -0 is not equal to 0.")
+var rules = flag.String("rules", "", "A JSON file of rules for comparing the objects at certain paths. See the README.")
+var offsets = flag.Bool("offsets", false, "Annotate each difference in reports with the offset of its object in the input.")
+var maxDepth = flag.Int("max-depth", msgpackdiff.DefaultMaxDepth, "The maximum number of containers that can be nested inside each other.")
+var maxContainerLength = flag.Int("max-container-length", msgpackdiff.DefaultMaxContainerLength, "The maximum number of elements in an array or entries in a map.")
 var context = flag.Int("context", 3, "The number of nearby fields to show in difference reports.")
+var input = addInputFlags(flag.CommandLine)
+
+func init() {
+	flag.Var(&arrayKeys, "array-key", "Match the elements of the arrays at a path by a key in each element, like accounts[*]=addr. May be repeated.")
+	flag.Var(&unordered, "unordered", "Compare the arrays at a path as multisets, like txn.signers or accounts[*].assets. May be repeated.")
+	flag.Var(&ignore, "ignore", "Leave the fields at a path out of the comparison, like txn.fv, **.sig or blocks[*].ts. May be repeated.")
+}
+
+// pathFlags are the values of a repeatable flag of paths.
+type pathFlags []msgpackdiff.Path
+
+func (f *pathFlags) String() string {
+	paths := make([]string, len(*f))
+	for i, path := range *f {
+		paths[i] = path.String()
+	}
+	return strings.Join(paths, ", ")
+}
+
+func (f *pathFlags) Set(value string) error {
+	path, err := msgpackdiff.ParsePath(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, path)
+	return nil
+}
+
+// arrayKeyFlags are the values of the repeatable --array-key flag.
+type arrayKeyFlags []msgpackdiff.ArrayKey
+
+func (f *arrayKeyFlags) String() string {
+	keys := make([]string, len(*f))
+	for i, key := range *f {
+		keys[i] = key.String()
+	}
+	return strings.Join(keys, ", ")
+}
+
+func (f *arrayKeyFlags) Set(value string) error {
+	key, err := msgpackdiff.ParseArrayKey(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, key)
+	return nil
+}
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "canonical":
+			checkCanonical(os.Args[2:])
+			return
+		case "canonicalize":
+			canonicalize(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 	args := flag.Args()
 
@@ -23,38 +108,141 @@ func main() {
 		os.Exit(2)
 	}
 
+	if args[0] == "-" && args[1] == "-" {
+		fmt.Fprintln(os.Stderr, "Only one object can be read from stdin")
+		os.Exit(2)
+	}
+
 	if *context < 0 {
 		fmt.Fprintln(os.Stderr, "Context must not be negative.")
 		os.Exit(2)
 	}
 
-	binA, err := msgpackdiff.GetBinary(args[0])
+	if *maxDepth <= 0 || *maxContainerLength <= 0 {
+		fmt.Fprintln(os.Stderr, "Parsing limits must be positive.")
+		os.Exit(2)
+	}
+
+	tolerance := msgpackdiff.Tolerance{
+		Absolute: *absTolerance,
+		Relative: *relTolerance,
+		ULPs:     *ulpTolerance,
+	}
+
+	options := msgpackdiff.CompareOptions{
+		Brief:            *brief,
+		IgnoreEmpty:      *ignoreEmpty,
+		IgnoreOrder:      *ignoreOrder,
+		FlexibleTypes:    *flexibleTypes,
+		StrictTimestamps: *strictTimestamps,
+		StrictEncoding:   *strictEncoding,
+		Base64Binary:     *base64Binary,
+		BinaryStrings:    *binaryStrings,
+		ArrayKeys:        arrayKeys,
+		UnorderedArrays:  *unorderedArrays,
+		UnorderedPaths:   unordered,
+		IgnorePaths:      ignore,
+		Tolerance:        tolerance,
+		ShowOffsets:      *offsets,
+		ParseOptions: msgpackdiff.ParseOptions{
+			MaxDepth:           *maxDepth,
+			MaxContainerLength: *maxContainerLength,
+		},
+	}
+
+	if !(*absTolerance >= 0) || !(*relTolerance >= 0) {
+		fmt.Fprintln(os.Stderr, "Tolerances must not be negative.")
+		os.Exit(2)
+	}
+
+	var err error
+	options.FloatEquality, err = msgpackdiff.ParseFloatEquality(*floatEquality)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to extract first object: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *rules != "" {
+		options.Rules, err = msgpackdiff.LoadRules(*rules)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load rules: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if *format != "auto" && *format != "msgpack" && *format != "json" && *format != "cbor" {
+		fmt.Fprintf(os.Stderr, "Unknown format %q\n", *format)
 		os.Exit(2)
 	}
 
-	binB, err := msgpackdiff.GetBinary(args[1])
+	frameOptions := msgpackdiff.FrameOptions{
+		PrefixWidth:  *prefixWidth,
+		LittleEndian: *littleEndian,
+	}
+	frameOptions.Framing, err = msgpackdiff.ParseFraming(*framing)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to extract second object: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
 
-	options := msgpackdiff.CompareOptions{
-		Brief:         *brief,
-		IgnoreEmpty:   *ignoreEmpty,
-		IgnoreOrder:   *ignoreOrder,
-		FlexibleTypes: *flexibleTypes,
+	if *prefixWidth != 1 && *prefixWidth != 2 && *prefixWidth != 4 && *prefixWidth != 8 {
+		fmt.Fprintln(os.Stderr, "Prefix width must be 1, 2, 4 or 8.")
+		os.Exit(2)
 	}
 
-	result, err := msgpackdiff.Compare(binA, binB, options)
+	if frameOptions.Framing != msgpackdiff.FramingRaw {
+		if *stream {
+			fmt.Fprintln(os.Stderr, "Framed objects cannot be streamed")
+			os.Exit(2)
+		}
+		if objectFormat(args[0]) != "msgpack" || objectFormat(args[1]) != "msgpack" {
+			fmt.Fprintln(os.Stderr, "Only MessagePack objects can be framed")
+			os.Exit(2)
+		}
+	}
+
+	var alignKey msgpackdiff.AlignKey
+	if *alignBy != "" {
+		alignKey, err = msgpackdiff.ParseAlignKey(*alignBy)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if *stream || frameOptions.Framing == msgpackdiff.FramingRPC {
+			fmt.Fprintln(os.Stderr, "Objects cannot be aligned by a key with --stream or --framing rpc")
+			os.Exit(2)
+		}
+	}
+
+	if *stream {
+		if objectFormat(args[0]) != "msgpack" || objectFormat(args[1]) != "msgpack" {
+			fmt.Fprintln(os.Stderr, "Only MessagePack objects can be streamed")
+			os.Exit(2)
+		}
+		compareStreams(args[0], args[1], options)
+		return
+	}
+
+	objectsA := readObjects(args[0], "first object", frameOptions, options.ParseOptions)
+	objectsB := readObjects(args[1], "second object", frameOptions, options.ParseOptions)
 
+	var result msgpackdiff.CompareResult
+	switch {
+	case frameOptions.Framing == msgpackdiff.FramingRPC:
+		result, err = msgpackdiff.CompareRPC(objectsA, objectsB, options)
+	case alignKey != nil:
+		result, err = msgpackdiff.CompareAligned(objectsA, objectsB, alignKey, options)
+	default:
+		result = msgpackdiff.CompareParsed(objectsA, objectsB, options)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "An error occurred: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
 
 	result.PrintReport(os.Stdout, *context)
+	printIgnored(result.Reporter.Ignored)
+	printBinaryStrings(result.Reporter.BinaryStrings)
 
 	if !result.Equal {
 		fmt.Println("Objects are not equal")
@@ -63,3 +251,371 @@ func main() {
 
 	fmt.Println("Objects are equal")
 }
+
+// printIgnored prints how many fields were left out of the comparison by --ignore or the rules.
+func printIgnored(count int) {
+	if (len(ignore) == 0 && count == 0) || *brief {
+		return
+	}
+	s := "s"
+	if count == 1 {
+		s = ""
+	}
+	fmt.Printf("%d ignored field%s\n", count, s)
+}
+
+// printBinaryStrings prints how many strings were only equal to binary data because of
+// --binary-strings, since their types still differ.
+func printBinaryStrings(count int) {
+	if count == 0 || *brief {
+		return
+	}
+	s := "s"
+	if count == 1 {
+		s = ""
+	}
+	fmt.Printf("%d string%s equal to binary data with the same bytes\n", count, s)
+}
+
+// objectFormat returns the format that the object should be parsed with: msgpack, json or cbor.
+func objectFormat(object string) string {
+	if *format != "auto" {
+		return *format
+	}
+	path := strings.TrimPrefix(object, "file:")
+	for _, extension := range []string{".gz", ".bz2", ".zlib"} {
+		path = strings.TrimSuffix(path, extension)
+	}
+	switch {
+	case strings.HasSuffix(path, ".json"):
+		return "json"
+	case strings.HasSuffix(path, ".cbor"):
+		return "cbor"
+	}
+	return "msgpack"
+}
+
+// readObjects reads and parses the top-level objects given by the argument object, exiting if they
+// cannot be read or parsed. The name describes the object in messages. MessagePack objects are
+// framed as given by framing.
+func readObjects(object string, name string, framing msgpackdiff.FrameOptions, options msgpackdiff.ParseOptions) []msgpackdiff.MsgpObject {
+	switch objectFormat(object) {
+	case "msgpack":
+		objects, err := msgpackdiff.ParseFrames(input.read(object, name), framing, options)
+		if err != nil {
+			// the error describes where the object failed to parse
+			fmt.Fprintf(os.Stderr, "Failed to parse %s: %v\n", name, err)
+			os.Exit(2)
+		}
+		return objects
+	case "cbor":
+		objects, err := msgpackdiff.ParseCBOR(input.read(object, name), options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse %s as CBOR: %v\n", name, err)
+			os.Exit(2)
+		}
+		return objects
+	}
+
+	// JSON is text, but it should not be mistaken for hex or base64
+	encoding := input.encoding()
+	if encoding == msgpackdiff.InputAuto {
+		encoding = msgpackdiff.InputBinary
+	}
+	objects, err := msgpackdiff.ParseJSON(input.readWithEncoding(object, name, encoding), options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse %s as JSON: %v\n", name, err)
+		os.Exit(2)
+	}
+	return objects
+}
+
+func compareStreams(objectA string, objectB string, options msgpackdiff.CompareOptions) {
+	readerA, err := openStream(objectA, "first object")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open first object: %v\n", err)
+		os.Exit(2)
+	}
+	defer readerA.Close()
+
+	readerB, err := openStream(objectB, "second object")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open second object: %v\n", err)
+		os.Exit(2)
+	}
+	defer readerB.Close()
+
+	comparison := msgpackdiff.CompareReaders(readerA, readerB, options)
+
+	ignored := 0
+	binaryStringCount := 0
+	skipped := 0
+	printSkipped := func() {
+		if skipped != 0 && !*brief {
+			s := "s"
+			if skipped == 1 {
+				s = ""
+			}
+			fmt.Printf(" ... %d skipped object%s\n", skipped, s)
+		}
+		skipped = 0
+	}
+
+	for {
+		result, err := comparison.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "An error occurred in object %d: %v\n", comparison.Index, err)
+			os.Exit(2)
+		}
+		ignored += result.Reporter.Ignored
+		binaryStringCount += result.Reporter.BinaryStrings
+
+		if result.Equal {
+			skipped++
+			continue
+		}
+
+		if *brief {
+			break
+		}
+
+		printSkipped()
+		result.PrintReport(os.Stdout, *context)
+	}
+	printSkipped()
+	printIgnored(ignored)
+	printBinaryStrings(binaryStringCount)
+
+	if !comparison.Equal {
+		fmt.Println("Objects are not equal")
+		os.Exit(1)
+	}
+
+	fmt.Println("Objects are equal")
+}
+
+// openStream opens the object given by the argument object for streaming. Files and stdin that hold
+// binary MessagePack are read incrementally and decompressed as given by the flags. Objects that
+// are decoded from a text encoding, because of a prefix such as "b64:", an --input-format other
+// than auto or binary, or an argument that is not a file, are read in full before they are
+// streamed. The name describes the object in messages.
+func openStream(object string, name string) (io.ReadCloser, error) {
+	encoding := input.encoding()
+	binary := encoding == msgpackdiff.InputAuto || encoding == msgpackdiff.InputBinary
+
+	var path string
+	switch {
+	case binary && object == "-":
+	case binary && strings.HasPrefix(object, "file:"):
+		path = object[len("file:"):]
+	case encoding == msgpackdiff.InputBinary || (encoding == msgpackdiff.InputAuto && msgpackdiff.FileExists(object)):
+		path = object
+	default:
+		bin, decoding, err := msgpackdiff.ReadInput(object, msgpackdiff.InputOptions{
+			Encoding:    encoding,
+			Compression: input.compression(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if *input.verbose {
+			fmt.Fprintf(os.Stderr, "Read %s from %s\n", name, decoding)
+		}
+		return ioutil.NopCloser(bytes.NewReader(bin)), nil
+	}
+
+	file := os.Stdin
+	if path != "" {
+		var err error
+		file, err = os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reader, compression, err := msgpackdiff.Decompress(file, input.compression())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if *input.verbose {
+		source := "stdin"
+		if path != "" {
+			source = "file " + path
+		}
+		fmt.Fprintf(os.Stderr, "Streaming %s from %s decompressed with %s\n", name, source, compression)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}, nil
+}
+
+// inputFlags are the flags that control how objects are read.
+type inputFlags struct {
+	format     *string
+	decompress *string
+	verbose    *bool
+}
+
+// addInputFlags adds the flags that control how objects are read to flags.
+func addInputFlags(flags *flag.FlagSet) inputFlags {
+	return inputFlags{
+		format:     flags.String("input-format", "auto", "The encoding of objects without a prefix: auto, b64, b64url, hex or binary."),
+		decompress: flags.String("decompress", "auto", "The compression of files and stdin: auto, none, gzip, zlib or bzip2."),
+		verbose:    flags.Bool("verbose", false, "Print how each object was read."),
+	}
+}
+
+// read reads the object given by the argument object, exiting if it cannot be read. The name
+// describes the object in messages.
+func (f inputFlags) read(object string, name string) []byte {
+	return f.readWithEncoding(object, name, f.encoding())
+}
+
+// readWithEncoding is like read, but decodes the object with encoding instead of the one given by
+// the flags.
+func (f inputFlags) readWithEncoding(object string, name string, encoding msgpackdiff.InputEncoding) []byte {
+	bin, decoding, err := msgpackdiff.ReadInput(object, msgpackdiff.InputOptions{
+		Encoding:    encoding,
+		Compression: f.compression(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to extract %s: %v\n", name, err)
+		os.Exit(2)
+	}
+
+	if *f.verbose {
+		fmt.Fprintf(os.Stderr, "Read %s from %s\n", name, decoding)
+	}
+
+	return bin
+}
+
+// encoding returns the input encoding given by the flags, exiting if it is unknown.
+func (f inputFlags) encoding() msgpackdiff.InputEncoding {
+	encoding, err := msgpackdiff.ParseInputEncoding(*f.format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return encoding
+}
+
+// compression returns the compression given by the flags, exiting if it is unknown.
+func (f inputFlags) compression() msgpackdiff.Compression {
+	compression, err := msgpackdiff.ParseCompression(*f.decompress)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return compression
+}
+
+// addParseFlags adds the flags that limit parsing to a subcommand's flags. The returned function
+// returns the limits once the flags are parsed.
+func addParseFlags(flags *flag.FlagSet) func() msgpackdiff.ParseOptions {
+	maxDepth := flags.Int("max-depth", msgpackdiff.DefaultMaxDepth, "The maximum number of containers that can be nested inside each other.")
+	maxContainerLength := flags.Int("max-container-length", msgpackdiff.DefaultMaxContainerLength, "The maximum number of elements in an array or entries in a map.")
+	return func() msgpackdiff.ParseOptions {
+		if *maxDepth <= 0 || *maxContainerLength <= 0 {
+			fmt.Fprintln(os.Stderr, "Parsing limits must be positive.")
+			os.Exit(2)
+		}
+		return msgpackdiff.ParseOptions{
+			MaxDepth:           *maxDepth,
+			MaxContainerLength: *maxContainerLength,
+		}
+	}
+}
+
+// parseInterspersed parses the flags of a subcommand, allowing them to come after the object like
+// "canonicalize [A] -o out". The object arguments are returned.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	flags.Parse(args)
+	objects := []string{}
+	for flags.NArg() != 0 {
+		objects = append(objects, flags.Arg(0))
+		flags.Parse(flags.Args()[1:])
+	}
+	return objects
+}
+
+func checkCanonical(args []string) {
+	flags := flag.NewFlagSet("canonical", flag.ExitOnError)
+	input := addInputFlags(flags)
+	parseOptions := addParseFlags(flags)
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Must specify exactly one object to check")
+		os.Exit(2)
+	}
+
+	bin := input.read(args[0], "object")
+
+	violations, err := msgpackdiff.CheckCanonical(bin, parseOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse object: %v\n", err)
+		os.Exit(2)
+	}
+
+	for _, violation := range violations {
+		fmt.Println(violation)
+	}
+
+	if len(violations) != 0 {
+		s := "s"
+		if len(violations) == 1 {
+			s = ""
+		}
+		fmt.Printf("Object is not canonical: %d violation%s\n", len(violations), s)
+		os.Exit(1)
+	}
+
+	fmt.Println("Object is canonical")
+}
+
+func canonicalize(args []string) {
+	flags := flag.NewFlagSet("canonicalize", flag.ExitOnError)
+	input := addInputFlags(flags)
+	output := flags.String("o", "", "The file to write the canonical encoding to. If not given, it is printed in base64.")
+	parseOptions := addParseFlags(flags)
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Must specify exactly one object to canonicalize")
+		os.Exit(2)
+	}
+
+	bin := input.read(args[0], "object")
+
+	objects, err := msgpackdiff.ParseAll(bin, parseOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse object: %v\n", err)
+		os.Exit(2)
+	}
+
+	var encoded []byte
+	for _, object := range objects {
+		encoded, err = msgpackdiff.Encode(encoded, object, msgpackdiff.EncodeOptions{Canonical: true})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode object: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if *output == "" {
+		fmt.Println(base64.StdEncoding.EncodeToString(encoded))
+		return
+	}
+
+	err = ioutil.WriteFile(*output, encoded, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write output: %v\n", err)
+		os.Exit(2)
+	}
+}
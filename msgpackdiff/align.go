@@ -0,0 +1,160 @@
+package msgpackdiff
+
+import (
+	"crypto/sha512"
+	"encoding/base32"
+	"errors"
+	"fmt"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// AlignKey returns the key of a top-level object that it is aligned by in CompareAligned.
+type AlignKey func(object MsgpObject) (MsgpObject, error)
+
+// TransactionIDKey is the name of the AlignKey that computes the ID of an Algorand transaction in
+// ParseAlignKey.
+const TransactionIDKey = "@txid"
+
+// ParseAlignKey returns the AlignKey described by str, which is either TransactionIDKey or a path
+// in the form accepted by ParsePath, like txn.note.
+func ParseAlignKey(str string) (AlignKey, error) {
+	if str == TransactionIDKey {
+		return TransactionID, nil
+	}
+	path, err := ParsePath(str)
+	if err != nil {
+		return nil, err
+	}
+	return PathKey(path), nil
+}
+
+// PathKey returns an AlignKey that aligns objects by their descendant at path. Objects that do
+// not have a descendant at path cannot be aligned.
+func PathKey(path Path) AlignKey {
+	return func(object MsgpObject) (MsgpObject, error) {
+		value, ok := path.Lookup(object)
+		if !ok {
+			return MsgpObject{}, fmt.Errorf("Object has no %s", path)
+		}
+		return value, nil
+	}
+}
+
+// transactionDomain is the domain separation prefix that is hashed with a transaction to compute
+// its ID.
+const transactionDomain = "TX"
+
+// TransactionID is an AlignKey that computes the ID of an Algorand transaction, which is the
+// SHA-512/256 hash of "TX" followed by the canonical encoding of the transaction, in base32
+// without padding. If object is a signed transaction, that is a map with a txn key, the ID of that
+// transaction is computed. The ID is returned as a string.
+func TransactionID(object MsgpObject) (MsgpObject, error) {
+	txn, ok := Path{KeyElement(StringKey("txn"))}.Lookup(object)
+	if !ok {
+		txn = object
+	}
+	if txn.Type != msgp.MapType {
+		return MsgpObject{}, errors.New("Object is not a transaction")
+	}
+
+	encoded, err := Encode([]byte(transactionDomain), txn, EncodeOptions{Canonical: true})
+	if err != nil {
+		return MsgpObject{}, err
+	}
+	hash := sha512.Sum512_256(encoded)
+	id := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(hash[:])
+	return MsgpObject{Type: msgp.StrType, Value: id}, nil
+}
+
+// CompareAligned is like CompareParsed, but aligns the top-level objects of a and b by the keys
+// that key returns for them rather than by their position. Objects with the same key are compared
+// with each other, and objects whose key only appears on one side are reported as deletions or
+// additions.
+//
+// The objects of the returned result are maps from the key of each object to the object, so that
+// the report shows each difference under the key of its object. An error is returned if the key
+// of an object cannot be found, or if two objects of the same side have the same key.
+func CompareAligned(a []MsgpObject, b []MsgpObject, key AlignKey, options CompareOptions) (result CompareResult, err error) {
+	var aligned [2]MsgpObject
+	for i, objects := range [2][]MsgpObject{a, b} {
+		aligned[i], err = alignObjects(objects, key)
+		if err != nil {
+			err = fmt.Errorf("Failed to align %s object: %w", sides[i], err)
+			return
+		}
+	}
+
+	result = compareKeyed(aligned, options)
+	return
+}
+
+// alignObjects returns a map from the key of each of objects to the object.
+func alignObjects(objects []MsgpObject, key AlignKey) (MsgpObject, error) {
+	keyed := MsgpMap{
+		Order:  make([]MapKey, 0, len(objects)),
+		Values: make(map[MapKey]MsgpObject, len(objects)),
+	}
+	indices := make(map[MapKey]int, len(objects))
+	for i, object := range objects {
+		keyObject, err := key(object)
+		if err != nil {
+			return MsgpObject{}, fmt.Errorf("Object %d: %w", i, err)
+		}
+		encoded, err := Encode(nil, keyObject, EncodeOptions{})
+		if err != nil {
+			return MsgpObject{}, fmt.Errorf("Object %d: %w", i, err)
+		}
+
+		mapKey := newMapKey(keyObject, encoded)
+		if first, ok := indices[mapKey]; ok {
+			return MsgpObject{}, fmt.Errorf("Objects %d and %d have the same key %s", first, i, mapKey)
+		}
+		indices[mapKey] = i
+
+		keyed.Order = append(keyed.Order, mapKey)
+		keyed.Values[mapKey] = object
+	}
+	return keyedObject(objects, keyed), nil
+}
+
+// keyedObject returns a map object with the entries of keyed, which are the top-level objects
+// objects under their keys. The map spans the objects in their input.
+func keyedObject(objects []MsgpObject, keyed MsgpMap) MsgpObject {
+	object := MsgpObject{Type: msgp.MapType, Value: keyed}
+	if len(objects) != 0 {
+		object.Start = objects[0].Start
+		object.End = objects[len(objects)-1].End
+	}
+	return object
+}
+
+// compareKeyed compares two maps from keys to top-level objects. The entries of the second map are
+// put in the same order as the entries of the first map with the same keys, followed by the
+// entries whose keys are not in the first map, so that only the objects under each key are
+// compared and not the order of the keys.
+func compareKeyed(keyed [2]MsgpObject, options CompareOptions) (result CompareResult) {
+	result.Reporter.Brief = options.Brief
+	result.Reporter.ShowOffsets = options.ShowOffsets
+
+	mapA := keyed[0].Value.(MsgpMap)
+	mapB := keyed[1].Value.(MsgpMap)
+	order := make([]MapKey, 0, len(mapB.Order))
+	for _, key := range mapA.Order {
+		if _, ok := mapB.Values[key]; ok {
+			order = append(order, key)
+		}
+	}
+	for _, key := range mapB.Order {
+		if _, ok := mapA.Values[key]; !ok {
+			order = append(order, key)
+		}
+	}
+	mapB.Order = order
+	keyed[1].Value = mapB
+
+	result.Objects = keyed
+	result.Equal = compareObjects(&result.Reporter, result.Objects[0], result.Objects[1], options)
+
+	return
+}
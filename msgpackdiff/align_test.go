@@ -0,0 +1,144 @@
+package msgpackdiff
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestCompareAligned(t *testing.T) {
+	type CompareAlignedTest struct {
+		Name     string
+		A        string
+		B        string
+		Key      string
+		Expected bool
+		Paths    []string
+	}
+
+	tests := []CompareAlignedTest{
+		{
+			Name:     "reordered",
+			A:        `{"id": "a", "amt": 1} {"id": "b", "amt": 2}`,
+			B:        `{"id": "b", "amt": 2} {"id": "a", "amt": 1}`,
+			Key:      "id",
+			Expected: true,
+		},
+		{
+			Name:     "changed",
+			A:        `{"id": "a", "amt": 1} {"id": "b", "amt": 2}`,
+			B:        `{"id": "b", "amt": 3} {"id": "a", "amt": 1}`,
+			Key:      "id",
+			Expected: false,
+			Paths:    []string{"b.amt"},
+		},
+		{
+			Name:     "added and removed",
+			A:        `{"id": "a", "amt": 1} {"id": "b", "amt": 2}`,
+			B:        `{"id": "c", "amt": 2} {"id": "a", "amt": 1}`,
+			Key:      "id",
+			Expected: false,
+			Paths:    []string{"b", "c"},
+		},
+		{
+			Name:     "nested key",
+			A:        `{"txn": {"note": [1, 2]}, "sig": 1} {"txn": {"note": [3]}, "sig": 2}`,
+			B:        `{"txn": {"note": [3]}, "sig": 2} {"txn": {"note": [1, 2]}, "sig": 1}`,
+			Key:      "txn.note",
+			Expected: true,
+		},
+		{
+			Name:     "transaction ID",
+			A:        `{"txn": {"amt": 1, "fee": 1000}, "sig": "x"} {"txn": {"amt": 2, "fee": 1000}}`,
+			B:        `{"txn": {"amt": 2, "fee": 1000}} {"txn": {"amt": 1, "fee": 1000}, "sig": "x"}`,
+			Key:      "@txid",
+			Expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			objectsA, err := ParseJSON([]byte(test.A), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			objectsB, err := ParseJSON([]byte(test.B), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			key, err := ParseAlignKey(test.Key)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			result, err := CompareAligned(objectsA, objectsB, key, CompareOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+
+			paths := []string{}
+			for _, diff := range result.Reporter.Differences {
+				if diff.Type != Replacement {
+					paths = append(paths, diffPath(diff).String())
+				}
+			}
+			if test.Paths == nil {
+				test.Paths = []string{}
+			}
+			if !reflect.DeepEqual(paths, test.Paths) {
+				t.Fatalf("Wrong differences: got %v, expected %v\n", paths, test.Paths)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+
+	invalid := []string{
+		`{"id": "a"} {"id": "a"}`,  // duplicate key
+		`{"id": "a"} {"key": "b"}`, // missing key
+	}
+	for _, input := range invalid {
+		objects, _ := ParseJSON([]byte(input), ParseOptions{})
+		_, err := CompareAligned(objects, []MsgpObject{}, PathKey(Path{KeyElement(StringKey("id"))}), CompareOptions{})
+		if err == nil {
+			t.Errorf("No error for %s\n", input)
+		}
+	}
+}
+
+func TestTransactionID(t *testing.T) {
+	bin, err := ioutil.ReadFile("../test/algo_txn_binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	object, _, err := Parse(bin)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	id, err := TransactionID(object)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	expected := "7TP5ETTVVGAFJNZSVHGHZTITT7HC6GD4VORFEGAQ7C4ONCLBBNWQ"
+	if id.Value != expected {
+		t.Fatalf("Wrong ID: got %v, expected %v\n", id.Value, expected)
+	}
+
+	// the ID of a transaction does not depend on how it is encoded
+	txn := object.Value.(MsgpMap).Values[StringKey("txn")]
+	txnID, err := TransactionID(txn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if txnID.Value != expected {
+		t.Fatalf("Wrong ID of the unsigned transaction: got %v, expected %v\n", txnID.Value, expected)
+	}
+}
@@ -0,0 +1,157 @@
+package msgpackdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ArrayKey declares that the elements of the arrays at a path are maps or other objects that are
+// identified by a key, so that the elements are matched by their keys when the arrays are compared
+// instead of by their positions.
+type ArrayKey struct {
+	// The path to the arrays from each top-level object, which may contain wildcards.
+	Path Path
+	// The path to the key from each element of the arrays.
+	Key Path
+}
+
+// ParseArrayKey parses an ArrayKey written as the path to the elements of the arrays, ending in
+// [*], followed by = and the path to the key of each element, for example accounts[*]=addr.
+func ParseArrayKey(str string) (ArrayKey, error) {
+	separator := strings.LastIndex(str, "=")
+	if separator < 0 || !strings.HasSuffix(str[:separator], "[*]") {
+		return ArrayKey{}, fmt.Errorf("Invalid array key %q, expected the form path[*]=key", str)
+	}
+
+	path, err := ParsePath(strings.TrimSuffix(str[:separator], "[*]"))
+	if err != nil {
+		return ArrayKey{}, err
+	}
+	key, err := ParsePath(str[separator+1:])
+	if err != nil {
+		return ArrayKey{}, err
+	}
+	return ArrayKey{Path: path, Key: key}, nil
+}
+
+func (k ArrayKey) String() string {
+	return fmt.Sprintf("%s[*]=%s", k.Path, k.Key)
+}
+
+// arrayKeyAt returns the key of the elements of the array at path, which starts with the index of
+// the top-level object that the array is in.
+func arrayKeyAt(path Path, options CompareOptions) (Path, bool) {
+	if len(path) == 0 {
+		// the top-level objects themselves are aligned with AlignKey instead
+		return nil, false
+	}
+	for _, arrayKey := range options.ArrayKeys {
+		if arrayKey.Path.Match(path[1:]) {
+			return arrayKey.Key, true
+		}
+	}
+	return nil, false
+}
+
+// elementKeys returns the key of each element of array. If an element has no key, or two elements
+// have the same key, false is returned.
+func elementKeys(array []MsgpObject, key Path) ([]MapKey, bool) {
+	keys := make([]MapKey, len(array))
+	seen := make(map[MapKey]bool, len(array))
+	for i, element := range array {
+		keyObject, ok := key.Lookup(element)
+		if !ok {
+			return nil, false
+		}
+		encoded, err := Encode(nil, keyObject, EncodeOptions{})
+		if err != nil {
+			return nil, false
+		}
+		keys[i] = newMapKey(keyObject, encoded)
+		if seen[keys[i]] {
+			return nil, false
+		}
+		seen[keys[i]] = true
+	}
+	return keys, true
+}
+
+// compareKeyedArrays compares the elements of two arrays that are matched by their keys, which
+// are keysA and keysB. Elements with the same key are compared wherever they are, and the elements
+// whose positions relative to the other matched elements differ are reported as moves, separately
+// from any differences in their contents. The reporter must have entered the arrays.
+func compareKeyedArrays(reporter *Reporter, arrayA []MsgpObject, arrayB []MsgpObject, keysA []MapKey, keysB []MapKey, options CompareOptions) (equal bool) {
+	equal = true
+
+	indicesA := make(map[MapKey]int, len(keysA))
+	for i, key := range keysA {
+		indicesA[key] = i
+	}
+	indicesB := make(map[MapKey]int, len(keysB))
+	for i, key := range keysB {
+		indicesB[key] = i
+	}
+
+	// the matched elements that are in the same order on both sides have not moved
+	var commonA, commonB []MapKey
+	for _, key := range keysA {
+		if _, ok := indicesB[key]; ok {
+			commonA = append(commonA, key)
+		}
+	}
+	for _, key := range keysB {
+		if _, ok := indicesA[key]; ok {
+			commonB = append(commonB, key)
+		}
+	}
+	unmoved := make(map[MapKey]bool, len(commonA))
+	for _, key := range lcsKeys(commonA, commonB) {
+		unmoved[key] = true
+	}
+
+	for indexA, key := range keysA {
+		reporter.SetIndex(indexA)
+		if reporter.ignore(options) {
+			continue
+		}
+		valueA := arrayA[indexA]
+
+		indexB, ok := indicesB[key]
+		if !ok {
+			if !reporter.ignoreEmpty(valueA, options) {
+				reporter.LogDeletion(valueA)
+				equal = false
+			}
+		} else {
+			valueB := arrayB[indexB]
+			if !unmoved[key] && !options.IgnoreOrder {
+				reporter.LogMove(valueA, valueB, indexB)
+				equal = false
+			}
+			if !compareObjects(reporter, valueA, valueB, options) {
+				equal = false
+			}
+		}
+
+		if options.Brief && !equal {
+			return
+		}
+	}
+
+	reporter.SetIndex(len(arrayA))
+	for indexB, key := range keysB {
+		valueB := arrayB[indexB]
+		if _, ok := indicesA[key]; ok {
+			continue
+		}
+		if !reporter.ignoreEmptyElementB(valueB, indexB, options) && !reporter.ignoreElementB(indexB, options) {
+			reporter.LogAddition(valueB)
+			equal = false
+			if options.Brief {
+				return
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,239 @@
+package msgpackdiff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ttacon/chalk"
+)
+
+func TestParseArrayKey(t *testing.T) {
+	key, err := ParseArrayKey("txns[*].accounts[*]=info.addr")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	expected := ArrayKey{
+		Path: Path{KeyElement(StringKey("txns")), AnyIndex, KeyElement(StringKey("accounts"))},
+		Key:  Path{KeyElement(StringKey("info")), KeyElement(StringKey("addr"))},
+	}
+	if !reflect.DeepEqual(key, expected) {
+		t.Fatalf("Wrong array key: got %v, expected %v\n", key, expected)
+	}
+
+	if key.String() != "txns[*].accounts[*]=info.addr" {
+		t.Fatalf("Wrong string: got %s, expected txns[*].accounts[*]=info.addr\n", key)
+	}
+
+	invalid := []string{"accounts=addr", "accounts[*]", "accounts[0]=addr", "a b[*]=addr"}
+	for _, str := range invalid {
+		if _, err := ParseArrayKey(str); err == nil {
+			t.Errorf("No error for %s\n", str)
+		}
+	}
+}
+
+func TestCompareArrayKeys(t *testing.T) {
+	type ArrayKeyTest struct {
+		Name     string
+		A        string
+		B        string
+		Keys     []string
+		Options  CompareOptions
+		Expected bool
+		// the type and path of each difference, other than replacements
+		Differences []string
+	}
+
+	accounts := `{"accounts": [{"addr": "A", "amt": 1}, {"addr": "B", "amt": 2}, {"addr": "C", "amt": 3}]}`
+
+	tests := []ArrayKeyTest{
+		{
+			Name:     "same order",
+			A:        accounts,
+			B:        accounts,
+			Keys:     []string{"accounts[*]=addr"},
+			Expected: true,
+		},
+		{
+			Name:        "moved",
+			A:           accounts,
+			B:           `{"accounts": [{"addr": "C", "amt": 3}, {"addr": "A", "amt": 1}, {"addr": "B", "amt": 2}]}`,
+			Keys:        []string{"accounts[*]=addr"},
+			Expected:    false,
+			Differences: []string{"move [0].accounts[2]"},
+		},
+		{
+			Name:     "moved with ignored order",
+			A:        accounts,
+			B:        `{"accounts": [{"addr": "C", "amt": 3}, {"addr": "A", "amt": 1}, {"addr": "B", "amt": 2}]}`,
+			Keys:     []string{"accounts[*]=addr"},
+			Options:  CompareOptions{IgnoreOrder: true},
+			Expected: true,
+		},
+		{
+			Name:        "moved and changed",
+			A:           accounts,
+			B:           `{"accounts": [{"addr": "C", "amt": 4}, {"addr": "A", "amt": 1}, {"addr": "B", "amt": 2}]}`,
+			Keys:        []string{"accounts[*]=addr"},
+			Expected:    false,
+			Differences: []string{"move [0].accounts[2]", "deletion [0].accounts[2].amt"},
+		},
+		{
+			Name:        "inserted and removed",
+			A:           accounts,
+			B:           `{"accounts": [{"addr": "D", "amt": 1}, {"addr": "A", "amt": 1}, {"addr": "C", "amt": 3}]}`,
+			Keys:        []string{"accounts[*]=addr"},
+			Expected:    false,
+			Differences: []string{"deletion [0].accounts[1]", "addition [0].accounts[3]"},
+		},
+		{
+			Name:        "wildcard path",
+			A:           `{"txns": [{"accounts": [{"addr": "A"}, {"addr": "B"}]}]}`,
+			B:           `{"txns": [{"accounts": [{"addr": "B"}, {"addr": "A"}]}]}`,
+			Keys:        []string{"txns[*].accounts[*]=addr"},
+			Expected:    false,
+			Differences: []string{"move [0].txns[0].accounts[0]"},
+		},
+		{
+			Name:     "top-level array",
+			A:        `[{"id": 1}, {"id": 2}] [{"id": 3}]`,
+			B:        `[{"id": 2}, {"id": 1}] [{"id": 3}]`,
+			Keys:     []string{"[*]=id"},
+			Options:  CompareOptions{IgnoreOrder: true},
+			Expected: true,
+		},
+		{
+			Name:     "other path",
+			A:        accounts,
+			B:        `{"accounts": [{"addr": "C", "amt": 3}, {"addr": "A", "amt": 1}, {"addr": "B", "amt": 2}]}`,
+			Keys:     []string{"assets[*]=addr"},
+			Options:  CompareOptions{Brief: true},
+			Expected: false,
+		},
+		{
+			Name:        "duplicate keys",
+			A:           `{"accounts": [{"addr": "A", "amt": 1}, {"addr": "A", "amt": 2}]}`,
+			B:           `{"accounts": [{"addr": "A", "amt": 2}, {"addr": "A", "amt": 1}]}`,
+			Keys:        []string{"accounts[*]=addr"},
+			Expected:    false,
+			Differences: []string{"deletion [0].accounts[0]", "addition [0].accounts[2]"},
+		},
+	}
+
+	diffTypes := map[DifferenceType]string{Deletion: "deletion", Addition: "addition", Move: "move"}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			objectsA, err := ParseJSON([]byte(test.A), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			objectsB, err := ParseJSON([]byte(test.B), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			for _, str := range test.Keys {
+				key, err := ParseArrayKey(str)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v\n", err)
+				}
+				test.Options.ArrayKeys = append(test.Options.ArrayKeys, key)
+			}
+
+			result := CompareParsed(objectsA, objectsB, test.Options)
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+
+			if test.Options.Brief {
+				return
+			}
+
+			differences := []string{}
+			for _, diff := range result.Reporter.Differences {
+				if diff.Type != Replacement {
+					differences = append(differences, fmt.Sprintf("%s %s", diffTypes[diff.Type], diffPath(diff)))
+				}
+			}
+			if test.Differences == nil {
+				test.Differences = []string{}
+			}
+			if !reflect.DeepEqual(differences, test.Differences) {
+				t.Fatalf("Wrong differences: got %v, expected %v\n", differences, test.Differences)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestMoveReport(t *testing.T) {
+	a, _ := ParseJSON([]byte(`[{"id": 1}, {"id": 2}]`), ParseOptions{})
+	b, _ := ParseJSON([]byte(`[{"id": 2}, {"id": 1}]`), ParseOptions{})
+
+	key, _ := ParseArrayKey("[*]=id")
+	result := CompareParsed(a, b, CompareOptions{ArrayKeys: []ArrayKey{key}})
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	yellow := chalk.Yellow.String()
+	expected := fmt.Sprintf(` [
+%s~  {
+%s~    "id": 1
+%s~  }, (moved to index 1)%s
+   {
+     "id": 2
+   }
+ ]
+`, yellow, yellow, yellow, chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestMovedAndChangedReport(t *testing.T) {
+	a, _ := ParseJSON([]byte(`[{"id": 1, "v": 1}, {"id": 2, "v": 2}, {"id": 3, "v": 3}]`), ParseOptions{})
+	b, _ := ParseJSON([]byte(`[{"id": 3, "v": 9}, {"id": 1, "v": 5}, {"id": 2, "v": 2}]`), ParseOptions{})
+
+	key, _ := ParseArrayKey("[*]=id")
+	result := CompareParsed(a, b, CompareOptions{ArrayKeys: []ArrayKey{key}})
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	// the move and the changes of each element are shown separately, under that element
+	red, green, yellow, reset := chalk.Red.String(), chalk.Green.String(), chalk.Yellow.String(), chalk.ResetColor.String()
+	expected := fmt.Sprintf(` [
+   {
+     "id": 1,
+%s-    "v": 1%s
+%s+    "v": 5%s
+   },
+   {
+     "id": 2,
+     "v": 2
+   },
+%s~  {
+%s~    "id": 3,
+%s~    "v": 3
+%s~  }, (moved to index 0)%s
+   {
+     "id": 3,
+%s-    "v": 3%s
+%s+    "v": 9%s
+   }
+ ]
+`, red, reset, green, reset, yellow, yellow, yellow, yellow, reset, red, reset, green, reset)
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
@@ -0,0 +1,152 @@
+package msgpackdiff
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// Violation is a way in which a MessagePack object is not encoded canonically.
+type Violation struct {
+	// The map keys and array indices that lead from the top-level object to the object that is not
+	// canonical.
+	Path Path
+	// The offset of the object in the input.
+	Offset int
+	// A description of the problem.
+	Message string
+}
+
+func (v Violation) String() string {
+	if len(v.Path) == 0 {
+		return fmt.Sprintf("%s (offset 0x%x)", v.Message, v.Offset)
+	}
+	return fmt.Sprintf("%s: %s (offset 0x%x)", v.Path, v.Message, v.Offset)
+}
+
+// CheckCanonical checks if the MessagePack objects in bin are encoded canonically, in the way that
+// Algorand encodes objects. A canonical encoding:
+//   - sorts the keys of maps, strings lexicographically and numbers by value,
+//   - encodes integers, strings, binary data, arrays and maps with the smallest possible format,
+//   - omits map values that are empty, and
+//   - has no duplicate keys.
+//
+// Every violation found is returned. A duplicate key stops parsing, so nothing after it is checked.
+// If the objects cannot be parsed for any other reason, an error is returned.
+func CheckCanonical(bin []byte, options ParseOptions) (violations []Violation, err error) {
+	objects, err := ParseAll(bin, options)
+	for _, object := range objects {
+		violations = checkCanonicalObject(violations, Path{}, object)
+	}
+
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) && errors.Is(parseErr.Err, ErrDuplicateKey) {
+		violations = append(violations, Violation{
+			Path:    parseErr.Path,
+			Offset:  parseErr.Offset,
+			Message: "Key appears more than once",
+		})
+		err = nil
+	}
+	return
+}
+
+// checkCanonicalObject appends the violations of object and its children to violations. The path
+// leads to object.
+func checkCanonicalObject(violations []Violation, path Path, object MsgpObject) []Violation {
+	violations = checkMinimalFormat(violations, path, object, "Value")
+
+	switch object.Type {
+	case msgp.MapType:
+		valueMap := object.Value.(MsgpMap)
+		for i, key := range valueMap.Order {
+			keyPath := append(path[:len(path):len(path)], KeyElement(key))
+			keyObject, ok := valueMap.Keys[key]
+			if !ok {
+				// a key that cannot be decoded has an unknown format, which is not checked
+				keyObject, _ = key.Object()
+			}
+
+			if i > 0 && keyLess(key, valueMap.Order[i-1]) {
+				violations = append(violations, Violation{
+					Path:    keyPath,
+					Offset:  keyObject.Start,
+					Message: fmt.Sprintf("Key is not sorted, it should come before %s", valueMap.Order[i-1]),
+				})
+			}
+			violations = checkMinimalFormat(violations, keyPath, keyObject, "Key")
+
+			value := valueMap.Values[key]
+			if value.IsEmpty() {
+				violations = append(violations, Violation{
+					Path:    keyPath,
+					Offset:  value.Start,
+					Message: "Value is empty and should be omitted",
+				})
+			}
+			violations = checkCanonicalObject(violations, keyPath, value)
+		}
+	case msgp.ArrayType:
+		for i, item := range object.Value.([]MsgpObject) {
+			violations = checkCanonicalObject(violations, append(path[:len(path):len(path)], IndexElement(i)), item)
+		}
+	}
+
+	return violations
+}
+
+// checkMinimalFormat appends a violation to violations if object is not encoded with the smallest
+// possible format. The description says what the object is.
+func checkMinimalFormat(violations []Violation, path Path, object MsgpObject, description string) []Violation {
+	if object.Format == FormatUnknown {
+		return violations
+	}
+
+	switch object.Type {
+	case msgp.IntType, msgp.UintType, msgp.StrType, msgp.BinType, msgp.ArrayType, msgp.MapType:
+		minimal := minimalFormat(object)
+		if object.Format != minimal {
+			violations = append(violations, Violation{
+				Path:    path,
+				Offset:  object.Start,
+				Message: fmt.Sprintf("%s is encoded as %s instead of %s", description, object.Format, minimal),
+			})
+		}
+	}
+
+	return violations
+}
+
+// keyLess returns true if a should be sorted before b. Strings are sorted lexicographically by
+// their bytes and numbers are sorted by value. Keys of other types, or of different types, are not
+// ordered, so false is returned for them.
+func keyLess(a MapKey, b MapKey) bool {
+	if a.Type == msgp.StrType && b.Type == msgp.StrType {
+		return a.Value.(string) < b.Value.(string)
+	}
+
+	if isInteger(a) && isInteger(b) {
+		// negative numbers come before non-negative ones, which can all be compared as uint64
+		negativeA := a.Type == msgp.IntType && a.Value.(int64) < 0
+		negativeB := b.Type == msgp.IntType && b.Value.(int64) < 0
+		if negativeA || negativeB {
+			return negativeA && (!negativeB || a.Value.(int64) < b.Value.(int64))
+		}
+		return keyUint(a) < keyUint(b)
+	}
+
+	return false
+}
+
+func isInteger(key MapKey) bool {
+	return key.Type == msgp.IntType || key.Type == msgp.UintType
+}
+
+// keyUint returns the value of a non-negative integer key as a uint64.
+func keyUint(key MapKey) uint64 {
+	if key.Type == msgp.IntType {
+		return uint64(key.Value.(int64))
+	}
+	return key.Value.(uint64)
+}
@@ -0,0 +1,84 @@
+package msgpackdiff
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCheckCanonical(t *testing.T) {
+	type CanonicalTest struct {
+		Name     string
+		Input    string
+		Expected []string
+	}
+
+	tests := []CanonicalTest{
+		{
+			Name:     "canonical",
+			Input:    "gqFhAaFiAg==", // {"a":1,"b":2}
+			Expected: nil,
+		},
+		{
+			Name:  "violations",
+			Input: "hKFiAKFhzQAFoWPZAXihZJHQAQ==", // {"b":0,"a":uint16(5),"c":str8("x"),"d":[int8(1)]}
+			Expected: []string{
+				"b: Value is empty and should be omitted (offset 0x3)",
+				"a: Key is not sorted, it should come before \"b\" (offset 0x4)",
+				"a: Value is encoded as uint16 instead of fixint (offset 0x6)",
+				"c: Value is encoded as str8 instead of fixstr (offset 0xb)",
+				"d[0]: Value is encoded as int8 instead of fixint (offset 0x11)",
+			},
+		},
+		{
+			Name:  "key format",
+			Input: "gdkBYQE=", // {str8("a"):1}
+			Expected: []string{
+				"a: Key is encoded as str8 instead of fixstr (offset 0x1)",
+			},
+		},
+		{
+			Name:  "map format",
+			Input: "3gABoWEB", // map16({"a":1})
+			Expected: []string{
+				"Value is encoded as map16 instead of fixmap (offset 0x0)",
+			},
+		},
+		{
+			Name:     "nonempty binary",
+			Input:    "xAEA", // base64(AA==)
+			Expected: nil,
+		},
+		{
+			Name:  "duplicate key",
+			Input: "gqFhAaFhAQ==", // {"a":1,"a":1}
+			Expected: []string{
+				"a: Key appears more than once (offset 0x4)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			decoded, err := base64.StdEncoding.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			violations, err := CheckCanonical(decoded, ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if len(violations) != len(test.Expected) {
+				t.Fatalf("Wrong number of violations: got %v, expected %v\n", violations, test.Expected)
+			}
+
+			for i, violation := range violations {
+				if violation.String() != test.Expected[i] {
+					t.Errorf("Wrong violation: got %q, expected %q\n", violation, test.Expected[i])
+				}
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
@@ -0,0 +1,380 @@
+package msgpackdiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// The major types of CBOR, from the top three bits of the first byte of an item.
+const (
+	cborUnsigned = iota
+	cborNegative
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// cborNegativeBignum is the tag of a negative bignum, whose content is a byte string that holds an
+// unsigned integer n for the integer -1-n.
+const cborNegativeBignum = 3
+
+// cborIndefinite is the additional information of an item with an indefinite length, and cborBreak
+// is the byte that ends such an item.
+const (
+	cborIndefinite = 31
+	cborBreak      = 0xff
+)
+
+// ParseCBOR parses every CBOR item in data, which may hold any number of items one after another,
+// into the same in-memory data structure that ParseAll returns for MessagePack, so that CBOR can be
+// compared to CBOR or to MessagePack. The limits of options apply to the input as a whole.
+//
+// CBOR items are converted to MessagePack objects like so:
+//   - unsigned and negative integers become IntType or UintType, like ParseJSON, or the same Tag
+//     as a negative bignum if they are less than the minimum int64, so that they are exact,
+//   - byte strings become BinType and text strings become StrType,
+//   - arrays become ArrayType and maps become MapType, with their keys in order,
+//   - false, true and null become BoolType and NilType, and undefined becomes NilType too,
+//   - half and single precision floats become Float32Type, and double precision floats become
+//     Float64Type, and
+//   - tags become ExtensionType objects whose value is a Tag.
+//
+// Strings, arrays and maps with an indefinite length are parsed the same as if their length was
+// given. The objects have no format, and their offsets are those of their encoding in data. If an
+// item cannot be parsed, the items before it are returned along with a *ParseError.
+func ParseCBOR(data []byte, options ParseOptions) (objects []MsgpObject, err error) {
+	objects = []MsgpObject{}
+	p := cborParser{
+		data:   data,
+		parser: newParser(data, options),
+	}
+	for p.offset < len(data) {
+		var object MsgpObject
+		object, err = p.parseItem(0)
+		if err != nil {
+			return
+		}
+		objects = append(objects, object)
+	}
+	return
+}
+
+// ErrCBORBreak is the error of a ParseError when a CBOR break byte appears outside of an item with
+// an indefinite length.
+var ErrCBORBreak = errors.New("Unexpected CBOR break")
+
+// cborParser holds the state of a call to ParseCBOR.
+type cborParser struct {
+	data   []byte
+	offset int
+	parser *parser
+}
+
+// parseItem parses the item at the current offset, which is nested inside depth containers.
+func (p *cborParser) parseItem(depth int) (parsed MsgpObject, err error) {
+	start := p.offset
+	defer func() {
+		if _, ok := err.(*ParseError); err != nil && !ok {
+			typeByte := -1
+			if start < len(p.data) {
+				typeByte = int(p.data[start])
+			}
+			err = &ParseError{Offset: start, TypeByte: typeByte, Err: err}
+		}
+	}()
+
+	major, info, argument, err := p.readHead()
+	if err != nil {
+		return
+	}
+
+	switch major {
+	case cborUnsigned:
+		parsed = unsignedObject(argument)
+	case cborNegative:
+		if argument <= math.MaxInt64 {
+			parsed = MsgpObject{Type: msgp.IntType, Value: -1 - int64(argument)}
+		} else {
+			// a float64 would not be exact, so the integer is kept as a negative bignum instead
+			content := MsgpObject{Type: msgp.BinType, Value: make([]byte, 8), Start: start, End: p.offset}
+			binary.BigEndian.PutUint64(content.Value.([]byte), argument)
+			parsed = MsgpObject{Type: msgp.ExtensionType, Value: Tag{Number: cborNegativeBignum, Content: content}}
+		}
+	case cborBytes, cborText:
+		var str []byte
+		str, err = p.readString(major, info, argument)
+		if major == cborBytes {
+			parsed = MsgpObject{Type: msgp.BinType, Value: str}
+		} else {
+			parsed = MsgpObject{Type: msgp.StrType, Value: string(str)}
+		}
+	case cborArray:
+		if depth >= p.parser.maxDepth {
+			return parsed, ErrMaxDepth
+		}
+		parsed.Type = msgp.ArrayType
+		parsed.Value, err = p.parseArray(info, argument, depth)
+	case cborMap:
+		if depth >= p.parser.maxDepth {
+			return parsed, ErrMaxDepth
+		}
+		parsed.Type = msgp.MapType
+		parsed.Value, err = p.parseMap(info, argument, depth)
+	case cborTag:
+		if depth >= p.parser.maxDepth {
+			return parsed, ErrMaxDepth
+		}
+		var content MsgpObject
+		content, err = p.parseItem(depth + 1)
+		parsed = MsgpObject{Type: msgp.ExtensionType, Value: Tag{Number: argument, Content: content}}
+	case cborSimple:
+		parsed, err = simpleObject(info, argument)
+	}
+
+	parsed.Start = start
+	parsed.End = p.offset
+	return
+}
+
+// readHead reads the first bytes of an item, which hold its major type and argument. The info is
+// the additional information of the item, from the low five bits of its first byte.
+func (p *cborParser) readHead() (major int, info int, argument uint64, err error) {
+	if p.offset >= len(p.data) {
+		err = msgp.ErrShortBytes
+		return
+	}
+	lead := p.data[p.offset]
+	major = int(lead >> 5)
+	info = int(lead & 0x1f)
+	p.offset++
+
+	switch {
+	case info < 24:
+		argument = uint64(info)
+	case info <= 27:
+		// the argument follows in 1, 2, 4 or 8 bytes
+		width := 1 << (info - 24)
+		if len(p.data)-p.offset < width {
+			err = msgp.ErrShortBytes
+			return
+		}
+		var padded [8]byte
+		copy(padded[8-width:], p.data[p.offset:p.offset+width])
+		argument = binary.BigEndian.Uint64(padded[:])
+		p.offset += width
+	case info == cborIndefinite:
+		switch major {
+		case cborBytes, cborText, cborArray, cborMap:
+		case cborSimple:
+			err = ErrCBORBreak
+		default:
+			err = errors.New("Invalid CBOR indefinite length")
+		}
+	default:
+		err = errors.New("Invalid CBOR additional information")
+	}
+	return
+}
+
+// readString reads the content of a byte or text string whose head has been read. A string with an
+// indefinite length is the concatenation of its chunks, which must be strings of the same type.
+func (p *cborParser) readString(major int, info int, length uint64) ([]byte, error) {
+	if info != cborIndefinite {
+		str, err := p.readBytes(length)
+		// copy the string so that it does not share memory with the input, as in Parse
+		return append([]byte{}, str...), err
+	}
+
+	str := []byte{}
+	for {
+		if p.atBreak() {
+			return str, nil
+		}
+		start := p.offset
+		chunkMajor, chunkInfo, chunkLength, err := p.readHead()
+		if err == nil && (chunkMajor != major || chunkInfo == cborIndefinite) {
+			err = errors.New("Invalid CBOR string chunk")
+		}
+		if err != nil {
+			p.offset = start
+			return str, err
+		}
+		chunk, err := p.readBytes(chunkLength)
+		if err != nil {
+			return str, err
+		}
+		str = append(str, chunk...)
+	}
+}
+
+// readBytes reads length bytes.
+func (p *cborParser) readBytes(length uint64) ([]byte, error) {
+	if uint64(len(p.data)-p.offset) < length {
+		return nil, msgp.ErrShortBytes
+	}
+	bytes := p.data[p.offset : p.offset+int(length)]
+	p.offset += int(length)
+	return bytes, nil
+}
+
+// atBreak returns true and consumes the break byte if the items of a container with an indefinite
+// length have ended.
+func (p *cborParser) atBreak() bool {
+	if p.offset < len(p.data) && p.data[p.offset] == cborBreak {
+		p.offset++
+		return true
+	}
+	return false
+}
+
+// containerLength checks the length of a container whose head has been read. The length of a
+// container with an indefinite length is checked as its items are parsed.
+func (p *cborParser) containerLength(info int, length uint64, entrySize int) (int, error) {
+	if info == cborIndefinite {
+		return 0, nil
+	}
+	if length > uint64(p.parser.maxContainerLength) {
+		return 0, ErrMaxContainerLength
+	}
+	return int(length), p.parser.allocate(entrySize * int(length))
+}
+
+// parseArray parses the items of an array whose head has been read.
+func (p *cborParser) parseArray(info int, length uint64, depth int) ([]MsgpObject, error) {
+	size, err := p.containerLength(info, length, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	valueArray := make([]MsgpObject, 0, size)
+	for i := 0; info == cborIndefinite || i < size; i++ {
+		if info == cborIndefinite {
+			if p.atBreak() {
+				break
+			}
+			if i >= p.parser.maxContainerLength {
+				return valueArray, ErrMaxContainerLength
+			}
+		}
+		item, err := p.parseItem(depth + 1)
+		if err != nil {
+			return valueArray, withPathElement(err, IndexElement(i))
+		}
+		valueArray = append(valueArray, item)
+	}
+	return valueArray, nil
+}
+
+// parseMap parses the entries of a map whose head has been read.
+func (p *cborParser) parseMap(info int, length uint64, depth int) (MsgpMap, error) {
+	// each entry of the map is a key and a value
+	size, err := p.containerLength(info, length, 2)
+	valueMap := MsgpMap{
+		Order:  make([]MapKey, 0, size),
+		Values: make(map[MapKey]MsgpObject, size),
+		Keys:   make(map[MapKey]MsgpObject, size),
+	}
+	if err != nil {
+		return valueMap, err
+	}
+
+	for i := 0; info == cborIndefinite || i < size; i++ {
+		if info == cborIndefinite {
+			if p.atBreak() {
+				break
+			}
+			if i >= p.parser.maxContainerLength {
+				return valueMap, ErrMaxContainerLength
+			}
+		}
+
+		keyObject, err := p.parseItem(depth + 1)
+		if err != nil {
+			return valueMap, err
+		}
+		key, err := cborMapKey(keyObject)
+		if err != nil {
+			return valueMap, &ParseError{
+				Offset:   keyObject.Start,
+				TypeByte: int(p.data[keyObject.Start]),
+				Err:      err,
+			}
+		}
+		if _, ok := valueMap.Values[key]; ok {
+			err = &ParseError{
+				Offset:   keyObject.Start,
+				Path:     Path{KeyElement(key)},
+				TypeByte: int(p.data[keyObject.Start]),
+				Err:      ErrDuplicateKey,
+			}
+			return valueMap, err
+		}
+
+		value, err := p.parseItem(depth + 1)
+		if err != nil {
+			return valueMap, withPathElement(err, KeyElement(key))
+		}
+		valueMap.Order = append(valueMap.Order, key)
+		valueMap.Keys[key] = keyObject
+		valueMap.Values[key] = value
+	}
+	return valueMap, nil
+}
+
+// cborMapKey creates the MapKey for an object that appears as a key in a CBOR map. MapKeys for
+// containers hold their MessagePack encoding, so keys that cannot be encoded in MessagePack, such as
+// tags, are not supported.
+func cborMapKey(object MsgpObject) (MapKey, error) {
+	encoded, err := Encode(nil, object, EncodeOptions{})
+	if err != nil {
+		return MapKey{}, errors.New("Unsupported CBOR map key")
+	}
+	return newMapKey(object, encoded), nil
+}
+
+// simpleObject returns the object for a simple value or float, given the additional information
+// and argument of its head.
+func simpleObject(info int, argument uint64) (MsgpObject, error) {
+	switch info {
+	case 20, 21:
+		return MsgpObject{Type: msgp.BoolType, Value: info == 21}, nil
+	case 22, 23:
+		// null and undefined
+		return MsgpObject{Type: msgp.NilType}, nil
+	case 25:
+		return MsgpObject{Type: msgp.Float32Type, Value: halfToFloat32(uint16(argument))}, nil
+	case 26:
+		return MsgpObject{Type: msgp.Float32Type, Value: math.Float32frombits(uint32(argument))}, nil
+	case 27:
+		return MsgpObject{Type: msgp.Float64Type, Value: math.Float64frombits(argument)}, nil
+	}
+	return MsgpObject{}, errors.New("Unsupported CBOR simple value")
+}
+
+// halfToFloat32 converts an IEEE 754 half precision float to a float32, which can represent every
+// half precision value exactly.
+func halfToFloat32(half uint16) float32 {
+	sign := uint32(half>>15) << 31
+	exponent := uint32(half>>10) & 0x1f
+	fraction := uint32(half) & 0x3ff
+
+	switch exponent {
+	case 0:
+		// zero or a subnormal number, which is fraction * 2^-24
+		value := float32(fraction) / (1 << 24)
+		if sign != 0 {
+			value = -value
+		}
+		return value
+	case 0x1f:
+		// infinity or NaN
+		return math.Float32frombits(sign | 0xff<<23 | fraction<<13)
+	}
+	return math.Float32frombits(sign | (exponent-15+127)<<23 | fraction<<13)
+}
@@ -0,0 +1,443 @@
+package msgpackdiff
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+func TestParseCBOR(t *testing.T) {
+	type ParseCBORTest struct {
+		Name     string
+		Input    string
+		Expected MsgpObject
+	}
+
+	// the examples of RFC 8949, appendix A
+	tests := []ParseCBORTest{
+		{
+			Name:     "0",
+			Input:    "00",
+			Expected: MsgpObject{Type: msgp.IntType, Value: int64(0)},
+		},
+		{
+			Name:     "24",
+			Input:    "1818",
+			Expected: MsgpObject{Type: msgp.IntType, Value: int64(24)},
+		},
+		{
+			Name:     "128",
+			Input:    "1880",
+			Expected: MsgpObject{Type: msgp.UintType, Value: uint64(128)},
+		},
+		{
+			Name:     "max uint64",
+			Input:    "1bffffffffffffffff",
+			Expected: MsgpObject{Type: msgp.UintType, Value: uint64(math.MaxUint64)},
+		},
+		{
+			Name:     "-1000",
+			Input:    "3903e7",
+			Expected: MsgpObject{Type: msgp.IntType, Value: int64(-1000)},
+		},
+		{
+			Name:     "min negative",
+			Input:    "3bffffffffffffffff",
+			Expected: MsgpObject{Type: msgp.ExtensionType, Value: Tag{Number: 3, Content: MsgpObject{Type: msgp.BinType, Value: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}}}},
+		},
+		{
+			Name:     "less than min int64",
+			Input:    "3b8000000000000000",
+			Expected: MsgpObject{Type: msgp.ExtensionType, Value: Tag{Number: 3, Content: MsgpObject{Type: msgp.BinType, Value: []byte{0x80, 0, 0, 0, 0, 0, 0, 0}}}},
+		},
+		{
+			Name:     "min int64",
+			Input:    "3b7fffffffffffffff",
+			Expected: MsgpObject{Type: msgp.IntType, Value: int64(math.MinInt64)},
+		},
+		{
+			Name:     "half 1.0",
+			Input:    "f93c00",
+			Expected: MsgpObject{Type: msgp.Float32Type, Value: float32(1)},
+		},
+		{
+			Name:     "half 65504",
+			Input:    "f97bff",
+			Expected: MsgpObject{Type: msgp.Float32Type, Value: float32(65504)},
+		},
+		{
+			Name:     "half subnormal",
+			Input:    "f90001",
+			Expected: MsgpObject{Type: msgp.Float32Type, Value: float32(5.960464477539063e-8)},
+		},
+		{
+			Name:     "half -infinity",
+			Input:    "f9fc00",
+			Expected: MsgpObject{Type: msgp.Float32Type, Value: float32(math.Inf(-1))},
+		},
+		{
+			Name:     "single",
+			Input:    "fa47c35000",
+			Expected: MsgpObject{Type: msgp.Float32Type, Value: float32(100000)},
+		},
+		{
+			Name:     "double",
+			Input:    "fb3ff199999999999a",
+			Expected: MsgpObject{Type: msgp.Float64Type, Value: 1.1},
+		},
+		{
+			Name:     "true",
+			Input:    "f5",
+			Expected: MsgpObject{Type: msgp.BoolType, Value: true},
+		},
+		{
+			Name:     "undefined",
+			Input:    "f7",
+			Expected: MsgpObject{Type: msgp.NilType},
+		},
+		{
+			Name:     "bytes",
+			Input:    "4401020304",
+			Expected: MsgpObject{Type: msgp.BinType, Value: []byte{1, 2, 3, 4}},
+		},
+		{
+			Name:     "text",
+			Input:    "6449455446",
+			Expected: MsgpObject{Type: msgp.StrType, Value: "IETF"},
+		},
+		{
+			Name:     "indefinite bytes",
+			Input:    "5f42010243030405ff",
+			Expected: MsgpObject{Type: msgp.BinType, Value: []byte{1, 2, 3, 4, 5}},
+		},
+		{
+			Name:     "indefinite text",
+			Input:    "7f657374726561646d696e67ff",
+			Expected: MsgpObject{Type: msgp.StrType, Value: "streaming"},
+		},
+		{
+			Name:  "indefinite arrays",
+			Input: "9f018202039f0405ffff",
+			Expected: MsgpObject{
+				Type: msgp.ArrayType,
+				Value: []MsgpObject{
+					{Type: msgp.IntType, Value: int64(1)},
+					{
+						Type: msgp.ArrayType,
+						Value: []MsgpObject{
+							{Type: msgp.IntType, Value: int64(2)},
+							{Type: msgp.IntType, Value: int64(3)},
+						},
+					},
+					{
+						Type: msgp.ArrayType,
+						Value: []MsgpObject{
+							{Type: msgp.IntType, Value: int64(4)},
+							{Type: msgp.IntType, Value: int64(5)},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "map",
+			Input: "a26161016162820203",
+			Expected: MsgpObject{
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("a"), StringKey("b")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("a"): {Type: msgp.IntType, Value: int64(1)},
+						StringKey("b"): {
+							Type: msgp.ArrayType,
+							Value: []MsgpObject{
+								{Type: msgp.IntType, Value: int64(2)},
+								{Type: msgp.IntType, Value: int64(3)},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "indefinite map",
+			Input: "bf6346756ef563416d7421ff",
+			Expected: MsgpObject{
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("Fun"), StringKey("Amt")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("Fun"): {Type: msgp.BoolType, Value: true},
+						StringKey("Amt"): {Type: msgp.IntType, Value: int64(-2)},
+					},
+				},
+			},
+		},
+		{
+			Name:  "tag",
+			Input: "c11a514b67b0",
+			Expected: MsgpObject{
+				Type: msgp.ExtensionType,
+				Value: Tag{
+					Number:  1,
+					Content: MsgpObject{Type: msgp.UintType, Value: uint64(1363896240)},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			input, err := hex.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			objects, err := ParseCBOR(input, ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if len(objects) != 1 {
+				t.Fatalf("Wrong number of objects: got %d, expected 1\n", len(objects))
+			}
+
+			object := stripMetadata(objects[0])
+			if !reflect.DeepEqual(object, test.Expected) {
+				t.Fatalf("Wrong object: got %v, expected %v\n", object, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestParseCBORKeys(t *testing.T) {
+	// {1: 2, [1, 2]: "x"}
+	input, _ := hex.DecodeString("a201028201026178")
+	objects, err := ParseCBOR(input, ParseOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	valueMap := objects[0].Value.(MsgpMap)
+	keys := []string{}
+	for _, key := range valueMap.Order {
+		keys = append(keys, key.String())
+	}
+
+	expected := []string{"int(1)", "[1, 2]"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("Wrong keys: got %v, expected %v\n", keys, expected)
+	}
+}
+
+func TestParseCBORErrors(t *testing.T) {
+	type ErrorTest struct {
+		Name     string
+		Input    string
+		Options  ParseOptions
+		Err      error
+		Offset   int
+		Path     string
+		TypeByte int
+	}
+
+	tests := []ErrorTest{
+		{
+			Name:     "break",
+			Input:    "ff",
+			Err:      ErrCBORBreak,
+			TypeByte: 0xff,
+		},
+		{
+			Name:     "truncated",
+			Input:    "8201",
+			Err:      msgp.ErrShortBytes,
+			Offset:   2,
+			Path:     "[1]",
+			TypeByte: -1,
+		},
+		{
+			Name:     "duplicate key",
+			Input:    "a2616101616102",
+			Err:      ErrDuplicateKey,
+			Offset:   4,
+			Path:     "a",
+			TypeByte: 0x61,
+		},
+		{
+			Name:     "depth",
+			Input:    "818101",
+			Options:  ParseOptions{MaxDepth: 1},
+			Err:      ErrMaxDepth,
+			Offset:   1,
+			Path:     "[0]",
+			TypeByte: 0x81,
+		},
+		{
+			Name:     "container length",
+			Input:    "83010203",
+			Options:  ParseOptions{MaxContainerLength: 2},
+			Err:      ErrMaxContainerLength,
+			TypeByte: 0x83,
+		},
+		{
+			Name:     "indefinite container length",
+			Input:    "9f010203ff",
+			Options:  ParseOptions{MaxContainerLength: 2},
+			Err:      ErrMaxContainerLength,
+			TypeByte: 0x9f,
+		},
+		{
+			Name:     "allocation",
+			Input:    "9a00010000",
+			Err:      ErrAllocationLimit,
+			TypeByte: 0x9a,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			input, err := hex.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			_, err = ParseCBOR(input, test.Options)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a *ParseError, got %v\n", err)
+			}
+
+			if !errors.Is(err, test.Err) {
+				t.Fatalf("Wrong error: got %v, expected %v\n", parseErr.Err, test.Err)
+			}
+
+			if parseErr.Offset != test.Offset {
+				t.Fatalf("Wrong offset: got %d, expected %d\n", parseErr.Offset, test.Offset)
+			}
+
+			if parseErr.Path.String() != test.Path {
+				t.Fatalf("Wrong path: got %s, expected %s\n", parseErr.Path, test.Path)
+			}
+
+			if parseErr.TypeByte != test.TypeByte {
+				t.Fatalf("Wrong type byte: got %d, expected %d\n", parseErr.TypeByte, test.TypeByte)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+
+	invalid := []string{
+		"1c",         // reserved additional information
+		"5f410061ff", // text chunk in a byte string
+		"a1c10100",   // tag as a map key
+		"f818",       // simple value
+		"3f",         // negative integer with an indefinite length
+	}
+	for _, input := range invalid {
+		bin, _ := hex.DecodeString(input)
+		_, err := ParseCBOR(bin, ParseOptions{})
+		if err == nil {
+			t.Errorf("No error for %s\n", input)
+		}
+	}
+}
+
+func TestCompareCBOR(t *testing.T) {
+	type CompareCBORTest struct {
+		Name     string
+		A        string
+		B        string
+		Expected bool
+	}
+
+	tests := []CompareCBORTest{
+		{
+			Name:     "same as msgpack",
+			A:        "a26161016162820203",
+			B:        "msgpack:gqFhAaFikgID", // {"a": 1, "b": [2, 3]}
+			Expected: true,
+		},
+		{
+			Name:     "indefinite and definite",
+			A:        "a26161016162820203",
+			B:        "bf61610161629f0203ffff",
+			Expected: true,
+		},
+		{
+			Name:     "different value",
+			A:        "a26161016162820203",
+			B:        "a26161016162820204",
+			Expected: false,
+		},
+		{
+			Name:     "same tags",
+			A:        "c11a514b67b0",
+			B:        "c11a514b67b0",
+			Expected: true,
+		},
+		{
+			Name:     "different tag numbers",
+			A:        "c01a514b67b0",
+			B:        "c11a514b67b0",
+			Expected: false,
+		},
+		{
+			Name:     "min negative and negative bignum",
+			A:        "3bffffffffffffffff", // -2^64
+			B:        "c348ffffffffffffffff",
+			Expected: true,
+		},
+		{
+			Name:     "min negative and the next integer",
+			A:        "3bffffffffffffffff", // -2^64
+			B:        "3bfffffffffffffffe", // -2^64+1
+			Expected: false,
+		},
+		{
+			Name:     "min negative and float",
+			A:        "3bffffffffffffffff", // -2^64
+			B:        "fbc3f0000000000000", // -2^64 as a double
+			Expected: false,
+		},
+		{
+			Name:     "tag and extension",
+			A:        "c101",
+			B:        "msgpack:1AEB", // ext(1, base64(AQ==))
+			Expected: false,
+		},
+	}
+
+	parse := func(t *testing.T, input string) []MsgpObject {
+		var objects []MsgpObject
+		var err error
+		if len(input) > len("msgpack:") && input[:len("msgpack:")] == "msgpack:" {
+			bin, _ := base64.StdEncoding.DecodeString(input[len("msgpack:"):])
+			objects, err = ParseAll(bin, ParseOptions{})
+		} else {
+			bin, _ := hex.DecodeString(input)
+			objects, err = ParseCBOR(bin, ParseOptions{})
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		return objects
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			result := CompareParsed(parse(t, test.A), parse(t, test.B), CompareOptions{})
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
@@ -2,6 +2,8 @@ package msgpackdiff
 
 import (
 	"bytes"
+	"encoding/base64"
+	"fmt"
 	"io"
 	"math"
 	"time"
@@ -21,7 +23,7 @@ type CompareResult struct {
 // PrintReport prints a difference report of the CompareResult object to the io.Writer w.
 func (result CompareResult) PrintReport(w io.Writer, context int) {
 	if !result.Reporter.Brief && !result.Equal {
-		result.Objects[0].PrintDiff(w, context, result.Reporter.Differences, 0, false, true)
+		result.Objects[0].printDiff(w, context, result.Reporter.Differences, 0, false, true, result.Reporter.ShowOffsets)
 	}
 }
 
@@ -34,46 +36,125 @@ type CompareOptions struct {
 	IgnoreEmpty bool
 	// Ignores ordering of object keys for comparison when true.
 	IgnoreOrder bool
-	// Compares all numerical values regardless of their type when true. Some precision may be lost.
+	// Compares all numerical values regardless of their type when true. Values of different types
+	// are equal if they represent exactly the same value.
 	FlexibleTypes bool
+	// Treats timestamps that represent the same instant as different if they are encoded with
+	// different formats when true, such as the 32 and 64 bit forms of the timestamp extension, or
+	// with different extension types, such as the msgp time extension and the timestamp extension.
+	StrictTimestamps bool
+	// Treats objects that are encoded with different formats as different when true, even if
+	// their values are equal. For example, 1 encoded as a fixint and 1 encoded as a uint64 are
+	// different, as are the str8 and str32 encodings of the same string. This also applies to the
+	// encodings of map keys, and to times encoded with the msgp time extension and the timestamp
+	// extension. Numbers of different kinds, such as an integer and a float, are only reported as
+	// encoded differently with FlexibleTypes. The format of a map or array is only reported if its
+	// contents are equal, since otherwise the differences in its contents are reported instead.
+	StrictEncoding bool
+	// Treats a string as equal to binary data when true if the string is the standard, padded
+	// base64 encoding of the data. This is useful to compare JSON, which encodes binary data this way, to
+	// MessagePack.
+	Base64Binary bool
+	// Treats a string as equal to binary data when true if they contain the same bytes, as when
+	// one encoder writes byte fields as str and another as bin. They are counted in
+	// Reporter.BinaryStrings. With StrictEncoding, they are still reported as encoded with
+	// different formats.
+	BinaryStrings bool
+	// Matches the elements of the arrays at certain paths by their keys instead of their
+	// positions. Elements with the same key are compared with each other, and elements that are
+	// in a different order are reported as moves, unless IgnoreOrder is true. If the elements of
+	// an array do not all have unique keys, they are aligned by their contents as usual.
+	ArrayKeys []ArrayKey
+	// Compares every array as a multiset when true, so that the order of their elements does not
+	// matter. Only the elements that are on one side and not the other are reported. Arrays with
+	// ArrayKeys are still matched by their keys.
+	UnorderedArrays bool
+	// Compares the arrays at these paths from each top-level object as multisets, like
+	// UnorderedArrays. The paths may contain wildcards.
+	UnorderedPaths []Path
+	// Leaves the objects at these paths from each top-level object out of the comparison, such as
+	// fields that change every time. The paths may contain wildcards: * matches exactly one key,
+	// so *.sig only matches sig fields one level down, while ** matches any number of elements, so
+	// **.sig matches sig fields at any depth. The number of objects that are left out is counted
+	// in Reporter.Ignored.
+	IgnorePaths []Path
+	// How much floating-point and complex numbers may differ while still being equal. With
+	// FlexibleTypes, this also applies to numbers of different types, if one of them is
+	// floating-point or complex.
+	Tolerance Tolerance
+	// Policies for the objects at certain paths, which override the other options for those
+	// objects and their descendants. See Rule.
+	Rules Rules
+	// How floating-point numbers and the parts of complex numbers are compared for equality. The
+	// default, FloatEqualityIEEE, compares them like the == operator does.
+	FloatEquality FloatEquality
+	// Annotates each difference in the report with the offsets of its objects when true.
+	ShowOffsets bool
+	// The limits used to parse the objects.
+	ParseOptions ParseOptions
+}
+
+// usesPaths returns true if the comparison depends on the paths to the objects being compared.
+func (options CompareOptions) usesPaths() bool {
+	return len(options.ArrayKeys) != 0 || options.UnorderedArrays || len(options.UnorderedPaths) != 0 ||
+		len(options.IgnorePaths) != 0 || len(options.Rules) != 0
+}
+
+// ignoresPaths returns true if objects at some paths are left out of the comparison.
+func (options CompareOptions) ignoresPaths() bool {
+	if len(options.IgnorePaths) != 0 {
+		return true
+	}
+	for _, rule := range options.Rules {
+		if rule.Ignore {
+			return true
+		}
+	}
+	return false
+}
+
+// skipsObjects returns true if objects that are only on one side may not be differences, so that
+// containers of different lengths may still be equal.
+func (options CompareOptions) skipsObjects() bool {
+	return options.IgnoreEmpty || options.ignoresPaths() || len(options.Rules) != 0
 }
 
 // Compare checks two MessagePack objects for equality. The first return value will be true if and
 // only if the objects a and b are considered equivalent. If the second return value is a non-nil
 // error, then the comparison could not be completed and the first return value should be ignored.
+// If a or b could not be parsed, the error wraps a *ParseError that describes where.
 func Compare(a []byte, b []byte, options CompareOptions) (result CompareResult, err error) {
-	result.Reporter.Brief = options.Brief
-
-	objectsA := []MsgpObject{}
-
-	for len(a) != 0 {
-		var object MsgpObject
-		object, a, err = Parse(a)
-		if err != nil {
-			return
-		}
-		objectsA = append(objectsA, object)
+	objectsA, err := ParseAll(a, options.ParseOptions)
+	if err != nil {
+		err = fmt.Errorf("Failed to parse first object: %w", err)
+		return
 	}
 
-	result.Objects[0] = MsgpObject{
-		msgp.ArrayType,
-		objectsA,
+	objectsB, err := ParseAll(b, options.ParseOptions)
+	if err != nil {
+		err = fmt.Errorf("Failed to parse second object: %w", err)
+		return
 	}
 
-	objectsB := []MsgpObject{}
+	result = CompareParsed(objectsA, objectsB, options)
+	return
+}
+
+// CompareParsed is like Compare, but compares objects that have already been parsed, such as the
+// objects returned by ParseAll or ParseJSON. Each of a and b holds every top-level object of its
+// side.
+func CompareParsed(a []MsgpObject, b []MsgpObject, options CompareOptions) (result CompareResult) {
+	result.Reporter.Brief = options.Brief
+	result.Reporter.ShowOffsets = options.ShowOffsets
 
-	for len(b) != 0 {
-		var object MsgpObject
-		object, b, err = Parse(b)
-		if err != nil {
-			return
+	for i, objects := range [2][]MsgpObject{a, b} {
+		result.Objects[i] = MsgpObject{
+			Type:  msgp.ArrayType,
+			Value: objects,
+		}
+		if len(objects) != 0 {
+			result.Objects[i].End = objects[len(objects)-1].End
 		}
-		objectsB = append(objectsB, object)
-	}
-
-	result.Objects[1] = MsgpObject{
-		msgp.ArrayType,
-		objectsB,
 	}
 
 	result.Equal = compareObjects(&result.Reporter, result.Objects[0], result.Objects[1], options)
@@ -81,6 +162,7 @@ func Compare(a []byte, b []byte, options CompareOptions) (result CompareResult,
 	return
 }
 
+// compareNumbers returns true if a and b are numbers of different types with exactly the same value.
 func compareNumbers(a MsgpObject, b MsgpObject) (equal bool) {
 	// make a have the smaller type so that the switch statement only has to check larger types for b
 	if a.Type > b.Type {
@@ -95,11 +177,11 @@ func compareNumbers(a MsgpObject, b MsgpObject) (equal bool) {
 	case a.Type == msgp.Float64Type && b.Type == msgp.IntType:
 		floatA := a.Value.(float64)
 		intB := b.Value.(int64)
-		equal = floatA == float64(intB)
+		equal = floatEqualsInt(floatA, intB)
 	case a.Type == msgp.Float64Type && b.Type == msgp.UintType:
 		floatA := a.Value.(float64)
 		intB := b.Value.(uint64)
-		equal = floatA == float64(intB)
+		equal = floatEqualsUint(floatA, intB)
 	case a.Type == msgp.Float64Type && b.Type == msgp.Complex64Type:
 		floatA := a.Value.(float64)
 		complexB := b.Value.(complex64)
@@ -111,11 +193,11 @@ func compareNumbers(a MsgpObject, b MsgpObject) (equal bool) {
 	case a.Type == msgp.Float32Type && b.Type == msgp.IntType:
 		floatA := a.Value.(float32)
 		intB := b.Value.(int64)
-		equal = floatA == float32(intB)
+		equal = floatEqualsInt(float64(floatA), intB)
 	case a.Type == msgp.Float32Type && b.Type == msgp.UintType:
 		floatA := a.Value.(float32)
 		intB := b.Value.(uint64)
-		equal = floatA == float32(intB)
+		equal = floatEqualsUint(float64(floatA), intB)
 	case a.Type == msgp.Float32Type && b.Type == msgp.Complex64Type:
 		floatA := a.Value.(float32)
 		complexB := b.Value.(complex64)
@@ -131,19 +213,19 @@ func compareNumbers(a MsgpObject, b MsgpObject) (equal bool) {
 	case a.Type == msgp.IntType && b.Type == msgp.Complex64Type:
 		intA := a.Value.(int64)
 		complexB := b.Value.(complex64)
-		equal = complex(float32(intA), 0) == complexB
+		equal = imag(complexB) == 0 && floatEqualsInt(float64(real(complexB)), intA)
 	case a.Type == msgp.IntType && b.Type == msgp.Complex128Type:
 		intA := a.Value.(int64)
 		complexB := b.Value.(complex128)
-		equal = complex(float64(intA), 0) == complexB
+		equal = imag(complexB) == 0 && floatEqualsInt(real(complexB), intA)
 	case a.Type == msgp.UintType && b.Type == msgp.Complex64Type:
 		intA := a.Value.(uint64)
 		complexB := b.Value.(complex64)
-		equal = complex(float32(intA), 0) == complexB
+		equal = imag(complexB) == 0 && floatEqualsUint(float64(real(complexB)), intA)
 	case a.Type == msgp.UintType && b.Type == msgp.Complex128Type:
 		intA := a.Value.(uint64)
 		complexB := b.Value.(complex128)
-		equal = complex(float64(intA), 0) == complexB
+		equal = imag(complexB) == 0 && floatEqualsUint(real(complexB), intA)
 	case a.Type == msgp.Complex64Type && b.Type == msgp.Complex128Type:
 		complexA := a.Value.(complex64)
 		complexB := b.Value.(complex128)
@@ -155,9 +237,60 @@ func compareNumbers(a MsgpObject, b MsgpObject) (equal bool) {
 	return
 }
 
+// floatEqualsInt returns true if f is exactly equal to i. Converting i to a float may round it, so
+// f is converted to an integer instead, which is exact when f is a whole number in the range of
+// int64.
+func floatEqualsInt(f float64, i int64) bool {
+	// -2^63 and 2^63 are exactly representable as floats, so the range checks are exact
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= -math.MinInt64 {
+		return false
+	}
+	return int64(f) == i
+}
+
+// floatEqualsUint is like floatEqualsInt, but for an unsigned integer.
+func floatEqualsUint(f float64, u uint64) bool {
+	// 2^64 is exactly representable as a float, so the range check is exact
+	if f != math.Trunc(f) || f < 0 || f >= 1<<64 {
+		return false
+	}
+	return uint64(f) == u
+}
+
 func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options CompareOptions) (equal bool) {
+	var path Path
+	if options.usesPaths() {
+		path = reporter.currentPath()
+		if options.ignoresPaths() && ignoredAt(path, options) {
+			reporter.Ignored++
+			equal = true
+			return
+		}
+		options = options.at(path)
+	}
+
 	if a.Type != b.Type {
-		if options.FlexibleTypes && compareNumbers(a, b) {
+		numbersEqual := compareNumbersWith(a, b, options.FloatEquality)
+		bytesEqual := options.BinaryStrings && compareStringBytes(a, b)
+		if options.StrictEncoding && (bytesEqual || (numbersEqual && (options.FlexibleTypes || sameNumberKind(a, b)))) {
+			// the values are equal, so the difference is in how they are encoded
+			reporter.LogFormatChange(a, b)
+			equal = false
+			return
+		}
+
+		if options.FlexibleTypes && (numbersEqual || numbersClose(a, b, options.Tolerance)) {
+			equal = true
+			return
+		}
+
+		if bytesEqual {
+			reporter.BinaryStrings++
+			equal = true
+			return
+		}
+
+		if options.Base64Binary && compareBase64(a, b) {
 			equal = true
 			return
 		}
@@ -180,9 +313,9 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 	case msgp.MapType:
 		mapA := a.Value.(MsgpMap)
 		mapB := b.Value.(MsgpMap)
-		reporter.EnterMap(a)
+		reporter.EnterMap(a, b)
 		defer reporter.LeaveMap()
-		if options.Brief && !options.IgnoreEmpty && len(mapA.Values) != len(mapB.Values) {
+		if options.Brief && !options.skipsObjects() && len(mapA.Values) != len(mapB.Values) {
 			equal = false
 		} else if options.IgnoreOrder {
 			equal = true
@@ -192,9 +325,12 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 				valueB, ok := mapB.Values[key]
 
 				reporter.SetKey(index, key)
+				if reporter.ignore(options) {
+					continue
+				}
 
 				if !ok {
-					if options.IgnoreEmpty && valueA.IsEmpty() {
+					if reporter.ignoreEmpty(valueA, options) {
 						continue
 					}
 
@@ -207,8 +343,9 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 					continue
 				}
 
+				keysEqual := !options.StrictEncoding || compareKeyFormats(reporter, mapA, mapB, key)
 				valuesEqual := compareObjects(reporter, valueA, valueB, options)
-				if !valuesEqual {
+				if !keysEqual || !valuesEqual {
 					equal = false
 					if options.Brief {
 						break
@@ -224,11 +361,10 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 					continue
 				}
 
-				if options.IgnoreEmpty && valueB.IsEmpty() {
+				reporter.SetKey(len(mapA.Order), key)
+				if reporter.ignoreEmpty(valueB, options) || reporter.ignore(options) {
 					continue
 				}
-
-				reporter.SetKey(len(mapA.Order), key)
 				reporter.LogAddition(valueB)
 
 				equal = false
@@ -238,8 +374,8 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 				}
 			}
 		} else {
-			lcs := lcsStrings(mapA.Order, mapB.Order)
-			if options.Brief && !options.IgnoreEmpty && (len(lcs) != len(mapA.Order) || len(lcs) != len(mapB.Order)) {
+			lcs := lcsKeys(mapA.Order, mapB.Order)
+			if options.Brief && !options.skipsObjects() && (len(lcs) != len(mapA.Order) || len(lcs) != len(mapB.Order)) {
 				equal = false
 			} else {
 				equal = true
@@ -256,8 +392,8 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 							break
 						}
 
-						if !options.IgnoreEmpty || !mapA.Values[keyA].IsEmpty() {
-							reporter.SetKey(indexA, keyA)
+						reporter.SetKey(indexA, keyA)
+						if !reporter.ignoreEmpty(mapA.Values[keyA], options) && !reporter.ignore(options) {
 							reporter.LogDeletion(mapA.Values[keyA])
 
 							equal = false
@@ -276,8 +412,8 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 							break
 						}
 
-						if !options.IgnoreEmpty || !mapB.Values[keyB].IsEmpty() {
-							reporter.SetKey(indexA-1, keyB)
+						reporter.SetKey(indexA-1, keyB)
+						if !reporter.ignoreEmpty(mapB.Values[keyB], options) && !reporter.ignore(options) {
 							reporter.LogAddition(mapB.Values[keyB])
 
 							equal = false
@@ -292,9 +428,13 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 					valueB := mapB.Values[keyLCS]
 
 					reporter.SetKey(indexA-1, keyLCS)
+					if reporter.ignore(options) {
+						continue
+					}
 
+					keysEqual := !options.StrictEncoding || compareKeyFormats(reporter, mapA, mapB, keyLCS)
 					valuesEqual := compareObjects(reporter, valueA, valueB, options)
-					if !valuesEqual {
+					if !keysEqual || !valuesEqual {
 						equal = false
 						if options.Brief {
 							break
@@ -307,8 +447,8 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 					for ; indexA < len(mapA.Order); indexA++ {
 						keyA := mapA.Order[indexA]
 
-						if !options.IgnoreEmpty || !mapA.Values[keyA].IsEmpty() {
-							reporter.SetKey(indexA, keyA)
+						reporter.SetKey(indexA, keyA)
+						if !reporter.ignoreEmpty(mapA.Values[keyA], options) && !reporter.ignore(options) {
 							reporter.LogDeletion(mapA.Values[keyA])
 
 							equal = false
@@ -318,8 +458,8 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 					for ; indexB < len(mapB.Order); indexB++ {
 						keyB := mapB.Order[indexB]
 
-						if !options.IgnoreEmpty || !mapB.Values[keyB].IsEmpty() {
-							reporter.SetKey(indexA, keyB)
+						reporter.SetKey(indexA, keyB)
+						if !reporter.ignoreEmpty(mapB.Values[keyB], options) && !reporter.ignore(options) {
 							reporter.LogAddition(mapB.Values[keyB])
 
 							equal = false
@@ -331,13 +471,28 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 	case msgp.ArrayType:
 		arrayA := a.Value.([]MsgpObject)
 		arrayB := b.Value.([]MsgpObject)
-		reporter.EnterArray(a)
+		reporter.EnterArray(a, b)
 		defer reporter.LeaveArray()
-		if options.Brief && len(arrayA) != len(arrayB) {
+
+		// fall back to aligning the elements by their contents if they do not all have unique keys
+		key, keyed := arrayKeyAt(path, options)
+		var keysA, keysB []MapKey
+		if keyed {
+			keysA, keyed = elementKeys(arrayA, key)
+		}
+		if keyed {
+			keysB, keyed = elementKeys(arrayB, key)
+		}
+
+		if keyed {
+			equal = compareKeyedArrays(reporter, arrayA, arrayB, keysA, keysB, options)
+		} else if unorderedAt(path, options) {
+			equal = compareMultisets(reporter, arrayA, arrayB, path, options)
+		} else if options.Brief && !options.ignoresPaths() && len(arrayA) != len(arrayB) {
 			equal = false
 		} else {
 			equal = true
-			lcs := lcsObjects(arrayA, arrayB, options)
+			lcs := lcsObjects(arrayA, arrayB, path, options)
 
 			indexA := 0
 			indexB := 0
@@ -349,8 +504,8 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 
 				for ; indexA < lcsIndexA; indexA++ {
 					value := arrayA[indexA]
-					if !options.IgnoreEmpty || !value.IsEmpty() {
-						reporter.SetIndex(indexA)
+					reporter.SetIndex(indexA)
+					if !reporter.ignoreEmpty(value, options) && !reporter.ignore(options) {
 						reporter.LogDeletion(value)
 						equal = false
 						deleted = true
@@ -369,12 +524,14 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 				}
 				for ; indexB < lcsIndexB; indexB++ {
 					value := arrayB[indexB]
-					if !options.IgnoreEmpty || !value.IsEmpty() {
+					if !reporter.ignoreEmptyElementB(value, indexB, options) && !reporter.ignoreElementB(indexB, options) {
 						reporter.LogAddition(value)
 						equal = false
 					}
 				}
 				indexB++
+				reporter.Ignored += member.ignored
+				reporter.BinaryStrings += member.binaryStrings
 
 				if options.Brief && !equal {
 					break
@@ -396,8 +553,8 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 				for ; indexA < len(arrayA); indexA++ {
 					value := arrayA[indexA]
 
-					if !options.IgnoreEmpty || !value.IsEmpty() {
-						reporter.SetIndex(indexA)
+					reporter.SetIndex(indexA)
+					if !reporter.ignoreEmpty(value, options) && !reporter.ignore(options) {
 						reporter.LogDeletion(value)
 
 						equal = false
@@ -407,8 +564,8 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 				for ; indexB < len(arrayB); indexB++ {
 					value := arrayB[indexB]
 
-					if !options.IgnoreEmpty || !value.IsEmpty() {
-						reporter.SetIndex(indexA)
+					reporter.SetIndex(indexA)
+					if !reporter.ignoreEmptyElementB(value, indexB, options) && !reporter.ignoreElementB(indexB, options) {
 						reporter.LogAddition(value)
 
 						equal = false
@@ -419,11 +576,11 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 	case msgp.Float32Type:
 		floatA := a.Value.(float32)
 		floatB := b.Value.(float32)
-		equal = floatA == floatB
+		equal = options.FloatEquality.equal32(floatA, floatB) || options.Tolerance.within(float64(floatA), float64(floatB), true)
 	case msgp.Float64Type:
 		floatA := a.Value.(float64)
 		floatB := b.Value.(float64)
-		equal = floatA == floatB
+		equal = options.FloatEquality.equal64(floatA, floatB) || options.Tolerance.within(floatA, floatB, false)
 	case msgp.BoolType:
 		boolA := a.Value.(bool)
 		boolB := b.Value.(bool)
@@ -441,34 +598,134 @@ func compareObjects(reporter *Reporter, a MsgpObject, b MsgpObject, options Comp
 	case msgp.Complex64Type:
 		complexA := a.Value.(complex64)
 		complexB := b.Value.(complex64)
-		equal = complexA == complexB
+		equal = options.FloatEquality.equalComplex64(complexA, complexB) || numbersClose(a, b, options.Tolerance)
 	case msgp.Complex128Type:
 		complexA := a.Value.(complex128)
 		complexB := b.Value.(complex128)
-		equal = complexA == complexB
+		equal = options.FloatEquality.equalComplex128(complexA, complexB) || numbersClose(a, b, options.Tolerance)
 	case msgp.TimeType:
 		timeA := a.Value.(time.Time)
 		timeB := b.Value.(time.Time)
 		equal = timeA.Equal(timeB)
+		// the msgp time extension and the 96 bit timestamp are both ext8, but differ in their type
+		sameEncoding := a.Format == b.Format && a.TimeExtension == b.TimeExtension
+		if equal && options.StrictTimestamps && !options.StrictEncoding && !sameEncoding {
+			reporter.LogFormatChange(a, b)
+			equal = false
+			return
+		}
+	case msgp.ExtensionType:
+		extA, isExtA := a.Value.(Extension)
+		extB, isExtB := b.Value.(Extension)
+		tagA, isTagA := a.Value.(Tag)
+		tagB, isTagB := b.Value.(Tag)
+		switch {
+		case isExtA && isExtB:
+			equal = extA.Type == extB.Type && bytes.Equal(extA.Data, extB.Data)
+			if !equal && extA.Type == extB.Type && extA.Decoded != nil && extB.Decoded != nil {
+				// the decoded values are compared as a whole, since the report cannot descend into them
+				decodedReporter := Reporter{Brief: true}
+				equal = compareObjects(&decodedReporter, *extA.Decoded, *extB.Decoded, options)
+			}
+		case isTagA && isTagB:
+			// like decoded extensions, the contents are compared as a whole
+			contentReporter := Reporter{Brief: true}
+			equal = tagA.Number == tagB.Number && compareObjects(&contentReporter, tagA.Content, tagB.Content, options)
+		default:
+			// an extension is never equal to a tag
+			equal = false
+		}
 	}
 
 	if !equal && a.Type != msgp.MapType && a.Type != msgp.ArrayType {
 		reporter.LogChange(a, b)
 	}
 
+	if equal && options.StrictEncoding && !sameFormat(a, b) {
+		reporter.LogFormatChange(a, b)
+		equal = false
+	}
+
 	return
 }
 
-// lcsStrings returns a solution to the longest subsequence problem for string slices a and b.
+// compareBase64 returns true if one of a and b is binary data and the other is a string of the
+// data encoded with standard base64. Padding is required and unused bits must be zero, so that
+// each binary value has only one matching string.
+func compareBase64(a MsgpObject, b MsgpObject) bool {
+	if a.Type == msgp.BinType {
+		a, b = b, a
+	}
+	if a.Type != msgp.StrType || b.Type != msgp.BinType {
+		return false
+	}
+	decoded, err := base64.StdEncoding.Strict().DecodeString(a.Value.(string))
+	return err == nil && bytes.Equal(decoded, b.Value.([]byte))
+}
+
+// compareStringBytes returns true if one of a and b is a string and the other is binary data with
+// the same bytes.
+func compareStringBytes(a MsgpObject, b MsgpObject) bool {
+	if a.Type == msgp.BinType {
+		a, b = b, a
+	}
+	if a.Type != msgp.StrType || b.Type != msgp.BinType {
+		return false
+	}
+	return a.Value.(string) == string(b.Value.([]byte))
+}
+
+// sameNumberKind returns true if a and b are both integers, both floats or both complex numbers,
+// which are the numbers that differ only in their encoding when their values are equal.
+func sameNumberKind(a MsgpObject, b MsgpObject) bool {
+	return numberKind(a.Type) != 0 && numberKind(a.Type) == numberKind(b.Type)
+}
+
+// numberKind returns 1 for integer types, 2 for float types, 3 for complex types and 0 for the
+// types that are not numbers.
+func numberKind(t msgp.Type) int {
+	switch t {
+	case msgp.IntType, msgp.UintType:
+		return 1
+	case msgp.Float32Type, msgp.Float64Type:
+		return 2
+	case msgp.Complex64Type, msgp.Complex128Type:
+		return 3
+	}
+	return 0
+}
+
+// sameFormat returns false if a and b are both known to be encoded with different formats. Times
+// are also encoded differently if they have different extension types.
+func sameFormat(a MsgpObject, b MsgpObject) bool {
+	if a.Format == FormatUnknown || b.Format == FormatUnknown {
+		return true
+	}
+	return a.Format == b.Format && a.TimeExtension == b.TimeExtension
+}
+
+// compareKeyFormats checks if key is encoded with the same format in mapA and mapB, and reports a
+// difference if it is not.
+func compareKeyFormats(reporter *Reporter, mapA MsgpMap, mapB MsgpMap, key MapKey) bool {
+	keyA, okA := mapA.Keys[key]
+	keyB, okB := mapB.Keys[key]
+	if !okA || !okB || sameFormat(keyA, keyB) {
+		return true
+	}
+	reporter.LogKeyFormatChange(keyA, keyB)
+	return false
+}
+
+// lcsKeys returns a solution to the longest subsequence problem for MapKey slices a and b.
 // Based on https://en.wikipedia.org/wiki/Longest_common_subsequence_problem#Solution_for_two_sequences
-func lcsStrings(a []string, b []string) []string {
+func lcsKeys(a []MapKey, b []MapKey) []MapKey {
 	// make b the smaller slice
 	if len(a) < len(b) {
 		a, b = b, a
 	}
 
-	prevRow := make([][]string, len(b)+1)
-	currentRow := make([][]string, len(b)+1)
+	prevRow := make([][]MapKey, len(b)+1)
+	currentRow := make([][]MapKey, len(b)+1)
 
 	for _, itemA := range a {
 		prevRow, currentRow = currentRow, prevRow
@@ -491,33 +748,71 @@ func lcsStrings(a []string, b []string) []string {
 	return currentRow[len(b)]
 }
 
+// onlyFormatChanges returns true if differences is not empty and contains only differences in the
+// formats of objects.
+func onlyFormatChanges(differences []Difference) bool {
+	for _, diff := range differences {
+		if !diff.ShowFormat {
+			return false
+		}
+	}
+	return len(differences) != 0
+}
+
 type lcsMember struct {
 	indexA int
 	indexB int
 	diffs  []Difference
+	// the number of ignored objects in the elements
+	ignored int
+	// the number of strings in the elements that were equal to binary data with the same bytes
+	binaryStrings int
 }
 
 // lcsObjects returns a solution to the longest subsequence problem for MsgpObject slices a and b.
 // Based on https://en.wikipedia.org/wiki/Longest_common_subsequence_problem#Solution_for_two_sequences
-func lcsObjects(a []MsgpObject, b []MsgpObject, options CompareOptions) []lcsMember {
+// The path is the path to the arrays, which is only needed to find their descendants' ArrayKeys.
+func lcsObjects(a []MsgpObject, b []MsgpObject, path Path, options CompareOptions) []lcsMember {
 	prevRow := make([][]lcsMember, len(b)+1)
 	currentRow := make([][]lcsMember, len(b)+1)
 	differences := make([][]Difference, len(b))
+	ignored := make([]int, len(b))
+	binaryStrings := make([]int, len(b))
 
 	for indexA, itemA := range a {
 		prevRow, currentRow = currentRow, prevRow
 
+		var itemPath Path
+		if options.usesPaths() {
+			itemPath = append(path[:len(path):len(path)], IndexElement(indexA))
+		}
+		ignoredItem := options.ignoresPaths() && ignoredAt(itemPath, options)
+		// the rules for the item, which compareObjects also applies
+		itemOptions := options.at(itemPath)
+
 		minDiffs := math.MaxInt32
 		for indexB, itemB := range b {
 			reporter := Reporter{
 				Brief: options.Brief,
 				// set Differences to an empty slice since we use nil as a special value below
 				Differences: []Difference{},
+				prefix:      itemPath,
 			}
-			if itemA.Type != itemB.Type {
-				// items are different types so they can't be equal, don't even compare them
-				if options.FlexibleTypes && compareNumbers(itemA, itemB) {
-					// unless flexible types is enabled and the items are numbers
+			numbersEqual := itemA.Type != itemB.Type && compareNumbersWith(itemA, itemB, options.FloatEquality)
+			bytesEqual := itemA.Type != itemB.Type && options.BinaryStrings && compareStringBytes(itemA, itemB)
+			ignored[indexB] = 0
+			binaryStrings[indexB] = 0
+			formatsDiffer := bytesEqual || (numbersEqual && (itemOptions.FlexibleTypes || sameNumberKind(itemA, itemB)))
+			if itemA.Type != itemB.Type && !(options.StrictEncoding && formatsDiffer) && !ignoredItem {
+				// items are different types so they can't be equal, don't even compare them, unless
+				// strict encoding is enabled and they are equal numbers or bytes that differ in format
+				flexibleEqual := numbersEqual || numbersClose(itemA, itemB, itemOptions.Tolerance)
+				if (itemOptions.FlexibleTypes && flexibleEqual) || bytesEqual || (options.Base64Binary && compareBase64(itemA, itemB)) {
+					// unless flexible types is enabled and the items are numbers, or the items are
+					// a string and binary data with the same bytes or its base64 encoding
+					if bytesEqual {
+						binaryStrings[indexB] = 1
+					}
 					differences[indexB] = []Difference{}
 					minDiffs = 0
 					continue
@@ -527,10 +822,12 @@ func lcsObjects(a []MsgpObject, b []MsgpObject, options CompareOptions) []lcsMem
 			}
 			isContainer := itemA.Type == msgp.ArrayType || itemA.Type == msgp.MapType
 			equal := compareObjects(&reporter, itemA, itemB, options)
-			if options.Brief || !isContainer {
+			ignored[indexB] = reporter.Ignored
+			binaryStrings[indexB] = reporter.BinaryStrings
+			if options.Brief || (!isContainer && !onlyFormatChanges(reporter.Differences)) {
 				// if brief is enabled, then the diff count is meaningless
 				// simiarly, if the items aren't containers but are different, ignore the diffs and
-				// just mark them as different
+				// just mark them as different, unless they only differ in their formats
 				if equal {
 					differences[indexB] = []Difference{}
 					minDiffs = 0
@@ -552,9 +849,11 @@ func lcsObjects(a []MsgpObject, b []MsgpObject, options CompareOptions) []lcsMem
 			// if len(differences[indexB]) <= minDiffs, then the items are relatively equal
 			if differences[indexB] != nil && len(differences[indexB]) <= minDiffs {
 				member := lcsMember{
-					indexA: indexA,
-					indexB: indexB,
-					diffs:  differences[indexB],
+					indexA:        indexA,
+					indexB:        indexB,
+					diffs:         differences[indexB],
+					ignored:       ignored[indexB],
+					binaryStrings: binaryStrings[indexB],
 				}
 				currentRow[indexB+1] = append(prevRow[indexB], member)
 			} else {
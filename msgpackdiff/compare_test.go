@@ -2,6 +2,7 @@ package msgpackdiff
 
 import (
 	"encoding/base64"
+	"math"
 	"testing"
 
 	"github.com/algorand/msgp/msgp"
@@ -221,6 +222,36 @@ func TestCompareDefault(t *testing.T) {
 			SecondObject: "xAV0ZXN0Mg==", // base64(dGVzdDI=)
 			Expected:     false,
 		},
+		{
+			Name:         "int keys",
+			FirstObject:  "ggGhYQKhYg==", // {1: "a", 2: "b"}
+			SecondObject: "ggGhYQKhYg==", // {1: "a", 2: "b"}
+			Expected:     true,
+		},
+		{
+			Name:         "int and string keys",
+			FirstObject:  "ggGhYQKhYg==", // {1: "a", 2: "b"}
+			SecondObject: "ggGhYaEyYQ==", // {1: "a", "2": "a"}
+			Expected:     false,
+		},
+		{
+			Name:         "extensions",
+			FirstObject:  "1CoB", // ext(42, base64(AQ==))
+			SecondObject: "1CoB", // ext(42, base64(AQ==))
+			Expected:     true,
+		},
+		{
+			Name:         "extensions with different data",
+			FirstObject:  "1CoB", // ext(42, base64(AQ==))
+			SecondObject: "1CoC", // ext(42, base64(Ag==))
+			Expected:     false,
+		},
+		{
+			Name:         "extensions with different types",
+			FirstObject:  "1CoB", // ext(42, base64(AQ==))
+			SecondObject: "1CsB", // ext(43, base64(AQ==))
+			Expected:     false,
+		},
 	}
 
 	runTestsWithOptions(t, tests, CompareOptions{})
@@ -457,7 +488,494 @@ func TestCompareFlexibleTypes(t *testing.T) {
 	runTestsWithOptions(t, tests, CompareOptions{FlexibleTypes: true})
 }
 
-func TestLCSStrings(t *testing.T) {
+func TestCompareBinaryStrings(t *testing.T) {
+	tests := []CompareTest{
+		{
+			Name:         "string and binary",
+			FirstObject:  "gaFhomhp",     // {"a": "hi"}
+			SecondObject: "gaFhxAJoaQ==", // {"a": bin("hi")}
+			Expected:     true,
+		},
+		{
+			Name:         "string and different binary",
+			FirstObject:  "gaFhomhp",     // {"a": "hi"}
+			SecondObject: "gaFhxAJobw==", // {"a": bin("ho")}
+			Expected:     false,
+		},
+		{
+			Name:         "string and binary in array",
+			FirstObject:  "kqJoaQE=", // ["hi", 1]
+			SecondObject: "ksQCaGkB", // [bin("hi"), 1]
+			Expected:     true,
+		},
+		{
+			Name:         "string and binary in changed array",
+			FirstObject:  "kqJoaQE=", // ["hi", 1]
+			SecondObject: "ksQCaGkK", // [bin("hi"), 10]
+			Expected:     false,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{BinaryStrings: true})
+
+	tests = []CompareTest{
+		{
+			Name:         "string and binary without option",
+			FirstObject:  "gaFhomhp",     // {"a": "hi"}
+			SecondObject: "gaFhxAJoaQ==", // {"a": bin("hi")}
+			Expected:     false,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{})
+
+	tests = []CompareTest{
+		{
+			Name:         "string and binary with strict encoding",
+			FirstObject:  "gaFhomhp",     // {"a": "hi"}
+			SecondObject: "gaFhxAJoaQ==", // {"a": bin("hi")}
+			Expected:     false,
+		},
+		{
+			Name:         "string and binary in array with strict encoding",
+			FirstObject:  "kqJoaQE=", // ["hi", 1]
+			SecondObject: "ksQCaGkB", // [bin("hi"), 1]
+			Expected:     false,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{BinaryStrings: true, StrictEncoding: true})
+
+	tests = []CompareTest{
+		{
+			Name:         "string and binary in changed unordered array",
+			FirstObject:  "kqJoaQE=", // ["hi", 1]
+			SecondObject: "ksQCaGkK", // [bin("hi"), 10]
+			Expected:     false,
+		},
+		{
+			Name:         "reordered string and binary in unordered array",
+			FirstObject:  "kgGiaGk=", // [1, "hi"]
+			SecondObject: "ksQCaGkB", // [bin("hi"), 1]
+			Expected:     true,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{BinaryStrings: true, UnorderedArrays: true})
+	runTestsWithOptions(t, tests, CompareOptions{BinaryStrings: true, Base64Binary: true, UnorderedArrays: true})
+}
+
+func TestCompareBinaryStringsCount(t *testing.T) {
+	type CountTest struct {
+		Name         string
+		FirstObject  string
+		SecondObject string
+		Options      CompareOptions
+		Expected     bool
+		Count        int
+	}
+
+	tests := []CountTest{
+		{
+			Name:         "map value",
+			FirstObject:  "gaFhomhp",     // {"a": "hi"}
+			SecondObject: "gaFhxAJoaQ==", // {"a": bin("hi")}
+			Expected:     true,
+			Count:        1,
+		},
+		{
+			Name:         "array elements",
+			FirstObject:  "kqJoaaJoaQ==", // ["hi", "hi"]
+			SecondObject: "ksQCaGnEAmhp", // [bin("hi"), bin("hi")]
+			Expected:     true,
+			Count:        2,
+		},
+		{
+			Name:         "nested in array elements",
+			FirstObject:  "kYGhYaJoaQ==", // [{"a": "hi"}]
+			SecondObject: "kYGhYcQCaGk=", // [{"a": bin("hi")}]
+			Expected:     true,
+			Count:        1,
+		},
+		{
+			Name:         "changed array",
+			FirstObject:  "kqJoaQE=", // ["hi", 1]
+			SecondObject: "ksQCaGkK", // [bin("hi"), 10]
+			Expected:     false,
+			Count:        1,
+		},
+		{
+			Name:         "unordered array",
+			FirstObject:  "kgGiaGk=", // [1, "hi"]
+			SecondObject: "ksQCaGkB", // [bin("hi"), 1]
+			Options:      CompareOptions{UnorderedArrays: true},
+			Expected:     true,
+			Count:        1,
+		},
+		{
+			Name:         "same types",
+			FirstObject:  "gaFhomhp", // {"a": "hi"}
+			SecondObject: "gaFhomhp", // {"a": "hi"}
+			Expected:     true,
+			Count:        0,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			firstObject, _ := base64.StdEncoding.DecodeString(test.FirstObject)
+			secondObject, _ := base64.StdEncoding.DecodeString(test.SecondObject)
+
+			test.Options.BinaryStrings = true
+			result, err := Compare(firstObject, secondObject, test.Options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+
+			if result.Reporter.BinaryStrings != test.Count {
+				t.Fatalf("Wrong binary strings count: got %d, expected %d\n", result.Reporter.BinaryStrings, test.Count)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	type NumbersTest struct {
+		Name     string
+		A        MsgpObject
+		B        MsgpObject
+		Expected bool
+	}
+
+	tests := []NumbersTest{
+		{
+			Name:     "int and float32 at 2^24",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(1 << 24)},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: float32(1 << 24)},
+			Expected: true,
+		},
+		{
+			Name:     "int and float32 above 2^24",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(1<<24 + 1)},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: float32(1 << 24)},
+			Expected: false,
+		},
+		{
+			Name:     "uint and float32 above 2^24",
+			A:        MsgpObject{Type: msgp.UintType, Value: uint64(1<<24 + 1)},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: float32(1 << 24)},
+			Expected: false,
+		},
+		{
+			Name:     "int and complex64 above 2^24",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(1<<24 + 1)},
+			B:        MsgpObject{Type: msgp.Complex64Type, Value: complex64(1 << 24)},
+			Expected: false,
+		},
+		{
+			Name:     "int and float64 at 2^53",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(1 << 53)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(1 << 53)},
+			Expected: true,
+		},
+		{
+			Name:     "int and float64 above 2^53",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(1<<53 + 1)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(1 << 53)},
+			Expected: false,
+		},
+		{
+			Name:     "negative int and float64 below -2^53",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(-1<<53 - 1)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(-1 << 53)},
+			Expected: false,
+		},
+		{
+			Name:     "uint and complex128 above 2^53",
+			A:        MsgpObject{Type: msgp.UintType, Value: uint64(1<<53 + 1)},
+			B:        MsgpObject{Type: msgp.Complex128Type, Value: complex128(1 << 53)},
+			Expected: false,
+		},
+		{
+			Name:     "max int and float64 2^63",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(math.MaxInt64)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(1 << 63)},
+			Expected: false,
+		},
+		{
+			Name:     "min int and float64 -2^63",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(math.MinInt64)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(-1 << 63)},
+			Expected: true,
+		},
+		{
+			Name:     "uint and float64 at 2^63",
+			A:        MsgpObject{Type: msgp.UintType, Value: uint64(1 << 63)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(1 << 63)},
+			Expected: true,
+		},
+		{
+			Name:     "uint and float64 above 2^63",
+			A:        MsgpObject{Type: msgp.UintType, Value: uint64(1<<63 + 1)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(1 << 63)},
+			Expected: false,
+		},
+		{
+			Name:     "max uint and float64 2^64",
+			A:        MsgpObject{Type: msgp.UintType, Value: uint64(math.MaxUint64)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(1 << 64)},
+			Expected: false,
+		},
+		{
+			Name:     "negative int and uint",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(-1)},
+			B:        MsgpObject{Type: msgp.UintType, Value: uint64(math.MaxUint64)},
+			Expected: false,
+		},
+		{
+			Name:     "int and fraction",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(2)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: 2.5},
+			Expected: false,
+		},
+		{
+			Name:     "int and NaN",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(0)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: math.NaN()},
+			Expected: false,
+		},
+		{
+			Name:     "uint and infinity",
+			A:        MsgpObject{Type: msgp.UintType, Value: uint64(math.MaxUint64)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: math.Inf(1)},
+			Expected: false,
+		},
+		{
+			Name:     "int and negative zero",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(0)},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: float32(math.Copysign(0, -1))},
+			Expected: true,
+		},
+		{
+			Name:     "int and complex with imaginary part",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(1)},
+			B:        MsgpObject{Type: msgp.Complex128Type, Value: complex(1, 1)},
+			Expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			for _, swap := range []bool{false, true} {
+				a, b := test.A, test.B
+				if swap {
+					a, b = b, a
+				}
+				equal := compareNumbers(a, b)
+				if equal != test.Expected {
+					t.Fatalf("Wrong result with swap %v: got %v, expected %v\n", swap, equal, test.Expected)
+				}
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestCompareFloatKeys(t *testing.T) {
+	tests := []CompareTest{
+		{
+			Name:         "NaN keys",
+			FirstObject:  "gct/+AAAAAAAAAE=", // {NaN:1}
+			SecondObject: "gct/+AAAAAAAAAE=", // {NaN:1}
+			Expected:     true,
+		},
+		{
+			Name:         "NaN keys with different values",
+			FirstObject:  "gct/+AAAAAAAAAE=", // {NaN:1}
+			SecondObject: "gct/+AAAAAAAAAI=", // {NaN:2}
+			Expected:     false,
+		},
+		{
+			Name:         "zero and negative zero keys",
+			FirstObject:  "gcsAAAAAAAAAAAE=", // {0.0:1}
+			SecondObject: "gcuAAAAAAAAAAAE=", // {-0.0:1}
+			Expected:     false,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{})
+}
+
+func TestCompareTimestamps(t *testing.T) {
+	tests := []CompareTest{
+		{
+			Name:         "same format",
+			FirstObject:  "1v9fXhAA", // timestamp32(1600000000)
+			SecondObject: "1v9fXhAA", // timestamp32(1600000000)
+			Expected:     true,
+		},
+		{
+			Name:         "different formats",
+			FirstObject:  "1v9fXhAA",             // timestamp32(1600000000)
+			SecondObject: "xwz/AAAAAAAAAABfXhAA", // timestamp96(1600000000)
+			Expected:     true,
+		},
+		{
+			Name:         "different instants",
+			FirstObject:  "1/8AAAAAX14QAA==", // timestamp64(1600000000)
+			SecondObject: "1/8AAAfQX14QAA==", // timestamp64(1600000000.0000005)
+			Expected:     false,
+		},
+		{
+			Name:         "msgp time and timestamp96",
+			FirstObject:  "xwwFAAAAAF9eEAAAAAH0", // msgp time(1600000000.0000005)
+			SecondObject: "xwz/AAAB9AAAAABfXhAA", // timestamp96(1600000000.0000005)
+			Expected:     true,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{})
+}
+
+func TestCompareStrictTimestamps(t *testing.T) {
+	tests := []CompareTest{
+		{
+			Name:         "same format",
+			FirstObject:  "1v9fXhAA", // timestamp32(1600000000)
+			SecondObject: "1v9fXhAA", // timestamp32(1600000000)
+			Expected:     true,
+		},
+		{
+			Name:         "different formats",
+			FirstObject:  "1v9fXhAA",             // timestamp32(1600000000)
+			SecondObject: "xwz/AAAAAAAAAABfXhAA", // timestamp96(1600000000)
+			Expected:     false,
+		},
+		{
+			Name:         "msgp time and timestamp96",
+			FirstObject:  "xwwFAAAAAF9eEAAAAAH0", // msgp time(1600000000.0000005)
+			SecondObject: "xwz/AAAB9AAAAABfXhAA", // timestamp96(1600000000.0000005)
+			Expected:     false,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{StrictTimestamps: true})
+}
+
+func TestCompareStrictEncoding(t *testing.T) {
+	tests := []CompareTest{
+		{
+			Name:         "same format",
+			FirstObject:  "zAE=", // uint8(1)
+			SecondObject: "zAE=", // uint8(1)
+			Expected:     true,
+		},
+		{
+			Name:         "fixint and uint8",
+			FirstObject:  "AQ==", // fixint(1)
+			SecondObject: "zAE=", // uint8(1)
+			Expected:     false,
+		},
+		{
+			Name:         "uint8 and uint64",
+			FirstObject:  "zAE=",         // uint8(1)
+			SecondObject: "zwAAAAAAAAAB", // uint64(1)
+			Expected:     false,
+		},
+		{
+			Name:         "fixstr and str8",
+			FirstObject:  "oWE=", // fixstr("a")
+			SecondObject: "2QFh", // str8("a")
+			Expected:     false,
+		},
+		{
+			Name:         "fixarray and array16",
+			FirstObject:  "kA==", // fixarray([])
+			SecondObject: "3AAA", // array16([])
+			Expected:     false,
+		},
+		{
+			Name:         "key formats",
+			FirstObject:  "gaFhAQ==", // {fixstr("a"):1}
+			SecondObject: "gdkBYQE=", // {str8("a"):1}
+			Expected:     false,
+		},
+		{
+			Name:         "timestamp formats",
+			FirstObject:  "1v9fXhAA",             // timestamp32(1600000000)
+			SecondObject: "xwz/AAAAAAAAAABfXhAA", // timestamp96(1600000000)
+			Expected:     false,
+		},
+		{
+			Name:         "time extension types",
+			FirstObject:  "xwwFAAAAAF9eEAAAAAAA", // msgp time(1600000000)
+			SecondObject: "xwz/AAAAAAAAAABfXhAA", // timestamp96(1600000000)
+			Expected:     false,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{StrictEncoding: true})
+	runTestsWithOptions(t, tests, CompareOptions{StrictEncoding: true, FlexibleTypes: true})
+}
+
+func TestCompareRegisteredExtension(t *testing.T) {
+	RegisterExtension(42, func(data []byte) (MsgpObject, error) {
+		decoded, _, err := Parse(data)
+		return decoded, err
+	})
+	defer RegisterExtension(42, nil)
+
+	tests := []CompareTest{
+		{
+			Name:         "same encoding",
+			FirstObject:  "1iqBoWEB", // ext(42, {"a":1})
+			SecondObject: "1iqBoWEB", // ext(42, {"a":1})
+			Expected:     true,
+		},
+		{
+			Name:         "different encoding",
+			FirstObject:  "1iqBoWEB",     // ext(42, {"a":1})
+			SecondObject: "xwUqgaFh0AE=", // ext(42, {"a":int8(1)})
+			Expected:     true,
+		},
+	}
+
+	runTestsWithOptions(t, tests, CompareOptions{})
+}
+
+func TestCompareOffsets(t *testing.T) {
+	a, _ := GetBinary("gqFhkgHNASyhYqN4eXo=") // {"a":[1,300],"b":"xyz"}
+	b, _ := GetBinary("gqFhkgHNAS2hY4GheAE=") // {"a":[1,301],"c":{"x":1}}
+
+	result, err := Compare(a, b, CompareOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	expected := [][2]Span{
+		{{5, 8}, {3, 8}},    // 300 deleted from the array
+		{{3, 8}, {5, 8}},    // 301 added to the array
+		{{10, 14}, {0, 14}}, // "b" deleted from the map
+		{{0, 14}, {10, 14}}, // "c" added to the map
+	}
+
+	if len(result.Reporter.Differences) != len(expected) {
+		t.Fatalf("Wrong number of differences: got %d, expected %d\n", len(result.Reporter.Differences), len(expected))
+	}
+
+	for i, diff := range result.Reporter.Differences {
+		if diff.Offsets != expected[i] {
+			t.Errorf("Wrong offsets for difference %d: got %v, expected %v\n", i, diff.Offsets, expected[i])
+		}
+	}
+}
+
+func TestLCSKeys(t *testing.T) {
 	type LCSTest struct {
 		Name      string
 		FirstSeq  []string
@@ -540,7 +1058,15 @@ func TestLCSStrings(t *testing.T) {
 		},
 	}
 
-	slicesEqual := func(s1 []string, s2 []string) bool {
+	toKeys := func(strs []string) []MapKey {
+		keys := make([]MapKey, len(strs))
+		for i, str := range strs {
+			keys[i] = StringKey(str)
+		}
+		return keys
+	}
+
+	slicesEqual := func(s1 []MapKey, s2 []MapKey) bool {
 		if len(s1) != len(s2) {
 			return false
 		}
@@ -556,14 +1082,14 @@ func TestLCSStrings(t *testing.T) {
 
 	for _, test := range tests {
 		runTest := func(t *testing.T) {
-			result := lcsStrings(test.FirstSeq, test.SecondSeq)
-			resultFlipped := lcsStrings(test.SecondSeq, test.FirstSeq)
+			result := lcsKeys(toKeys(test.FirstSeq), toKeys(test.SecondSeq))
+			resultFlipped := lcsKeys(toKeys(test.SecondSeq), toKeys(test.FirstSeq))
 
 			if !slicesEqual(result, resultFlipped) {
 				t.Fatalf("Result differs based on order of arguments: got %v and %v\n", result, resultFlipped)
 			}
 
-			if !slicesEqual(result, test.Expected) {
+			if !slicesEqual(result, toKeys(test.Expected)) {
 				t.Fatalf("Wrong result: got %v, expected %v\n", result, test.Expected)
 			}
 		}
@@ -921,25 +1447,25 @@ func TestLCSObjects(t *testing.T) {
 				{
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"a"},
-						map[string]MsgpObject{
-							"a": {msgp.IntType, int64(1)},
+						Order: []MapKey{StringKey("a")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("a"): {Type: msgp.IntType, Value: int64(1)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"b"},
-						map[string]MsgpObject{
-							"b": {msgp.IntType, int64(2)},
+						Order: []MapKey{StringKey("b")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("b"): {Type: msgp.IntType, Value: int64(2)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"c"},
-						map[string]MsgpObject{
-							"c": {msgp.IntType, int64(3)},
+						Order: []MapKey{StringKey("c")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("c"): {Type: msgp.IntType, Value: int64(3)},
 						},
 					},
 				},
@@ -948,17 +1474,17 @@ func TestLCSObjects(t *testing.T) {
 				{
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"b"},
-						map[string]MsgpObject{
-							"b": {msgp.IntType, int64(2)},
+						Order: []MapKey{StringKey("b")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("b"): {Type: msgp.IntType, Value: int64(2)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"c"},
-						map[string]MsgpObject{
-							"c": {msgp.IntType, int64(3)},
+						Order: []MapKey{StringKey("c")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("c"): {Type: msgp.IntType, Value: int64(3)},
 						},
 					},
 				},
@@ -971,25 +1497,25 @@ func TestLCSObjects(t *testing.T) {
 				{
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"a"},
-						map[string]MsgpObject{
-							"a": {msgp.IntType, int64(1)},
+						Order: []MapKey{StringKey("a")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("a"): {Type: msgp.IntType, Value: int64(1)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"b"},
-						map[string]MsgpObject{
-							"b": {msgp.IntType, int64(2)},
+						Order: []MapKey{StringKey("b")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("b"): {Type: msgp.IntType, Value: int64(2)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"c"},
-						map[string]MsgpObject{
-							"c": {msgp.IntType, int64(3)},
+						Order: []MapKey{StringKey("c")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("c"): {Type: msgp.IntType, Value: int64(3)},
 						},
 					},
 				},
@@ -998,18 +1524,18 @@ func TestLCSObjects(t *testing.T) {
 				{
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"b"},
-						map[string]MsgpObject{
-							"b": {msgp.IntType, int64(2)},
+						Order: []MapKey{StringKey("b")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("b"): {Type: msgp.IntType, Value: int64(2)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"c", "empty"},
-						map[string]MsgpObject{
-							"c":     {msgp.IntType, int64(3)},
-							"empty": {msgp.NilType, nil},
+						Order: []MapKey{StringKey("c"), StringKey("empty")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("c"):     {Type: msgp.IntType, Value: int64(3)},
+							StringKey("empty"): {Type: msgp.NilType, Value: nil},
 						},
 					},
 				},
@@ -1023,25 +1549,25 @@ func TestLCSObjects(t *testing.T) {
 				{
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"a"},
-						map[string]MsgpObject{
-							"a": {msgp.IntType, int64(1)},
+						Order: []MapKey{StringKey("a")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("a"): {Type: msgp.IntType, Value: int64(1)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"b"},
-						map[string]MsgpObject{
-							"b": {msgp.Float32Type, float32(2)},
+						Order: []MapKey{StringKey("b")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("b"): {Type: msgp.Float32Type, Value: float32(2)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"c"},
-						map[string]MsgpObject{
-							"c": {msgp.IntType, int64(3)},
+						Order: []MapKey{StringKey("c")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("c"): {Type: msgp.IntType, Value: int64(3)},
 						},
 					},
 				},
@@ -1050,17 +1576,17 @@ func TestLCSObjects(t *testing.T) {
 				{
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"b"},
-						map[string]MsgpObject{
-							"b": {msgp.IntType, int64(2)},
+						Order: []MapKey{StringKey("b")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("b"): {Type: msgp.IntType, Value: int64(2)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"c"},
-						map[string]MsgpObject{
-							"c": {msgp.IntType, int64(3)},
+						Order: []MapKey{StringKey("c")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("c"): {Type: msgp.IntType, Value: int64(3)},
 						},
 					},
 				},
@@ -1074,26 +1600,26 @@ func TestLCSObjects(t *testing.T) {
 				{
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"a"},
-						map[string]MsgpObject{
-							"a": {msgp.Float32Type, float32(1)},
+						Order: []MapKey{StringKey("a")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("a"): {Type: msgp.Float32Type, Value: float32(1)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"b", "other"},
-						map[string]MsgpObject{
-							"b":     {msgp.IntType, int64(2)},
-							"other": {msgp.IntType, int64(17)},
+						Order: []MapKey{StringKey("b"), StringKey("other")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("b"):     {Type: msgp.IntType, Value: int64(2)},
+							StringKey("other"): {Type: msgp.IntType, Value: int64(17)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"c"},
-						map[string]MsgpObject{
-							"c": {msgp.IntType, int64(3)},
+						Order: []MapKey{StringKey("c")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("c"): {Type: msgp.IntType, Value: int64(3)},
 						},
 					},
 				},
@@ -1102,18 +1628,18 @@ func TestLCSObjects(t *testing.T) {
 				{
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"other", "b"},
-						map[string]MsgpObject{
-							"other": {msgp.IntType, int64(17)},
-							"b":     {msgp.IntType, int64(2)},
+						Order: []MapKey{StringKey("other"), StringKey("b")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("other"): {Type: msgp.IntType, Value: int64(17)},
+							StringKey("b"):     {Type: msgp.IntType, Value: int64(2)},
 						},
 					},
 				}, {
 					Type: msgp.MapType,
 					Value: MsgpMap{
-						[]string{"c"},
-						map[string]MsgpObject{
-							"c": {msgp.IntType, int64(3)},
+						Order: []MapKey{StringKey("c")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("c"): {Type: msgp.IntType, Value: int64(3)},
 						},
 					},
 				},
@@ -1163,7 +1689,7 @@ func TestLCSObjects(t *testing.T) {
 
 	for _, test := range tests {
 		runTest := func(t *testing.T) {
-			result := lcsObjects(test.FirstSeq, test.SecondSeq, test.Options)
+			result := lcsObjects(test.FirstSeq, test.SecondSeq, nil, test.Options)
 
 			actualIndices := [][2]int{}
 			for _, member := range result {
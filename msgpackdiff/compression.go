@@ -0,0 +1,130 @@
+package msgpackdiff
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Compression is a format that the data of an object may be compressed with.
+type Compression int
+
+// The compression formats of input data. CompressionAuto detects the format from the magic bytes
+// at the start of the data.
+const (
+	CompressionAuto Compression = iota
+	CompressionNone
+	CompressionGzip
+	CompressionZlib
+	CompressionBzip2
+)
+
+var compressionNames = map[Compression]string{
+	CompressionAuto:  "auto",
+	CompressionNone:  "none",
+	CompressionGzip:  "gzip",
+	CompressionZlib:  "zlib",
+	CompressionBzip2: "bzip2",
+}
+
+func (c Compression) String() string {
+	return compressionNames[c]
+}
+
+// ParseCompression returns the Compression with the given name, which is one of auto, none, gzip,
+// zlib or bzip2.
+func ParseCompression(name string) (Compression, error) {
+	for compression, compressionName := range compressionNames {
+		if name == compressionName {
+			return compression, nil
+		}
+	}
+	return CompressionAuto, fmt.Errorf("Unknown compression %q", name)
+}
+
+// Decompress returns a reader of the data in r decompressed with compression. If compression is
+// CompressionAuto, the format is detected from the first bytes of r, and data that does not start
+// with the magic bytes of a known format is read as is. Data that starts with the magic bytes is
+// checked by decompressing its first few kilobytes, or all of it if it is shorter, so that an error
+// is returned right away if it is not compressed after all. Uncompressed data that happens to start
+// with the magic bytes can be read as is with CompressionNone. The format that was used is also
+// returned, which is never CompressionAuto.
+func Decompress(r io.Reader, compression Compression) (io.Reader, Compression, error) {
+	buffered := bufio.NewReader(r)
+	if compression == CompressionAuto {
+		// a short read leaves fewer bytes, which detectCompression handles
+		header, _ := buffered.Peek(4)
+		compression = detectCompression(header)
+		if compression != CompressionNone {
+			if err := decompressPrefix(buffered, compression); err != nil {
+				return nil, compression, fmt.Errorf("Failed to decompress %s detected from the magic bytes: %w", compression, err)
+			}
+		}
+	}
+
+	var err error
+	var decompressed io.Reader
+	switch compression {
+	case CompressionNone:
+		decompressed = buffered
+	case CompressionGzip:
+		decompressed, err = gzip.NewReader(buffered)
+	case CompressionZlib:
+		decompressed, err = zlib.NewReader(buffered)
+	case CompressionBzip2:
+		decompressed = bzip2.NewReader(buffered)
+	default:
+		err = fmt.Errorf("Unknown compression %d", compression)
+	}
+	if err != nil {
+		return nil, compression, fmt.Errorf("Failed to decompress %s: %w", compression, err)
+	}
+	return decompressed, compression, nil
+}
+
+// decompressPrefix returns an error if the data buffered at the start of r is not the start of data
+// compressed with compression. If r has no more data than its buffer holds, all of the data must
+// decompress.
+func decompressPrefix(r *bufio.Reader, compression Compression) error {
+	prefix, err := r.Peek(r.Size())
+	complete := err != nil
+
+	decompressed, _, err := Decompress(bytes.NewReader(prefix), compression)
+	if err == nil {
+		_, err = io.Copy(ioutil.Discard, decompressed)
+	}
+	if !complete && errors.Is(err, io.ErrUnexpectedEOF) {
+		// the rest of the data is not buffered yet
+		return nil
+	}
+	return err
+}
+
+// detectCompression returns the compression format whose magic bytes are at the start of header,
+// or CompressionNone if there are none.
+func detectCompression(header []byte) Compression {
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return CompressionGzip
+	case bytes.HasPrefix(header, []byte("BZh")) && len(header) >= 4 && header[3] >= '1' && header[3] <= '9':
+		// the fourth byte is the block size
+		return CompressionBzip2
+	case len(header) >= 2 && isZlibHeader(header[0], header[1]):
+		return CompressionZlib
+	}
+	return CompressionNone
+}
+
+// isZlibHeader returns true if cmf and flg are the first two bytes of a zlib stream that uses
+// deflate with a 32K window and no preset dictionary, as described in RFC 1950. Other zlib streams
+// are not detected, since their first bytes are too likely to start text or MessagePack data.
+func isZlibHeader(cmf byte, flg byte) bool {
+	const presetDictionary = 0x20
+	return cmf == 0x78 && flg&presetDictionary == 0 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
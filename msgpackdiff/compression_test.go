@@ -0,0 +1,147 @@
+package msgpackdiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	type DecompressTest struct {
+		Name        string
+		Input       []byte
+		Compression Compression
+		Expected    []byte
+		Detected    Compression
+	}
+
+	algoTxn, err := ioutil.ReadFile("../test/algo_txn_binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gzipTxn, err := ioutil.ReadFile("../test/algo_txn_binary.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bzip2Txn, err := ioutil.ReadFile("../test/algo_txn_binary.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var zlibTxn bytes.Buffer
+	writer := zlib.NewWriter(&zlibTxn)
+	writer.Write(algoTxn)
+	writer.Close()
+
+	tests := []DecompressTest{
+		{
+			Name:     "gzip",
+			Input:    gzipTxn,
+			Expected: algoTxn,
+			Detected: CompressionGzip,
+		},
+		{
+			Name:     "zlib",
+			Input:    zlibTxn.Bytes(),
+			Expected: algoTxn,
+			Detected: CompressionZlib,
+		},
+		{
+			Name:     "bzip2",
+			Input:    bzip2Txn,
+			Expected: algoTxn,
+			Detected: CompressionBzip2,
+		},
+		{
+			Name:     "uncompressed",
+			Input:    algoTxn,
+			Expected: algoTxn,
+			Detected: CompressionNone,
+		},
+		{
+			Name:     "base64 like bzip2 magic",
+			Input:    []byte("BZhA"),
+			Expected: []byte("BZhA"),
+			Detected: CompressionNone,
+		},
+		{
+			Name:     "base64 like zlib magic",
+			Input:    []byte("xAP7"),
+			Expected: []byte("xAP7"),
+			Detected: CompressionNone,
+		},
+		{
+			Name:        "msgpack like zlib magic",
+			Input:       []byte{0x78, 0x9c, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01}, // 120 [1,1,1,1,1,1,1,1,1,1,1,1]
+			Compression: CompressionNone,
+			Expected:    []byte{0x78, 0x9c, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01},
+			Detected:    CompressionNone,
+		},
+		{
+			Name:     "short",
+			Input:    []byte{0x1f},
+			Expected: []byte{0x1f},
+			Detected: CompressionNone,
+		},
+		{
+			Name:        "disabled",
+			Input:       gzipTxn,
+			Compression: CompressionNone,
+			Expected:    gzipTxn,
+			Detected:    CompressionNone,
+		},
+		{
+			Name:        "forced",
+			Input:       zlibTxn.Bytes(),
+			Compression: CompressionZlib,
+			Expected:    algoTxn,
+			Detected:    CompressionZlib,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			r, detected, err := Decompress(bytes.NewReader(test.Input), test.Compression)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if detected != test.Detected {
+				t.Fatalf("Wrong compression: got %v, expected %v\n", detected, test.Detected)
+			}
+
+			result, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if !bytes.Equal(result, test.Expected) {
+				t.Fatalf("Invalid content: got %v, expected %v\n", result, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestDecompressErrors(t *testing.T) {
+	_, _, err := Decompress(strings.NewReader("not gzip"), CompressionGzip)
+	if err == nil {
+		t.Fatal("No error for forced gzip\n")
+	}
+
+	// data that only starts with the magic bytes is not read as is without CompressionNone
+	invalid := map[string][]byte{
+		"msgpack like zlib magic":              {0x78, 0x9c, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01}, // 120 [1,1,1,1,1,1,1,1,1,1,1,1]
+		"msgpack like a truncated zlib stream": {0x78, 0x01, 0x02},                                                                   // 120 1 2
+		"msgpack like gzip magic":              {0x1f, 0x8b, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c}, // 31 {1:2,...}
+	}
+	for name, data := range invalid {
+		if _, _, err := Decompress(bytes.NewReader(data), CompressionAuto); err == nil {
+			t.Errorf("No error for %s\n", name)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package msgpackdiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// EncodeOptions are the options used in a call to Encode.
+type EncodeOptions struct {
+	// Encodes objects canonically when true, as checked by CheckCanonical. The keys of maps are
+	// sorted, every object is encoded with the smallest possible format, and empty map values are
+	// omitted.
+	Canonical bool
+}
+
+// Encode appends the MessagePack encoding of object to b and returns the extended slice.
+//
+// Unless options.Canonical is true, objects are encoded with the format they were parsed with, so
+// the encoding of an object returned by Parse is identical to its input. Objects with an unknown
+// format are encoded with the smallest possible format. Times that were parsed from the time
+// extension of the msgp library are encoded with that extension again, and all other times are
+// encoded with the timestamp extension of the MessagePack specification.
+func Encode(b []byte, object MsgpObject, options EncodeOptions) ([]byte, error) {
+	format := object.Format
+	if options.Canonical || format == FormatUnknown {
+		format = minimalFormat(object)
+	}
+
+	var err error
+	switch object.Type {
+	case msgp.NilType:
+		b = append(b, 0xc0)
+	case msgp.BoolType:
+		b = msgp.AppendBool(b, object.Value.(bool))
+	case msgp.IntType, msgp.UintType:
+		b, err = appendInteger(b, format, object)
+	case msgp.Float32Type:
+		b = msgp.AppendFloat32(b, object.Value.(float32))
+	case msgp.Float64Type:
+		b = msgp.AppendFloat64(b, object.Value.(float64))
+	case msgp.Complex64Type:
+		b = msgp.AppendComplex64(b, object.Value.(complex64))
+	case msgp.Complex128Type:
+		b = msgp.AppendComplex128(b, object.Value.(complex128))
+	case msgp.StrType:
+		str := object.Value.(string)
+		b, err = appendHeader(b, format, len(str))
+		b = append(b, str...)
+	case msgp.BinType:
+		bin := object.Value.([]byte)
+		b, err = appendHeader(b, format, len(bin))
+		b = append(b, bin...)
+	case msgp.ArrayType:
+		valueArray := object.Value.([]MsgpObject)
+		b, err = appendHeader(b, format, len(valueArray))
+		for _, item := range valueArray {
+			if err != nil {
+				break
+			}
+			b, err = Encode(b, item, options)
+		}
+	case msgp.MapType:
+		b, err = appendMap(b, format, object.Value.(MsgpMap), options)
+	case msgp.ExtensionType:
+		ext, ok := object.Value.(Extension)
+		if !ok {
+			err = fmt.Errorf("Cannot encode CBOR tag %d", object.Value.(Tag).Number)
+			break
+		}
+		b, err = appendExtension(b, format, ext.Type, ext.Data)
+	case msgp.TimeType:
+		if object.TimeExtension == msgp.TimeExtension {
+			// the msgp library has a single form for its time extension
+			b = msgp.AppendTime(b, object.Value.(time.Time))
+			break
+		}
+		b, err = appendTimestamp(b, format, object.Value.(time.Time))
+	default:
+		err = fmt.Errorf("Cannot encode object of type %s", typeName(object.Type))
+	}
+	return b, err
+}
+
+// appendMap appends the encoding of a map with the given format to b.
+func appendMap(b []byte, format Format, valueMap MsgpMap, options EncodeOptions) ([]byte, error) {
+	order := valueMap.Order
+	if options.Canonical {
+		order = make([]MapKey, 0, len(valueMap.Order))
+		for _, key := range valueMap.Order {
+			if !valueMap.Values[key].IsEmpty() {
+				order = append(order, key)
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			rankI, rankJ := keyRank(order[i]), keyRank(order[j])
+			return rankI < rankJ || (rankI == rankJ && keyLess(order[i], order[j]))
+		})
+		// empty values may have been omitted
+		format = minimalFormat(MsgpObject{Type: msgp.MapType, Value: MsgpMap{Order: order}})
+	}
+
+	b, err := appendHeader(b, format, len(order))
+	for _, key := range order {
+		if err != nil {
+			break
+		}
+		keyObject, ok := valueMap.Keys[key]
+		if !ok {
+			keyObject, err = key.Object()
+			if err != nil {
+				break
+			}
+		}
+		b, err = Encode(b, keyObject, options)
+		if err != nil {
+			break
+		}
+		b, err = Encode(b, valueMap.Values[key], options)
+	}
+	return b, err
+}
+
+// keyRank groups the keys of a map when they are sorted canonically. Integers come before strings,
+// which come before keys of other types.
+func keyRank(key MapKey) int {
+	switch {
+	case isInteger(key):
+		return 0
+	case key.Type == msgp.StrType:
+		return 1
+	}
+	return 2
+}
+
+// appendInteger appends an integer object encoded with format to b.
+func appendInteger(b []byte, format Format, object MsgpObject) ([]byte, error) {
+	var signed int64
+	var unsigned uint64
+	negative := false
+	if object.Type == msgp.IntType {
+		signed = object.Value.(int64)
+		negative = signed < 0
+		unsigned = uint64(signed)
+	} else {
+		unsigned = object.Value.(uint64)
+		signed = int64(unsigned)
+	}
+
+	fits := false
+	switch format {
+	case FormatPositiveFixint:
+		fits = !negative && unsigned <= 127
+	case FormatNegativeFixint:
+		fits = negative && signed >= -32
+	case FormatUint8, FormatUint16, FormatUint32, FormatUint64:
+		width := formatWidth(format)
+		fits = !negative && (width == 8 || unsigned < 1<<(8*width))
+	case FormatInt8, FormatInt16, FormatInt32, FormatInt64:
+		width := formatWidth(format)
+		fits = (negative || unsigned <= math.MaxInt64) && (width == 8 || (signed >= -1<<(8*width-1) && signed < 1<<(8*width-1)))
+	}
+	if !fits {
+		return b, fmt.Errorf("Cannot encode %d as %s", object.Value, format)
+	}
+
+	switch format {
+	case FormatPositiveFixint, FormatNegativeFixint:
+		return append(b, byte(signed)), nil
+	}
+	b = append(b, leadByte(format))
+	return appendBigEndian(b, unsigned, formatWidth(format)), nil
+}
+
+// appendHeader appends the first bytes of a string, binary, array or map object with format and
+// length to b.
+func appendHeader(b []byte, format Format, length int) ([]byte, error) {
+	switch format {
+	case FormatFixstr:
+		if length <= 31 {
+			return append(b, 0xa0|byte(length)), nil
+		}
+	case FormatFixarray:
+		if length <= 15 {
+			return append(b, 0x90|byte(length)), nil
+		}
+	case FormatFixmap:
+		if length <= 15 {
+			return append(b, 0x80|byte(length)), nil
+		}
+	case FormatStr8, FormatStr16, FormatStr32, FormatBin8, FormatBin16, FormatBin32,
+		FormatArray16, FormatArray32, FormatMap16, FormatMap32:
+		width := formatWidth(format)
+		if width == 4 || length < 1<<(8*width) {
+			b = append(b, leadByte(format))
+			return appendBigEndian(b, uint64(length), width), nil
+		}
+	}
+	return b, fmt.Errorf("Cannot encode length %d as %s", length, format)
+}
+
+// appendExtension appends an extension object encoded with format to b.
+func appendExtension(b []byte, format Format, typ int8, data []byte) ([]byte, error) {
+	switch format {
+	case FormatFixext1, FormatFixext2, FormatFixext4, FormatFixext8, FormatFixext16:
+		if len(data) != formatWidth(format) {
+			return b, fmt.Errorf("Cannot encode extension of length %d as %s", len(data), format)
+		}
+		b = append(b, leadByte(format))
+	case FormatExt8, FormatExt16, FormatExt32:
+		width := formatWidth(format)
+		if width != 4 && len(data) >= 1<<(8*width) {
+			return b, fmt.Errorf("Cannot encode extension of length %d as %s", len(data), format)
+		}
+		b = append(b, leadByte(format))
+		b = appendBigEndian(b, uint64(len(data)), width)
+	default:
+		return b, fmt.Errorf("Cannot encode extension as %s", format)
+	}
+	b = append(b, byte(typ))
+	return append(b, data...), nil
+}
+
+// appendTimestamp appends t encoded with the timestamp extension to b. The format selects the 32,
+// 64 or 96 bit form of the extension.
+func appendTimestamp(b []byte, format Format, t time.Time) ([]byte, error) {
+	sec := t.Unix()
+	nsec := uint64(t.Nanosecond())
+
+	var data []byte
+	switch format {
+	case FormatFixext4:
+		if nsec != 0 || sec < 0 || sec > math.MaxUint32 {
+			return b, fmt.Errorf("Cannot encode timestamp %v as %s", t, format)
+		}
+		data = appendBigEndian(nil, uint64(sec), 4)
+	case FormatFixext8:
+		if sec < 0 || sec >= 1<<34 {
+			return b, fmt.Errorf("Cannot encode timestamp %v as %s", t, format)
+		}
+		data = appendBigEndian(nil, nsec<<34|uint64(sec), 8)
+	default:
+		data = appendBigEndian(nil, nsec, 4)
+		data = appendBigEndian(data, uint64(sec), 8)
+	}
+	return appendExtension(b, format, timestampExtension, data)
+}
+
+// leadByte returns the first byte of objects encoded with format, for formats that do not store
+// part of their value in the first byte.
+func leadByte(format Format) byte {
+	// the inverse of getFormat
+	return 0xc2 + byte(format-FormatFalse)
+}
+
+// formatWidth returns the number of bytes used for the value of an integer format, the length of a
+// string, binary, array, map or extension format, or the data of a fixext format.
+func formatWidth(format Format) int {
+	switch format {
+	case FormatUint8, FormatInt8, FormatStr8, FormatBin8, FormatExt8, FormatFixext1:
+		return 1
+	case FormatUint16, FormatInt16, FormatStr16, FormatBin16, FormatExt16, FormatArray16, FormatMap16, FormatFixext2:
+		return 2
+	case FormatUint32, FormatInt32, FormatStr32, FormatBin32, FormatExt32, FormatArray32, FormatMap32, FormatFixext4:
+		return 4
+	case FormatUint64, FormatInt64, FormatFixext8:
+		return 8
+	case FormatFixext16:
+		return 16
+	}
+	return 0
+}
+
+// appendBigEndian appends the lowest width bytes of value to b, most significant byte first.
+func appendBigEndian(b []byte, value uint64, width int) []byte {
+	var encoded [8]byte
+	binary.BigEndian.PutUint64(encoded[:], value)
+	return append(b, encoded[8-width:]...)
+}
@@ -0,0 +1,161 @@
+package msgpackdiff
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	type RoundTripTest struct {
+		Name  string
+		Input string
+	}
+
+	tests := []RoundTripTest{
+		{Name: "fixint", Input: "AQ=="},
+		{Name: "negative fixint", Input: "/w=="},
+		{Name: "uint8", Input: "zAE="},
+		{Name: "uint16", Input: "zQAB"},
+		{Name: "uint32", Input: "zgAAAAE="},
+		{Name: "uint64", Input: "zwAAAAAAAAAB"},
+		{Name: "int8", Input: "0AE="},
+		{Name: "int16", Input: "0f8A"},
+		{Name: "int32", Input: "0v///wA="},
+		{Name: "int64", Input: "0/////////8A"},
+		{Name: "nil", Input: "wA=="},
+		{Name: "true", Input: "ww=="},
+		{Name: "false", Input: "wg=="},
+		{Name: "float32", Input: "yj/AAAA="},
+		{Name: "float64", Input: "yz/4AAAAAAAA"},
+		{Name: "fixstr", Input: "oWE="},
+		{Name: "str8", Input: "2QFh"},
+		{Name: "str16", Input: "2gABYQ=="},
+		{Name: "str32", Input: "2wAAAAFh"},
+		{Name: "bin8", Input: "xAEB"},
+		{Name: "bin16", Input: "xQABAQ=="},
+		{Name: "bin32", Input: "xgAAAAEB"},
+		{Name: "fixarray", Input: "kgEC"},
+		{Name: "array16", Input: "3AACAQI="},
+		{Name: "array32", Input: "3QAAAAIBAg=="},
+		{Name: "fixmap", Input: "gaFhAQ=="},
+		{Name: "map16", Input: "3gAB2QFhAQ=="},
+		{Name: "map32", Input: "3wAAAAGhYQE="},
+		{Name: "fixext1", Input: "1CoB"},
+		{Name: "ext8", Input: "xwEqAQ=="},
+		{Name: "ext16", Input: "yAABKgE="},
+		{Name: "ext32", Input: "yQAAAAEqAQ=="},
+		{Name: "timestamp32", Input: "1v9fXhAA"},
+		{Name: "timestamp64", Input: "1/8AAAfQX14QAA=="},
+		{Name: "timestamp96", Input: "xwz/AAAAAAAAAABfXhAA"},
+		{Name: "msgp time", Input: "xwwFAAAAAF9eEAAAAAH0"},
+		{Name: "int keys", Input: "ggEBoWGhYg=="},
+		{Name: "nested", Input: "hKFiAKFhzQAFoWPZAXihZJHQAQ=="},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			decoded, err := base64.StdEncoding.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			object, _, err := Parse(decoded)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			encoded, err := Encode(nil, object, EncodeOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if !bytes.Equal(encoded, decoded) {
+				t.Fatalf("Wrong encoding: got %x, expected %x\n", encoded, decoded)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestEncodeCanonical(t *testing.T) {
+	type CanonicalTest struct {
+		Name     string
+		Input    string
+		Expected string
+	}
+
+	tests := []CanonicalTest{
+		{
+			Name:     "canonical",
+			Input:    "gqFhAaFiAg==", // {"a":1,"b":2}
+			Expected: "gqFhAaFiAg==", // {"a":1,"b":2}
+		},
+		{
+			Name:     "violations",
+			Input:    "hKFiAKFhzQAFoWPZAXihZJHQAQ==", // {"b":0,"a":uint16(5),"c":str8("x"),"d":[int8(1)]}
+			Expected: "g6FhBaFjoXihZJEB",             // {"a":5,"c":"x","d":[1]}
+		},
+		{
+			Name:     "int keys",
+			Input:    "ggKhYQGhYg==", // {2:"a",1:"b"}
+			Expected: "ggGhYgKhYQ==", // {1:"b",2:"a"}
+		},
+		{
+			Name:     "timestamp",
+			Input:    "xwz/AAAAAAAAAABfXhAA", // timestamp96(1600000000)
+			Expected: "1v9fXhAA",             // timestamp32(1600000000)
+		},
+		{
+			Name:     "msgp time",
+			Input:    "xwwFAAAAAF9eEAAAAAH0", // time(1600000000.0000005)
+			Expected: "xwwFAAAAAF9eEAAAAAH0", // time(1600000000.0000005)
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			decoded, err := base64.StdEncoding.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			object, _, err := Parse(decoded)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			encoded, err := Encode(nil, object, EncodeOptions{Canonical: true})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			actual := base64.StdEncoding.EncodeToString(encoded)
+			if actual != test.Expected {
+				t.Fatalf("Wrong encoding: got %s, expected %s\n", actual, test.Expected)
+			}
+
+			violations, err := CheckCanonical(encoded, ParseOptions{})
+			if err != nil || len(violations) != 0 {
+				t.Fatalf("Encoding is not canonical: %v %v\n", violations, err)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestEncodeInvalidFormat(t *testing.T) {
+	object := MsgpObject{Type: msgp.UintType, Value: uint64(300), Format: FormatUint8}
+
+	_, err := Encode(nil, object, EncodeOptions{})
+	if err == nil {
+		t.Fatal("No error for value that does not fit its format\n")
+	}
+
+	encoded, err := Encode(nil, object, EncodeOptions{Canonical: true})
+	if err != nil || !bytes.Equal(encoded, []byte{0xcd, 0x01, 0x2c}) {
+		t.Fatalf("Wrong canonical encoding: got %x, %v\n", encoded, err)
+	}
+}
@@ -0,0 +1,94 @@
+package msgpackdiff
+
+import (
+	"fmt"
+	"math"
+)
+
+// FloatEquality is the way that floating-point numbers are compared for equality, which also
+// applies to the parts of complex numbers.
+type FloatEquality int
+
+// The ways to compare floating-point numbers. FloatEqualityIEEE compares them like the == operator
+// does, so that NaN is not equal to itself and -0 is equal to 0. FloatEqualityNaN is the same,
+// except that every NaN is equal to every other NaN. FloatEqualityBitwise compares the bits of
+// the numbers, so that NaNs are equal if they have the same payload and -0 is not equal to 0.
+const (
+	FloatEqualityIEEE FloatEquality = iota
+	FloatEqualityNaN
+	FloatEqualityBitwise
+)
+
+var floatEqualityNames = map[FloatEquality]string{
+	FloatEqualityIEEE:    "ieee",
+	FloatEqualityNaN:     "nan",
+	FloatEqualityBitwise: "bitwise",
+}
+
+func (e FloatEquality) String() string {
+	return floatEqualityNames[e]
+}
+
+// ParseFloatEquality returns the FloatEquality with the given name, which is one of ieee, nan or
+// bitwise.
+func ParseFloatEquality(name string) (FloatEquality, error) {
+	for equality, equalityName := range floatEqualityNames {
+		if name == equalityName {
+			return equality, nil
+		}
+	}
+	return FloatEqualityIEEE, fmt.Errorf("Unknown float equality %q", name)
+}
+
+// equal64 returns true if a and b are equal.
+func (e FloatEquality) equal64(a float64, b float64) bool {
+	switch e {
+	case FloatEqualityNaN:
+		return a == b || (math.IsNaN(a) && math.IsNaN(b))
+	case FloatEqualityBitwise:
+		return math.Float64bits(a) == math.Float64bits(b)
+	}
+	return a == b
+}
+
+// equal32 returns true if a and b are equal.
+func (e FloatEquality) equal32(a float32, b float32) bool {
+	if e == FloatEqualityBitwise {
+		return math.Float32bits(a) == math.Float32bits(b)
+	}
+	return e.equal64(float64(a), float64(b))
+}
+
+// equalComplex64 returns true if the real and imaginary parts of a and b are equal.
+func (e FloatEquality) equalComplex64(a complex64, b complex64) bool {
+	return e.equal32(real(a), real(b)) && e.equal32(imag(a), imag(b))
+}
+
+// equalComplex128 returns true if the real and imaginary parts of a and b are equal.
+func (e FloatEquality) equalComplex128(a complex128, b complex128) bool {
+	return e.equal64(real(a), real(b)) && e.equal64(imag(a), imag(b))
+}
+
+// compareNumbersWith is like compareNumbers, but compares floating-point numbers as given by e.
+// NaNs of different types are compared after converting them to float64, and with
+// FloatEqualityBitwise, the signs of zeros must be the same.
+func compareNumbersWith(a MsgpObject, b MsgpObject, e FloatEquality) bool {
+	if e == FloatEqualityIEEE {
+		return compareNumbers(a, b)
+	}
+
+	realA, imagA, _, okA := numberParts(a)
+	realB, imagB, _, okB := numberParts(b)
+	if !okA || !okB {
+		return false
+	}
+	if math.IsNaN(realA) || math.IsNaN(imagA) || math.IsNaN(realB) || math.IsNaN(imagB) {
+		return e.equal64(realA, realB) && e.equal64(imagA, imagB)
+	}
+
+	if !compareNumbers(a, b) {
+		return false
+	}
+	return e != FloatEqualityBitwise ||
+		(math.Signbit(realA) == math.Signbit(realB) && math.Signbit(imagA) == math.Signbit(imagB))
+}
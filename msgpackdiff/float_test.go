@@ -0,0 +1,173 @@
+package msgpackdiff
+
+import (
+	"math"
+	"testing"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+func TestParseFloatEquality(t *testing.T) {
+	for equality, name := range floatEqualityNames {
+		parsed, err := ParseFloatEquality(name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if parsed != equality {
+			t.Fatalf("Wrong float equality: got %v, expected %v\n", parsed, equality)
+		}
+	}
+
+	if _, err := ParseFloatEquality("exact"); err == nil {
+		t.Fatalf("No error for unknown float equality\n")
+	}
+}
+
+func TestCompareFloatEquality(t *testing.T) {
+	type FloatEqualityTest struct {
+		Name    string
+		A       MsgpObject
+		B       MsgpObject
+		Options CompareOptions
+		// whether the objects are equal with FloatEqualityIEEE, FloatEqualityNaN and
+		// FloatEqualityBitwise
+		Expected [3]bool
+	}
+
+	otherNaN := math.Float64frombits(math.Float64bits(math.NaN()) + 1)
+	otherNaN32 := math.Float32frombits(math.Float32bits(float32(math.NaN())) + 1)
+	negativeZero := math.Copysign(0, -1)
+
+	tests := []FloatEqualityTest{
+		{
+			Name:     "float64 NaN",
+			A:        MsgpObject{Type: msgp.Float64Type, Value: math.NaN()},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: math.NaN()},
+			Expected: [3]bool{false, true, true},
+		},
+		{
+			Name:     "float64 NaN payloads",
+			A:        MsgpObject{Type: msgp.Float64Type, Value: math.NaN()},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: otherNaN},
+			Expected: [3]bool{false, true, false},
+		},
+		{
+			Name:     "float64 NaN and number",
+			A:        MsgpObject{Type: msgp.Float64Type, Value: math.NaN()},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: 1.5},
+			Expected: [3]bool{false, false, false},
+		},
+		{
+			Name:     "float64 zeros",
+			A:        MsgpObject{Type: msgp.Float64Type, Value: 0.0},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: negativeZero},
+			Expected: [3]bool{true, true, false},
+		},
+		{
+			Name:     "float64 numbers",
+			A:        MsgpObject{Type: msgp.Float64Type, Value: 1.5},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: 1.5},
+			Expected: [3]bool{true, true, true},
+		},
+		{
+			Name:     "float32 NaN",
+			A:        MsgpObject{Type: msgp.Float32Type, Value: float32(math.NaN())},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: float32(math.NaN())},
+			Expected: [3]bool{false, true, true},
+		},
+		{
+			Name:     "float32 NaN payloads",
+			A:        MsgpObject{Type: msgp.Float32Type, Value: float32(math.NaN())},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: otherNaN32},
+			Expected: [3]bool{false, true, false},
+		},
+		{
+			Name:     "float32 zeros",
+			A:        MsgpObject{Type: msgp.Float32Type, Value: float32(0)},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: float32(negativeZero)},
+			Expected: [3]bool{true, true, false},
+		},
+		{
+			Name:     "complex64 NaN",
+			A:        MsgpObject{Type: msgp.Complex64Type, Value: complex(float32(1), float32(math.NaN()))},
+			B:        MsgpObject{Type: msgp.Complex64Type, Value: complex(float32(1), float32(math.NaN()))},
+			Expected: [3]bool{false, true, true},
+		},
+		{
+			Name:     "complex128 NaN",
+			A:        MsgpObject{Type: msgp.Complex128Type, Value: complex(math.NaN(), 2)},
+			B:        MsgpObject{Type: msgp.Complex128Type, Value: complex(math.NaN(), 2)},
+			Expected: [3]bool{false, true, true},
+		},
+		{
+			Name:     "complex128 NaN and different part",
+			A:        MsgpObject{Type: msgp.Complex128Type, Value: complex(math.NaN(), 2)},
+			B:        MsgpObject{Type: msgp.Complex128Type, Value: complex(math.NaN(), 3)},
+			Expected: [3]bool{false, false, false},
+		},
+		{
+			Name:     "complex128 zeros",
+			A:        MsgpObject{Type: msgp.Complex128Type, Value: complex(1, 0)},
+			B:        MsgpObject{Type: msgp.Complex128Type, Value: complex(1, negativeZero)},
+			Expected: [3]bool{true, true, false},
+		},
+		{
+			Name:     "flexible NaN",
+			A:        MsgpObject{Type: msgp.Float32Type, Value: float32(math.NaN())},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: float64(float32(math.NaN()))},
+			Options:  CompareOptions{FlexibleTypes: true},
+			Expected: [3]bool{false, true, true},
+		},
+		{
+			Name:     "flexible zeros",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(0)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: negativeZero},
+			Options:  CompareOptions{FlexibleTypes: true},
+			Expected: [3]bool{true, true, false},
+		},
+		{
+			Name:     "zeros with tolerance",
+			A:        MsgpObject{Type: msgp.Float64Type, Value: 0.0},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: negativeZero},
+			Options:  CompareOptions{Tolerance: Tolerance{Absolute: 0.001}},
+			Expected: [3]bool{true, true, true},
+		},
+	}
+
+	equalities := []FloatEquality{FloatEqualityIEEE, FloatEqualityNaN, FloatEqualityBitwise}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			for i, equality := range equalities {
+				for _, brief := range []bool{false, true} {
+					options := test.Options
+					options.FloatEquality = equality
+					options.Brief = brief
+					expected := test.Expected[i]
+
+					result := CompareParsed([]MsgpObject{test.A}, []MsgpObject{test.B}, options)
+					if result.Equal != expected {
+						t.Fatalf("Wrong result with %v and brief %v: got %v, expected %v\n", equality, brief, result.Equal, expected)
+					}
+
+					// elements of arrays are matched with lcsObjects
+					array := func(object MsgpObject) MsgpObject {
+						return MsgpObject{Type: msgp.ArrayType, Value: []MsgpObject{object}}
+					}
+					result = CompareParsed([]MsgpObject{array(test.A)}, []MsgpObject{array(test.B)}, options)
+					if result.Equal != expected {
+						t.Fatalf("Wrong result in array with %v and brief %v: got %v, expected %v\n", equality, brief, result.Equal, expected)
+					}
+
+					// elements of unordered arrays are matched by their fingerprints
+					options.UnorderedArrays = true
+					result = CompareParsed([]MsgpObject{array(test.A)}, []MsgpObject{array(test.B)}, options)
+					if result.Equal != expected {
+						t.Fatalf("Wrong result in unordered array with %v and brief %v: got %v, expected %v\n", equality, brief, result.Equal, expected)
+					}
+				}
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
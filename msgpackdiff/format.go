@@ -0,0 +1,250 @@
+package msgpackdiff
+
+import (
+	"math"
+	"time"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// Format is the concrete MessagePack format that an object was encoded with. Formats that store
+// their value or length in the first byte, such as fixint and fixstr, are identified by their
+// family, so every fixstr has the format FormatFixstr regardless of its length.
+type Format int
+
+// The MessagePack formats. FormatUnknown is used for objects that were not parsed from
+// MessagePack.
+const (
+	FormatUnknown Format = iota
+	FormatPositiveFixint
+	FormatFixmap
+	FormatFixarray
+	FormatFixstr
+	FormatNil
+	FormatFalse
+	FormatTrue
+	FormatBin8
+	FormatBin16
+	FormatBin32
+	FormatExt8
+	FormatExt16
+	FormatExt32
+	FormatFloat32
+	FormatFloat64
+	FormatUint8
+	FormatUint16
+	FormatUint32
+	FormatUint64
+	FormatInt8
+	FormatInt16
+	FormatInt32
+	FormatInt64
+	FormatFixext1
+	FormatFixext2
+	FormatFixext4
+	FormatFixext8
+	FormatFixext16
+	FormatStr8
+	FormatStr16
+	FormatStr32
+	FormatArray16
+	FormatArray32
+	FormatMap16
+	FormatMap32
+	FormatNegativeFixint
+)
+
+var formatNames = map[Format]string{
+	FormatUnknown:        "unknown",
+	FormatPositiveFixint: "fixint",
+	FormatFixmap:         "fixmap",
+	FormatFixarray:       "fixarray",
+	FormatFixstr:         "fixstr",
+	FormatNil:            "nil",
+	FormatFalse:          "false",
+	FormatTrue:           "true",
+	FormatBin8:           "bin8",
+	FormatBin16:          "bin16",
+	FormatBin32:          "bin32",
+	FormatExt8:           "ext8",
+	FormatExt16:          "ext16",
+	FormatExt32:          "ext32",
+	FormatFloat32:        "float32",
+	FormatFloat64:        "float64",
+	FormatUint8:          "uint8",
+	FormatUint16:         "uint16",
+	FormatUint32:         "uint32",
+	FormatUint64:         "uint64",
+	FormatInt8:           "int8",
+	FormatInt16:          "int16",
+	FormatInt32:          "int32",
+	FormatInt64:          "int64",
+	FormatFixext1:        "fixext1",
+	FormatFixext2:        "fixext2",
+	FormatFixext4:        "fixext4",
+	FormatFixext8:        "fixext8",
+	FormatFixext16:       "fixext16",
+	FormatStr8:           "str8",
+	FormatStr16:          "str16",
+	FormatStr32:          "str32",
+	FormatArray16:        "array16",
+	FormatArray32:        "array32",
+	FormatMap16:          "map16",
+	FormatMap32:          "map32",
+	FormatNegativeFixint: "negative fixint",
+}
+
+func (f Format) String() string {
+	return formatNames[f]
+}
+
+// getFormat returns the format of an object that begins with the byte lead.
+func getFormat(lead byte) Format {
+	switch {
+	case lead <= 0x7f:
+		return FormatPositiveFixint
+	case lead <= 0x8f:
+		return FormatFixmap
+	case lead <= 0x9f:
+		return FormatFixarray
+	case lead <= 0xbf:
+		return FormatFixstr
+	case lead >= 0xe0:
+		return FormatNegativeFixint
+	case lead == 0xc1:
+		// 0xc1 is never used
+		return FormatUnknown
+	case lead == 0xc0:
+		return FormatNil
+	}
+	// the remaining formats are in the same order as their lead bytes, starting at 0xc2 and
+	// skipping the unused 0xc1
+	return FormatFalse + Format(lead-0xc2)
+}
+
+// minimalFormat returns the smallest format that can encode the value of an object, which is the
+// format a canonical encoder uses. If the object has no single smallest format, FormatUnknown is
+// returned.
+func minimalFormat(mo MsgpObject) Format {
+	switch mo.Type {
+	case msgp.NilType:
+		return FormatNil
+	case msgp.BoolType:
+		if mo.Value.(bool) {
+			return FormatTrue
+		}
+		return FormatFalse
+	case msgp.IntType:
+		value := mo.Value.(int64)
+		if value >= 0 {
+			return minimalUintFormat(uint64(value))
+		}
+		switch {
+		case value >= -32:
+			return FormatNegativeFixint
+		case value >= math.MinInt8:
+			return FormatInt8
+		case value >= math.MinInt16:
+			return FormatInt16
+		case value >= math.MinInt32:
+			return FormatInt32
+		}
+		return FormatInt64
+	case msgp.UintType:
+		return minimalUintFormat(mo.Value.(uint64))
+	case msgp.Float32Type:
+		return FormatFloat32
+	case msgp.Float64Type:
+		return FormatFloat64
+	case msgp.StrType:
+		length := len(mo.Value.(string))
+		if length <= 31 {
+			return FormatFixstr
+		}
+		return sizedFormat(length, FormatStr8, FormatStr16, FormatStr32)
+	case msgp.BinType:
+		return sizedFormat(len(mo.Value.([]byte)), FormatBin8, FormatBin16, FormatBin32)
+	case msgp.ArrayType:
+		length := len(mo.Value.([]MsgpObject))
+		if length <= 15 {
+			return FormatFixarray
+		}
+		return sizedFormat(length, FormatUnknown, FormatArray16, FormatArray32)
+	case msgp.MapType:
+		length := len(mo.Value.(MsgpMap).Order)
+		if length <= 15 {
+			return FormatFixmap
+		}
+		return sizedFormat(length, FormatUnknown, FormatMap16, FormatMap32)
+	case msgp.ExtensionType:
+		if ext, ok := mo.Value.(Extension); ok {
+			return minimalExtensionFormat(len(ext.Data))
+		}
+	case msgp.TimeType:
+		t := mo.Value.(time.Time)
+		switch {
+		case t.Nanosecond() == 0 && t.Unix() >= 0 && t.Unix() <= math.MaxUint32:
+			return FormatFixext4
+		case t.Unix() >= 0 && t.Unix() < 1<<34:
+			return FormatFixext8
+		}
+		return FormatExt8
+	}
+	return FormatUnknown
+}
+
+// unsignedObject returns the object for a non-negative integer from a format other than MessagePack.
+// It has the type that parsing the smallest MessagePack encoding of value produces, which is IntType
+// for a positive fixint and UintType otherwise.
+func unsignedObject(value uint64) MsgpObject {
+	if value <= 127 {
+		return MsgpObject{Type: msgp.IntType, Value: int64(value)}
+	}
+	return MsgpObject{Type: msgp.UintType, Value: value}
+}
+
+// minimalUintFormat returns the smallest format that can encode the non-negative integer value.
+func minimalUintFormat(value uint64) Format {
+	switch {
+	case value <= 127:
+		return FormatPositiveFixint
+	case value <= math.MaxUint8:
+		return FormatUint8
+	case value <= math.MaxUint16:
+		return FormatUint16
+	case value <= math.MaxUint32:
+		return FormatUint32
+	}
+	return FormatUint64
+}
+
+// minimalExtensionFormat returns the smallest format that can encode an extension with length bytes
+// of data.
+func minimalExtensionFormat(length int) Format {
+	switch length {
+	case 1:
+		return FormatFixext1
+	case 2:
+		return FormatFixext2
+	case 4:
+		return FormatFixext4
+	case 8:
+		return FormatFixext8
+	case 16:
+		return FormatFixext16
+	}
+	return sizedFormat(length, FormatExt8, FormatExt16, FormatExt32)
+}
+
+// sizedFormat returns the smallest of the formats with 8, 16 and 32 bit lengths that can encode an
+// object with the given length. If the family has no format with an 8 bit length, format8 is
+// FormatUnknown.
+func sizedFormat(length int, format8 Format, format16 Format, format32 Format) Format {
+	switch {
+	case length <= math.MaxUint8 && format8 != FormatUnknown:
+		return format8
+	case length <= math.MaxUint16:
+		return format16
+	}
+	return format32
+}
@@ -0,0 +1,242 @@
+package msgpackdiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// Framing is the way that the top-level objects of an input are separated from each other.
+type Framing int
+
+// The framings of input data. FramingRaw is objects one after another, as read by ParseAll.
+// FramingLengthPrefixed is objects that each follow their length in bytes. FramingRPC is
+// msgpack-RPC messages one after another, which are aligned by their msgid when compared.
+const (
+	FramingRaw Framing = iota
+	FramingLengthPrefixed
+	FramingRPC
+)
+
+var framingNames = map[Framing]string{
+	FramingRaw:            "raw",
+	FramingLengthPrefixed: "length",
+	FramingRPC:            "rpc",
+}
+
+func (f Framing) String() string {
+	return framingNames[f]
+}
+
+// ParseFraming returns the Framing with the given name, which is one of raw, length or rpc.
+func ParseFraming(name string) (Framing, error) {
+	for framing, framingName := range framingNames {
+		if name == framingName {
+			return framing, nil
+		}
+	}
+	return FramingRaw, fmt.Errorf("Unknown framing %q", name)
+}
+
+// DefaultPrefixWidth is the width of length prefixes used when FrameOptions.PrefixWidth is 0.
+const DefaultPrefixWidth = 4
+
+// FrameOptions describe how the top-level objects of an input are framed.
+type FrameOptions struct {
+	Framing Framing
+	// The number of bytes in each length prefix with FramingLengthPrefixed: 1, 2, 4 or 8. If 0,
+	// DefaultPrefixWidth is used.
+	PrefixWidth int
+	// Reads length prefixes as little-endian when true. They are big-endian otherwise.
+	LittleEndian bool
+}
+
+// The errors of a ParseError when the frames of an input are invalid.
+var (
+	ErrTruncatedFrame    = errors.New("Frame is longer than the remaining input")
+	ErrFrameTrailingData = errors.New("Frame has data after its object")
+)
+
+// ParseFrames parses every top-level object in data, which are framed as given by framing. With
+// FramingRaw and FramingRPC, this is the same as ParseAll. With FramingLengthPrefixed, each frame
+// must hold exactly one object. The offsets of the objects are those in data, so they include the
+// length prefixes. If an object cannot be parsed, the objects before it are returned along with a
+// *ParseError.
+func ParseFrames(data []byte, framing FrameOptions, options ParseOptions) (objects []MsgpObject, err error) {
+	if framing.Framing != FramingLengthPrefixed {
+		return ParseAll(data, options)
+	}
+
+	width := framing.PrefixWidth
+	if width == 0 {
+		width = DefaultPrefixWidth
+	}
+	if width != 1 && width != 2 && width != 4 && width != 8 {
+		return nil, fmt.Errorf("Invalid prefix width %d", width)
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if framing.LittleEndian {
+		order = binary.LittleEndian
+	}
+
+	objects = []MsgpObject{}
+	p := newParser(data, options)
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < width {
+			err = &ParseError{Offset: offset, TypeByte: -1, Err: ErrTruncatedFrame}
+			return
+		}
+
+		var length uint64
+		switch width {
+		case 1:
+			length = uint64(data[offset])
+		case 2:
+			length = uint64(order.Uint16(data[offset:]))
+		case 4:
+			length = uint64(order.Uint32(data[offset:]))
+		case 8:
+			length = order.Uint64(data[offset:])
+		}
+
+		start := offset + width
+		if length > uint64(len(data)-start) {
+			err = &ParseError{Offset: offset, TypeByte: -1, Err: ErrTruncatedFrame}
+			return
+		}
+		frame := data[start : start+int(length)]
+
+		var object MsgpObject
+		var remaining []byte
+		object, remaining, err = p.parseAt(frame, start, 0)
+		if err != nil {
+			return
+		}
+		if len(remaining) != 0 {
+			trailing := object.End
+			err = &ParseError{Offset: trailing, TypeByte: int(data[trailing]), Err: ErrFrameTrailingData}
+			return
+		}
+
+		objects = append(objects, object)
+		offset = start + int(length)
+	}
+	return
+}
+
+// The types of msgpack-RPC messages, from the first element of each message.
+const (
+	rpcRequest      = 0
+	rpcResponse     = 1
+	rpcNotification = 2
+)
+
+// CompareRPC compares two sequences of msgpack-RPC messages, such as the objects returned by
+// ParseFrames with FramingRPC. Requests and responses are aligned by their type and msgid rather
+// than their position, so that messages that were sent or answered in a different order are still
+// compared with each other. Notifications have no msgid, so they are aligned by their position
+// among the notifications of each side.
+//
+// The objects of the returned result are maps from a description of each message, like
+// "request 5", to the message. If a msgid is used by more than one request or response of a side,
+// they are aligned in the order they appear. An error is returned if an object is not a
+// msgpack-RPC message.
+func CompareRPC(a []MsgpObject, b []MsgpObject, options CompareOptions) (result CompareResult, err error) {
+	var messages [2]MsgpObject
+	for i, objects := range [2][]MsgpObject{a, b} {
+		messages[i], err = rpcMessages(objects)
+		if err != nil {
+			err = fmt.Errorf("Failed to align %s object: %w", sides[i], err)
+			return
+		}
+	}
+
+	result = compareKeyed(messages, options)
+	return
+}
+
+// rpcMessages returns a map from the description of each of the msgpack-RPC messages in objects to
+// the message.
+func rpcMessages(objects []MsgpObject) (MsgpObject, error) {
+	messages := MsgpMap{
+		Order:  make([]MapKey, 0, len(objects)),
+		Values: make(map[MapKey]MsgpObject, len(objects)),
+	}
+	notifications := 0
+	for i, object := range objects {
+		name, err := rpcName(object)
+		if err != nil {
+			return MsgpObject{}, fmt.Errorf("Object %d is not a msgpack-RPC message: %w", i, err)
+		}
+		if name == "notification" {
+			name = fmt.Sprintf("notification %d", notifications)
+			notifications++
+		}
+
+		key := StringKey(name)
+		for n := 2; ; n++ {
+			if _, ok := messages.Values[key]; !ok {
+				break
+			}
+			key = StringKey(fmt.Sprintf("%s (%d)", name, n))
+		}
+
+		messages.Order = append(messages.Order, key)
+		messages.Values[key] = object
+	}
+
+	return keyedObject(objects, messages), nil
+}
+
+// rpcName returns the type and msgid of a msgpack-RPC message, like "request 5", or "notification"
+// for a notification.
+func rpcName(message MsgpObject) (string, error) {
+	if message.Type != msgp.ArrayType {
+		return "", errors.New("Message is not an array")
+	}
+	elements := message.Value.([]MsgpObject)
+	if len(elements) == 0 {
+		return "", errors.New("Message is empty")
+	}
+
+	messageType, ok := rpcInteger(elements[0])
+	if !ok {
+		return "", errors.New("Message type is not an integer")
+	}
+
+	switch messageType {
+	case rpcRequest, rpcResponse:
+		if len(elements) != 4 {
+			return "", fmt.Errorf("Message has %d elements, expected 4", len(elements))
+		}
+		msgid, ok := rpcInteger(elements[1])
+		if !ok {
+			return "", errors.New("Message msgid is not an integer")
+		}
+		if messageType == rpcRequest {
+			return fmt.Sprintf("request %d", msgid), nil
+		}
+		return fmt.Sprintf("response %d", msgid), nil
+	case rpcNotification:
+		if len(elements) != 3 {
+			return "", fmt.Errorf("Message has %d elements, expected 3", len(elements))
+		}
+		return "notification", nil
+	}
+	return "", fmt.Errorf("Unknown message type %d", messageType)
+}
+
+// rpcInteger returns the value of a non-negative integer object.
+func rpcInteger(object MsgpObject) (uint64, bool) {
+	switch object.Type {
+	case msgp.IntType:
+		value := object.Value.(int64)
+		return uint64(value), value >= 0
+	case msgp.UintType:
+		return object.Value.(uint64), true
+	}
+	return 0, false
+}
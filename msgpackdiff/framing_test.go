@@ -0,0 +1,298 @@
+package msgpackdiff
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+func TestParseFrames(t *testing.T) {
+	type ParseFramesTest struct {
+		Name     string
+		Input    string
+		Framing  FrameOptions
+		Expected []MsgpObject
+		Spans    []Span
+	}
+
+	one := MsgpObject{Type: msgp.IntType, Value: int64(1)}
+	str := MsgpObject{Type: msgp.StrType, Value: "a"}
+
+	tests := []ParseFramesTest{
+		{
+			Name:     "raw",
+			Input:    "01a161",
+			Framing:  FrameOptions{Framing: FramingRaw},
+			Expected: []MsgpObject{one, str},
+			Spans:    []Span{{0, 1}, {1, 3}},
+		},
+		{
+			Name:     "default prefix",
+			Input:    "0000000101" + "00000002a161",
+			Framing:  FrameOptions{Framing: FramingLengthPrefixed},
+			Expected: []MsgpObject{one, str},
+			Spans:    []Span{{4, 5}, {9, 11}},
+		},
+		{
+			Name:     "one byte prefix",
+			Input:    "0101" + "02a161",
+			Framing:  FrameOptions{Framing: FramingLengthPrefixed, PrefixWidth: 1},
+			Expected: []MsgpObject{one, str},
+			Spans:    []Span{{1, 2}, {3, 5}},
+		},
+		{
+			Name:     "little-endian prefix",
+			Input:    "010001" + "0200a161",
+			Framing:  FrameOptions{Framing: FramingLengthPrefixed, PrefixWidth: 2, LittleEndian: true},
+			Expected: []MsgpObject{one, str},
+			Spans:    []Span{{2, 3}, {5, 7}},
+		},
+		{
+			Name:     "eight byte prefix",
+			Input:    "000000000000000101",
+			Framing:  FrameOptions{Framing: FramingLengthPrefixed, PrefixWidth: 8},
+			Expected: []MsgpObject{one},
+			Spans:    []Span{{8, 9}},
+		},
+		{
+			Name:     "empty",
+			Input:    "",
+			Framing:  FrameOptions{Framing: FramingLengthPrefixed},
+			Expected: []MsgpObject{},
+			Spans:    []Span{},
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			input, err := hex.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			objects, err := ParseFrames(input, test.Framing, ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			spans := []Span{}
+			for i := range objects {
+				spans = append(spans, objects[i].Span())
+				objects[i] = stripMetadata(objects[i])
+			}
+
+			if !reflect.DeepEqual(objects, test.Expected) {
+				t.Fatalf("Wrong objects: got %v, expected %v\n", objects, test.Expected)
+			}
+
+			if !reflect.DeepEqual(spans, test.Spans) {
+				t.Fatalf("Wrong spans: got %v, expected %v\n", spans, test.Spans)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestParseFramesErrors(t *testing.T) {
+	type ErrorTest struct {
+		Name     string
+		Input    string
+		Err      error
+		Offset   int
+		TypeByte int
+	}
+
+	tests := []ErrorTest{
+		{
+			Name:     "truncated prefix",
+			Input:    "0000000101" + "0000",
+			Err:      ErrTruncatedFrame,
+			Offset:   5,
+			TypeByte: -1,
+		},
+		{
+			Name:     "truncated frame",
+			Input:    "00000002a1",
+			Err:      ErrTruncatedFrame,
+			TypeByte: -1,
+		},
+		{
+			Name:     "trailing data",
+			Input:    "000000020102",
+			Err:      ErrFrameTrailingData,
+			Offset:   5,
+			TypeByte: 0x02,
+		},
+		{
+			Name:     "truncated object",
+			Input:    "00000001a1",
+			Err:      msgp.ErrShortBytes,
+			Offset:   4,
+			TypeByte: 0xa1,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			input, err := hex.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			_, err = ParseFrames(input, FrameOptions{Framing: FramingLengthPrefixed}, ParseOptions{})
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a *ParseError, got %v\n", err)
+			}
+
+			if !errors.Is(err, test.Err) {
+				t.Fatalf("Wrong error: got %v, expected %v\n", parseErr.Err, test.Err)
+			}
+
+			if parseErr.Offset != test.Offset {
+				t.Fatalf("Wrong offset: got %d, expected %d\n", parseErr.Offset, test.Offset)
+			}
+
+			if parseErr.TypeByte != test.TypeByte {
+				t.Fatalf("Wrong type byte: got %d, expected %d\n", parseErr.TypeByte, test.TypeByte)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+
+	_, err := ParseFrames([]byte{1, 1}, FrameOptions{Framing: FramingLengthPrefixed, PrefixWidth: 3}, ParseOptions{})
+	if err == nil {
+		t.Fatalf("No error for a prefix width of 3\n")
+	}
+}
+
+func TestCompareRPC(t *testing.T) {
+	type CompareRPCTest struct {
+		Name     string
+		A        string
+		B        string
+		Expected bool
+		Paths    []string
+	}
+
+	// [0, 1, "a", []], [0, 2, "b", []], [1, 1, nil, 5], [1, 2, nil, 6]
+	requests := "940001a161909400" + "02a16290"
+	responses := "940101c005" + "940102c006"
+
+	tests := []CompareRPCTest{
+		{
+			Name:     "same order",
+			A:        requests + responses,
+			B:        requests + responses,
+			Expected: true,
+		},
+		{
+			Name:     "responses reordered",
+			A:        requests + "940101c005" + "940102c006",
+			B:        requests + "940102c006" + "940101c005",
+			Expected: true,
+		},
+		{
+			Name:     "requests interleaved with responses",
+			A:        requests + responses,
+			B:        "940001a16190" + "940101c005" + "940002a16290" + "940102c006",
+			Expected: true,
+		},
+		{
+			Name:     "different result",
+			A:        requests + "940101c005" + "940102c006",
+			B:        requests + "940102c007" + "940101c005",
+			Expected: false,
+			Paths:    []string{`["response 2"][3]`, `["response 2"][4]`},
+		},
+		{
+			Name:     "missing response",
+			A:        requests + responses,
+			B:        requests + "940102c006",
+			Expected: false,
+			Paths:    []string{`["response 1"]`},
+		},
+		{
+			Name:     "notifications",
+			A:        "9302a16190" + "9302a16290",
+			B:        "9302a16190" + "9302a16390",
+			Expected: false,
+			Paths:    []string{`["notification 1"][1]`, `["notification 1"][1]`},
+		},
+		{
+			Name:     "reused msgid",
+			A:        "940101c005" + "940101c006",
+			B:        "940101c005" + "940101c006",
+			Expected: true,
+		},
+	}
+
+	parse := func(t *testing.T, input string) []MsgpObject {
+		bin, err := hex.DecodeString(input)
+		if err != nil {
+			t.Fatalf("Could not decode input \"%v\": %v\n", input, err)
+		}
+		objects, err := ParseFrames(bin, FrameOptions{Framing: FramingRPC}, ParseOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		return objects
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			result, err := CompareRPC(parse(t, test.A), parse(t, test.B), CompareOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+
+			paths := []string{}
+			for _, diff := range result.Reporter.Differences {
+				if diff.Type != Replacement {
+					paths = append(paths, diffPath(diff).String())
+				}
+			}
+			if test.Paths == nil {
+				test.Paths = []string{}
+			}
+			if !reflect.DeepEqual(paths, test.Paths) {
+				t.Fatalf("Wrong differences: got %v, expected %v\n", paths, test.Paths)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+
+	invalid := []string{
+		"01",           // not an array
+		"930001a161",   // request with 3 elements
+		"9403010203",   // unknown type
+		"94a16101c005", // string type
+	}
+	for _, input := range invalid {
+		_, err := CompareRPC(parse(t, input), []MsgpObject{}, CompareOptions{})
+		if err == nil {
+			t.Errorf("No error for %s\n", input)
+		}
+	}
+}
+
+// diffPath returns the path to the object of a difference.
+func diffPath(diff Difference) Path {
+	path := Path{}
+	for _, layer := range diff.Path {
+		if layer.Object.Type == msgp.MapType {
+			path = append(path, KeyElement(layer.CurrentKey))
+		} else {
+			path = append(path, IndexElement(layer.CurrentIndex))
+		}
+	}
+	return path
+}
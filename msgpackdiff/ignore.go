@@ -0,0 +1,111 @@
+package msgpackdiff
+
+// ignoredAt returns true if the object at path is left out of the comparison because it matches
+// one of options.IgnorePaths or a rule that ignores it. The path starts with the index of the top-level object that the
+// object is in.
+func ignoredAt(path Path, options CompareOptions) bool {
+	if len(path) == 0 {
+		return false
+	}
+	for _, pattern := range options.IgnorePaths {
+		if pattern.Match(path[1:]) {
+			return true
+		}
+	}
+	for _, rule := range options.Rules {
+		if rule.Ignore && rule.Path.Match(path[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayBeIgnored returns true if one of options.IgnorePaths or the rules that ignore objects may
+// match the object at path, where path may contain wildcards for indices that are not known, like
+// the paths used by fingerprint. The path starts with the index of the top-level object that the
+// object is in.
+func mayBeIgnored(path Path, options CompareOptions) bool {
+	if len(path) == 0 {
+		return false
+	}
+	for _, pattern := range options.IgnorePaths {
+		if pattern.overlaps(path[1:]) {
+			return true
+		}
+	}
+	for _, rule := range options.Rules {
+		if rule.Ignore && rule.Path.overlaps(path[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps returns true if some path is matched by both p and other, which may both contain
+// wildcards.
+func (p Path) overlaps(other Path) bool {
+	if len(p) != 0 && p[0].Recursive {
+		return p[1:].overlaps(other) || (len(other) != 0 && p.overlaps(other[1:]))
+	}
+	if len(other) != 0 && other[0].Recursive {
+		return other.overlaps(p)
+	}
+	if len(p) == 0 || len(other) == 0 {
+		return len(p) == len(other)
+	}
+	element, otherElement := p[0], other[0]
+	switch {
+	case element.InArray != otherElement.InArray:
+		return false
+	case element.Wildcard || otherElement.Wildcard:
+	case element != otherElement:
+		return false
+	}
+	return p[1:].overlaps(other[1:])
+}
+
+// ignore returns true if the current object is ignored because of its path, and counts it in
+// r.Ignored.
+func (r *Reporter) ignore(options CompareOptions) bool {
+	if !options.ignoresPaths() || !ignoredAt(r.currentPath(), options) {
+		return false
+	}
+	r.Ignored++
+	return true
+}
+
+// ignoreElementB is like ignore, but checks the element at indexB of the current array of side B
+// instead of the current element, whose index is the index in side A.
+func (r *Reporter) ignoreElementB(indexB int, options CompareOptions) bool {
+	if !options.ignoresPaths() {
+		return false
+	}
+	path := r.currentPath()
+	path[len(path)-1] = IndexElement(indexB)
+	if !ignoredAt(path, options) {
+		return false
+	}
+	r.Ignored++
+	return true
+}
+
+// ignoreEmpty returns true if value, the current object, is empty and is only on one side, and
+// options or the rules for its path ignore empty objects. Unlike ignore, it is not counted in
+// r.Ignored.
+func (r *Reporter) ignoreEmpty(value MsgpObject, options CompareOptions) bool {
+	if len(options.Rules) != 0 {
+		options = options.at(r.currentPath())
+	}
+	return options.IgnoreEmpty && value.IsEmpty()
+}
+
+// ignoreEmptyElementB is like ignoreEmpty, but checks the element at indexB of the current array of
+// side B like ignoreElementB.
+func (r *Reporter) ignoreEmptyElementB(value MsgpObject, indexB int, options CompareOptions) bool {
+	if len(options.Rules) != 0 {
+		path := r.currentPath()
+		path[len(path)-1] = IndexElement(indexB)
+		options = options.at(path)
+	}
+	return options.IgnoreEmpty && value.IsEmpty()
+}
@@ -0,0 +1,214 @@
+package msgpackdiff
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestIgnorePaths(t *testing.T) {
+	type IgnoreTest struct {
+		Name     string
+		A        string
+		B        string
+		Paths    []string
+		Options  CompareOptions
+		Expected bool
+		Ignored  int
+		// the type and path of each difference, other than replacements
+		Differences []string
+	}
+
+	txnA := `{"txn": {"fv": 10, "lv": 20, "amt": 5}, "sig": "x"}`
+	txnB := `{"txn": {"fv": 11, "lv": 21, "amt": 5}, "sig": "y"}`
+
+	tests := []IgnoreTest{
+		{
+			Name:     "changed fields",
+			A:        txnA,
+			B:        txnB,
+			Paths:    []string{"txn.fv", "txn.lv", "sig"},
+			Expected: true,
+			Ignored:  3,
+		},
+		{
+			Name:        "other fields",
+			A:           txnA,
+			B:           txnB,
+			Paths:       []string{"txn.fv"},
+			Expected:    false,
+			Ignored:     1,
+			Differences: []string{"deletion [0].txn.lv", "deletion [0].sig"},
+		},
+		{
+			Name:     "wildcard key",
+			A:        `{"a": {"sig": 1, "v": 1}, "b": {"sig": 2, "v": 2}}`,
+			B:        `{"a": {"sig": 3, "v": 1}, "b": {"sig": 4, "v": 2}}`,
+			Paths:    []string{"*.sig"},
+			Expected: true,
+			Ignored:  2,
+		},
+		{
+			Name:        "wildcard key at other depths",
+			A:           `{"sig": 1, "a": {"b": {"sig": 2}}}`,
+			B:           `{"sig": 3, "a": {"b": {"sig": 4}}}`,
+			Paths:       []string{"*.sig"},
+			Expected:    false,
+			Differences: []string{"deletion [0].sig", "deletion [0].a.b.sig"},
+		},
+		{
+			Name:     "recursive wildcard",
+			A:        `{"sig": 1, "a": {"sig": 2, "b": [{"sig": 3, "v": 1}]}}`,
+			B:        `{"sig": 4, "a": {"sig": 5, "b": [{"sig": 6, "v": 1}]}}`,
+			Paths:    []string{"**.sig"},
+			Expected: true,
+			Ignored:  3,
+		},
+		{
+			Name:     "recursive wildcard in unordered arrays",
+			A:        `{"txns": [{"id": 1, "m": {"ts": 1}}, {"id": 2, "m": {"ts": 2}}]}`,
+			B:        `{"txns": [{"id": 2, "m": {"ts": 3}}, {"id": 1, "m": {"ts": 4}}]}`,
+			Paths:    []string{"**.ts"},
+			Options:  CompareOptions{UnorderedArrays: true},
+			Expected: true,
+			Ignored:  2,
+		},
+		{
+			Name:     "missing fields",
+			A:        `{"txn": {"fv": 10, "amt": 5}, "sig": "x"}`,
+			B:        `{"txn": {"amt": 5, "lv": 20}}`,
+			Paths:    []string{"txn.fv", "txn.lv", "sig"},
+			Expected: true,
+			Ignored:  3,
+		},
+		{
+			Name:     "missing fields with ignored order",
+			A:        `{"txn": {"fv": 10, "amt": 5}, "sig": "x"}`,
+			B:        `{"txn": {"amt": 5, "lv": 20}}`,
+			Paths:    []string{"txn.fv", "txn.lv", "sig"},
+			Options:  CompareOptions{IgnoreOrder: true},
+			Expected: true,
+			Ignored:  3,
+		},
+		{
+			Name:     "brief",
+			A:        `{"txn": {"fv": 10, "amt": 5}, "sig": "x"}`,
+			B:        `{"txn": {"amt": 5}}`,
+			Paths:    []string{"txn.fv", "sig"},
+			Options:  CompareOptions{Brief: true},
+			Expected: true,
+		},
+		{
+			Name:     "array elements",
+			A:        `{"blocks": [{"ts": 1, "rnd": 1}, {"ts": 2, "rnd": 2}]}`,
+			B:        `{"blocks": [{"ts": 5, "rnd": 1}, {"ts": 6, "rnd": 2}]}`,
+			Paths:    []string{"blocks[*].ts"},
+			Expected: true,
+			Ignored:  2,
+		},
+		{
+			Name:        "array element index",
+			A:           `{"list": [1, 2, 3]}`,
+			B:           `{"list": [4, 2, 5]}`,
+			Paths:       []string{"list[0]"},
+			Expected:    false,
+			Ignored:     1,
+			Differences: []string{"deletion [0].list[2]", "addition [0].list[3]"},
+		},
+		{
+			Name:     "top-level objects",
+			A:        `{"ts": 1, "v": 1} {"ts": 2, "v": 2}`,
+			B:        `{"ts": 3, "v": 1} {"ts": 4, "v": 2}`,
+			Paths:    []string{"ts"},
+			Expected: true,
+			Ignored:  2,
+		},
+		{
+			Name:     "keyed arrays",
+			A:        `{"accounts": [{"addr": "A", "rnd": 1}, {"addr": "B", "rnd": 2}]}`,
+			B:        `{"accounts": [{"addr": "A", "rnd": 3}, {"addr": "B", "rnd": 4}]}`,
+			Paths:    []string{"accounts[*].rnd"},
+			Options:  CompareOptions{ArrayKeys: []ArrayKey{{Path: Path{KeyElement(StringKey("accounts"))}, Key: Path{KeyElement(StringKey("addr"))}}}},
+			Expected: true,
+			Ignored:  2,
+		},
+		{
+			Name:     "unordered arrays",
+			A:        `{"txns": [{"id": 1, "ts": 1}, {"id": 2, "ts": 2}]}`,
+			B:        `{"txns": [{"id": 2, "ts": 3}, {"id": 1, "ts": 4}]}`,
+			Paths:    []string{"txns[*].ts"},
+			Options:  CompareOptions{UnorderedArrays: true},
+			Expected: true,
+			Ignored:  2,
+		},
+		{
+			Name:        "unordered arrays with other differences",
+			A:           `{"txns": [{"id": 1, "ts": 1}, {"id": 2, "ts": 2}]}`,
+			B:           `{"txns": [{"id": 3, "ts": 3}, {"id": 1, "ts": 4}]}`,
+			Paths:       []string{"txns[*].ts"},
+			Options:     CompareOptions{UnorderedArrays: true},
+			Expected:    false,
+			Ignored:     1,
+			Differences: []string{"deletion [0].txns[1]", "addition [0].txns[2]"},
+		},
+		{
+			Name:     "whole array elements",
+			A:        `{"list": [1, 2, 3]}`,
+			B:        `{"list": [4, "x"]}`,
+			Paths:    []string{"list[*]"},
+			Expected: true,
+			Ignored:  3,
+		},
+	}
+
+	diffTypes := map[DifferenceType]string{Deletion: "deletion", Addition: "addition", Move: "move"}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			objectsA, err := ParseJSON([]byte(test.A), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			objectsB, err := ParseJSON([]byte(test.B), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			for _, str := range test.Paths {
+				path, err := ParsePath(str)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v\n", err)
+				}
+				test.Options.IgnorePaths = append(test.Options.IgnorePaths, path)
+			}
+
+			result := CompareParsed(objectsA, objectsB, test.Options)
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+
+			if test.Options.Brief {
+				return
+			}
+
+			if result.Reporter.Ignored != test.Ignored {
+				t.Fatalf("Wrong ignored count: got %d, expected %d\n", result.Reporter.Ignored, test.Ignored)
+			}
+
+			differences := []string{}
+			for _, diff := range result.Reporter.Differences {
+				if diff.Type != Replacement {
+					differences = append(differences, fmt.Sprintf("%s %s", diffTypes[diff.Type], diffPath(diff)))
+				}
+			}
+			if test.Differences == nil {
+				test.Differences = []string{}
+			}
+			if !reflect.DeepEqual(differences, test.Differences) {
+				t.Fatalf("Wrong differences: got %v, expected %v\n", differences, test.Differences)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
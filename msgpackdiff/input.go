@@ -0,0 +1,269 @@
+package msgpackdiff
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// InputEncoding is the way that the data of an object is encoded.
+type InputEncoding int
+
+// The encodings of input data. InputAuto detects the encoding from the data.
+const (
+	InputAuto InputEncoding = iota
+	InputBase64
+	InputBase64URL
+	InputHex
+	InputBinary
+)
+
+var inputEncodingNames = map[InputEncoding]string{
+	InputAuto:      "auto",
+	InputBase64:    "b64",
+	InputBase64URL: "b64url",
+	InputHex:       "hex",
+	InputBinary:    "binary",
+}
+
+func (e InputEncoding) String() string {
+	return inputEncodingNames[e]
+}
+
+// ParseInputEncoding returns the InputEncoding with the given name, which is one of auto, b64,
+// b64url, hex or binary.
+func ParseInputEncoding(name string) (InputEncoding, error) {
+	for encoding, encodingName := range inputEncodingNames {
+		if name == encodingName {
+			return encoding, nil
+		}
+	}
+	return InputAuto, fmt.Errorf("Unknown input format %q", name)
+}
+
+// InputSource is where the data of an object comes from.
+type InputSource int
+
+// The sources of input data.
+const (
+	SourceArgument InputSource = iota
+	SourceFile
+	SourceStdin
+)
+
+// Input describes how the data of an object was read.
+type Input struct {
+	Source InputSource
+	// The path of the file, if Source is SourceFile.
+	Path string
+	// The encoding that the data was decoded with. This is never InputAuto.
+	Encoding InputEncoding
+	// The compression that was removed from the content of the file or stdin before decoding. This
+	// is CompressionNone for arguments.
+	Compression Compression
+}
+
+func (input Input) String() string {
+	decoding := fmt.Sprintf("decoded as %s", input.Encoding)
+	if input.Compression != CompressionNone {
+		decoding = fmt.Sprintf("decompressed with %s and %s", input.Compression, decoding)
+	}
+
+	switch input.Source {
+	case SourceFile:
+		return fmt.Sprintf("file %s %s", input.Path, decoding)
+	case SourceStdin:
+		return fmt.Sprintf("stdin %s", decoding)
+	}
+	return fmt.Sprintf("argument %s", decoding)
+}
+
+// InputOptions are the options used in a call to ReadInput.
+type InputOptions struct {
+	// The encoding of the data. If InputAuto, the encoding is detected.
+	Encoding InputEncoding
+	// The compression of the content of files and stdin. If CompressionAuto, the compression is
+	// detected.
+	Compression Compression
+	// The reader used for the object "-". If nil, os.Stdin is used.
+	Stdin io.Reader
+}
+
+// ReadInput gathers the binary content of a string that represents a MessagePack object. The string
+// may start with a prefix that says where the object comes from:
+//   - "b64:", "b64url:" or "hex:" followed by the object encoded with standard base64, URL-safe
+//     base64 or hexadecimal,
+//   - "file:" followed by the path of a file that contains the object, or
+//   - "-" alone, which reads the object from stdin.
+//
+// The content of files and stdin is first decompressed with options.Compression, then decoded with
+// options.Encoding. If it is InputAuto, the content is decoded as hex or base64 if possible and used
+// as binary otherwise.
+//
+// A string without a prefix is decoded with options.Encoding, or as the path of a file if it is
+// InputBinary. If it is InputAuto, the string is the path of a file if that file exists, since many
+// file names are also valid hex or base64, and is otherwise decoded as hex or base64 if possible.
+//
+// The returned Input describes how the object was read.
+func ReadInput(object string, options InputOptions) (bin []byte, input Input, err error) {
+	prefixes := []struct {
+		prefix   string
+		encoding InputEncoding
+	}{
+		{"b64:", InputBase64},
+		{"b64url:", InputBase64URL},
+		{"hex:", InputHex},
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(object, p.prefix) {
+			input = Input{Source: SourceArgument, Encoding: p.encoding, Compression: CompressionNone}
+			bin, err = decodeInput(object[len(p.prefix):], p.encoding)
+			return
+		}
+	}
+
+	var content []byte
+	switch {
+	case strings.HasPrefix(object, "file:"):
+		input = Input{Source: SourceFile, Path: object[len("file:"):]}
+		content, err = ioutil.ReadFile(input.Path)
+	case object == "-":
+		input = Input{Source: SourceStdin}
+		stdin := options.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		content, err = ioutil.ReadAll(stdin)
+	case options.Encoding == InputAuto && !FileExists(object):
+		// an argument without a prefix is encoded data if it is not a file and can be decoded
+		var encoding InputEncoding
+		bin, encoding, err = detectInput(object)
+		if err == nil {
+			input = Input{Source: SourceArgument, Encoding: encoding, Compression: CompressionNone}
+			return
+		}
+		input = Input{Source: SourceFile, Path: object}
+		content, err = ioutil.ReadFile(object)
+	case options.Encoding == InputAuto || options.Encoding == InputBinary:
+		input = Input{Source: SourceFile, Path: object}
+		content, err = ioutil.ReadFile(object)
+	default:
+		input = Input{Source: SourceArgument, Encoding: options.Encoding, Compression: CompressionNone}
+		bin, err = decodeInput(object, options.Encoding)
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	var decompressed io.Reader
+	decompressed, input.Compression, err = Decompress(bytes.NewReader(content), options.Compression)
+	if err != nil {
+		return
+	}
+	content, err = ioutil.ReadAll(decompressed)
+	if err != nil {
+		err = fmt.Errorf("Failed to decompress %s: %w", input.Compression, err)
+		return
+	}
+
+	input.Encoding = options.Encoding
+	if input.Encoding == InputAuto {
+		// attempt to decode from a text encoding
+		bin, input.Encoding, err = detectInput(string(content))
+		if err == nil {
+			return
+		}
+		input.Encoding = InputBinary
+	}
+
+	bin, err = decodeInput(string(content), input.Encoding)
+	return
+}
+
+// detectInput decodes data with the first text encoding that can decode it. Data that only has hex
+// digits is decoded as hex, even though it may also be valid base64. Otherwise standard base64 is
+// tried before URL-safe base64.
+func detectInput(data string) ([]byte, InputEncoding, error) {
+	if isHex(data) {
+		bin, err := decodeInput(data, InputHex)
+		return bin, InputHex, err
+	}
+
+	for _, encoding := range []InputEncoding{InputBase64, InputBase64URL} {
+		bin, err := decodeInput(data, encoding)
+		if err == nil {
+			return bin, encoding, nil
+		}
+	}
+
+	return nil, InputAuto, errors.New("Input is not hex or base64")
+}
+
+// FileExists returns true if path is the path of an existing file, which ReadInput reads as a file
+// when the encoding is InputAuto.
+func FileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// decodeInput decodes data that is encoded with encoding, which must not be InputAuto. Whitespace
+// around text encodings is ignored, and the padding of base64 is optional. Hex may be separated by
+// whitespace or commas, and each byte may have the prefix 0x.
+func decodeInput(data string, encoding InputEncoding) ([]byte, error) {
+	switch encoding {
+	case InputBase64:
+		return base64.RawStdEncoding.DecodeString(trimBase64(data))
+	case InputBase64URL:
+		return base64.RawURLEncoding.DecodeString(trimBase64(data))
+	case InputHex:
+		return hex.DecodeString(normalizeHex(data))
+	case InputBinary:
+		return []byte(data), nil
+	}
+	return nil, errors.New("Input encoding must be known")
+}
+
+// trimBase64 removes the whitespace around base64 data and its padding.
+func trimBase64(data string) string {
+	return strings.TrimRight(strings.TrimSpace(data), "=")
+}
+
+// normalizeHex removes the separators and 0x prefixes from hex data.
+func normalizeHex(data string) string {
+	var str strings.Builder
+	for _, field := range strings.FieldsFunc(data, isHexSeparator) {
+		if strings.HasPrefix(field, "0x") || strings.HasPrefix(field, "0X") {
+			field = field[2:]
+		}
+		str.WriteString(field)
+	}
+	return str.String()
+}
+
+func isHexSeparator(c rune) bool {
+	return c == ',' || unicode.IsSpace(c)
+}
+
+// isHex returns true if data is a non-empty sequence of bytes written in hex.
+func isHex(data string) bool {
+	normalized := normalizeHex(data)
+	if len(normalized) == 0 || len(normalized)%2 != 0 {
+		return false
+	}
+	for _, c := range normalized {
+		isDigit := c >= '0' && c <= '9'
+		isLetter := (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+		if !isDigit && !isLetter {
+			return false
+		}
+	}
+	return true
+}
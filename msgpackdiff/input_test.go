@@ -0,0 +1,323 @@
+package msgpackdiff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadInput(t *testing.T) {
+	type ReadInputTest struct {
+		Name     string
+		Input    string
+		Options  InputOptions
+		Expected []byte
+		Decoding Input
+	}
+
+	algoTxn, err := ioutil.ReadFile("../test/algo_txn_binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "msgpackdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a file whose name is valid base64
+	base64Name := filepath.Join(dir, "abcd")
+	err = ioutil.WriteFile(base64Name, []byte{0x01}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// raw MessagePack that starts with the magic bytes of zlib
+	zlibLike := filepath.Join(dir, "zlib-like")
+	err = ioutil.WriteFile(zlibLike, []byte{0x78, 0x01, 0x02}, 0644) // 120 1 2
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hexFile := filepath.Join(dir, "hex.txt")
+	err = ioutil.WriteFile(hexFile, []byte("81 a2 70 69 cb 40 09 21\nfb 54 44 2d 18\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pi := []byte{129, 162, 112, 105, 203, 64, 9, 33, 251, 84, 68, 45, 24} // {"pi": 3.141592653589793}
+
+	var gzipBase64 bytes.Buffer
+	writer := gzip.NewWriter(&gzipBase64)
+	writer.Write([]byte("gaJwactACSH7VEQtGA==\n"))
+	writer.Close()
+
+	gzipFile, err := ioutil.ReadFile("../test/algo_txn_binary.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []ReadInputTest{
+		{
+			Name:     "auto base64",
+			Input:    "gaJwactACSH7VEQtGA==",
+			Expected: pi,
+			Decoding: Input{Source: SourceArgument, Encoding: InputBase64, Compression: CompressionNone},
+		},
+		{
+			Name:     "auto binary file",
+			Input:    "../test/algo_txn_binary",
+			Expected: algoTxn,
+			Decoding: Input{Source: SourceFile, Path: "../test/algo_txn_binary", Encoding: InputBinary, Compression: CompressionNone},
+		},
+		{
+			Name:     "auto base64 file",
+			Input:    "../test/algo_txn_base64",
+			Expected: algoTxn,
+			Decoding: Input{Source: SourceFile, Path: "../test/algo_txn_base64", Encoding: InputBase64, Compression: CompressionNone},
+		},
+		{
+			Name:     "b64 prefix",
+			Input:    "b64:gaJwactACSH7VEQtGA==",
+			Expected: pi,
+			Decoding: Input{Source: SourceArgument, Encoding: InputBase64, Compression: CompressionNone},
+		},
+		{
+			Name:     "b64url prefix",
+			Input:    "b64url:gaJwactACSH7VEQtGA==",
+			Expected: pi,
+			Decoding: Input{Source: SourceArgument, Encoding: InputBase64URL, Compression: CompressionNone},
+		},
+		{
+			Name:     "hex prefix",
+			Input:    "hex:81a27069cb400921fb54442d18",
+			Expected: pi,
+			Decoding: Input{Source: SourceArgument, Encoding: InputHex, Compression: CompressionNone},
+		},
+		{
+			Name:     "file prefix with base64 name",
+			Input:    "file:" + base64Name,
+			Expected: []byte{0x01},
+			Decoding: Input{Source: SourceFile, Path: base64Name, Encoding: InputBinary, Compression: CompressionNone},
+		},
+		{
+			Name:     "stdin",
+			Input:    "-",
+			Options:  InputOptions{Stdin: strings.NewReader("gaJwactACSH7VEQtGA==\n")},
+			Expected: pi,
+			Decoding: Input{Source: SourceStdin, Encoding: InputBase64, Compression: CompressionNone},
+		},
+		{
+			Name:     "stdin hex",
+			Input:    "-",
+			Options:  InputOptions{Encoding: InputHex, Stdin: strings.NewReader("81a27069cb400921fb54442d18\n")},
+			Expected: pi,
+			Decoding: Input{Source: SourceStdin, Encoding: InputHex, Compression: CompressionNone},
+		},
+		{
+			Name:     "forced hex",
+			Input:    "81a27069cb400921fb54442d18",
+			Options:  InputOptions{Encoding: InputHex},
+			Expected: pi,
+			Decoding: Input{Source: SourceArgument, Encoding: InputHex, Compression: CompressionNone},
+		},
+		{
+			Name:     "forced binary",
+			Input:    base64Name,
+			Options:  InputOptions{Encoding: InputBinary},
+			Expected: []byte{0x01},
+			Decoding: Input{Source: SourceFile, Path: base64Name, Encoding: InputBinary, Compression: CompressionNone},
+		},
+		{
+			Name:     "gzip file",
+			Input:    "../test/algo_txn_binary.gz",
+			Expected: algoTxn,
+			Decoding: Input{Source: SourceFile, Path: "../test/algo_txn_binary.gz", Encoding: InputBinary, Compression: CompressionGzip},
+		},
+		{
+			Name:     "bzip2 file",
+			Input:    "file:../test/algo_txn_binary.bz2",
+			Expected: algoTxn,
+			Decoding: Input{Source: SourceFile, Path: "../test/algo_txn_binary.bz2", Encoding: InputBinary, Compression: CompressionBzip2},
+		},
+		{
+			Name:     "gzip base64 stdin",
+			Input:    "-",
+			Options:  InputOptions{Stdin: &gzipBase64},
+			Expected: pi,
+			Decoding: Input{Source: SourceStdin, Encoding: InputBase64, Compression: CompressionGzip},
+		},
+		{
+			Name:     "forced gzip",
+			Input:    "../test/algo_txn_binary.gz",
+			Options:  InputOptions{Compression: CompressionGzip},
+			Expected: algoTxn,
+			Decoding: Input{Source: SourceFile, Path: "../test/algo_txn_binary.gz", Encoding: InputBinary, Compression: CompressionGzip},
+		},
+		{
+			Name:     "disabled decompression",
+			Input:    "../test/algo_txn_binary.gz",
+			Options:  InputOptions{Compression: CompressionNone},
+			Expected: gzipFile,
+			Decoding: Input{Source: SourceFile, Path: "../test/algo_txn_binary.gz", Encoding: InputBinary, Compression: CompressionNone},
+		},
+		{
+			Name:     "forced hex file",
+			Input:    "file:" + hexFile,
+			Options:  InputOptions{Encoding: InputHex},
+			Expected: pi,
+			Decoding: Input{Source: SourceFile, Path: hexFile, Encoding: InputHex, Compression: CompressionNone},
+		},
+		{
+			Name:     "hex file",
+			Input:    "file:" + hexFile,
+			Expected: pi,
+			Decoding: Input{Source: SourceFile, Path: hexFile, Encoding: InputHex, Compression: CompressionNone},
+		},
+		{
+			Name:     "uncompressed msgpack file like zlib magic",
+			Input:    zlibLike,
+			Options:  InputOptions{Compression: CompressionNone},
+			Expected: []byte{0x78, 0x01, 0x02},
+			Decoding: Input{Source: SourceFile, Path: zlibLike, Encoding: InputBinary, Compression: CompressionNone},
+		},
+		{
+			Name:     "forced base64 file",
+			Input:    "file:../test/algo_txn_base64",
+			Options:  InputOptions{Encoding: InputBase64},
+			Expected: algoTxn,
+			Decoding: Input{Source: SourceFile, Path: "../test/algo_txn_base64", Encoding: InputBase64, Compression: CompressionNone},
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			result, decoding, err := ReadInput(test.Input, test.Options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if !bytes.Equal(result, test.Expected) {
+				t.Fatalf("Invalid binary: got %v, expected %v\n", result, test.Expected)
+			}
+
+			if decoding != test.Decoding {
+				t.Fatalf("Wrong decoding: got %v, expected %v\n", decoding, test.Decoding)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestReadInputFileNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msgpackdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// the files are read by their names alone, which are also valid hex or base64
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"deadbeef", "cafe", "dump", "abcd", "ledger", "txns_a"}
+	for _, name := range names {
+		runTest := func(t *testing.T) {
+			content := []byte{0x91, 0xa1, name[0]} // [name[0]]
+			err := ioutil.WriteFile(name, content, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, decoding, err := ReadInput(name, InputOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if !bytes.Equal(result, content) {
+				t.Fatalf("Invalid binary: got %v, expected %v\n", result, content)
+			}
+
+			expected := Input{Source: SourceFile, Path: name, Encoding: InputBinary, Compression: CompressionNone}
+			if decoding != expected {
+				t.Fatalf("Wrong decoding: got %v, expected %v\n", decoding, expected)
+			}
+		}
+		t.Run(name, runTest)
+	}
+
+	// without a file, a name that is hex is decoded
+	result, decoding, err := ReadInput("beef", InputOptions{})
+	if err != nil || decoding.Encoding != InputHex || !bytes.Equal(result, []byte{0xbe, 0xef}) {
+		t.Fatalf("Wrong result for missing file: got %v %v %v\n", result, decoding, err)
+	}
+}
+
+func TestReadInputErrors(t *testing.T) {
+	type ErrorTest struct {
+		Name    string
+		Input   string
+		Options InputOptions
+	}
+
+	dir, err := ioutil.TempDir("", "msgpackdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// raw MessagePack that starts with the magic bytes of zlib
+	zlibLike := filepath.Join(dir, "zlib-like")
+	err = ioutil.WriteFile(zlibLike, []byte{0x78, 0x01, 0x02}, 0644) // 120 1 2
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []ErrorTest{
+		{
+			Name:  "invalid hex",
+			Input: "hex:zz",
+		},
+		{
+			Name:    "forced hex",
+			Input:   "gaJwactACSH7VEQtGA==",
+			Options: InputOptions{Encoding: InputHex},
+		},
+		{
+			Name:    "forced bzip2",
+			Input:   "../test/algo_txn_binary.gz",
+			Options: InputOptions{Compression: CompressionBzip2},
+		},
+		{
+			Name:  "missing file",
+			Input: "file:does-not-exist",
+		},
+		{
+			Name:  "msgpack file like zlib magic",
+			Input: "file:" + zlibLike,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			_, _, err := ReadInput(test.Input, test.Options)
+			if err == nil {
+				t.Fatalf("No error for %q\n", test.Input)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
@@ -0,0 +1,198 @@
+package msgpackdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// ParseJSON parses every JSON value in data, which may hold any number of values one after another,
+// into the same in-memory data structure that ParseAll returns for MessagePack, so that JSON can be
+// compared to MessagePack. The keys of JSON objects keep their order in MsgpMap.Order.
+//
+// JSON numbers are converted to the types that MessagePack parsing would produce for the smallest
+// encoding of the same number:
+//   - integers from 0 to 127 become IntType, since they fit in a positive fixint,
+//   - larger integers up to the maximum uint64 become UintType,
+//   - negative integers down to the minimum int64 become IntType, and
+//   - numbers with a fraction or exponent, and integers out of those ranges, become Float64Type.
+//
+// JSON has no binary type, so binary data is usually encoded as a base64 string. Such strings are
+// parsed as StrType, and CompareOptions.Base64Binary compares them to binary data.
+//
+// The objects have no format, and their offsets are those of their text in data. If a value cannot
+// be parsed, the values before it are returned along with a *ParseError.
+func ParseJSON(data []byte, options ParseOptions) (objects []MsgpObject, err error) {
+	objects = []MsgpObject{}
+	p := jsonParser{
+		data:    data,
+		decoder: json.NewDecoder(bytes.NewReader(data)),
+		parser:  newParser(data, options),
+	}
+	p.decoder.UseNumber()
+	for {
+		var object MsgpObject
+		object, err = p.parseValue(0)
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		objects = append(objects, object)
+	}
+}
+
+// jsonParser holds the state of a call to ParseJSON.
+type jsonParser struct {
+	data    []byte
+	decoder *json.Decoder
+	parser  *parser
+}
+
+// parseValue parses the next JSON value, which is nested inside depth containers. It returns io.EOF
+// if there are no more values.
+func (p *jsonParser) parseValue(depth int) (parsed MsgpObject, err error) {
+	parsed.Start = p.nextOffset()
+	token, err := p.decoder.Token()
+	if err == io.EOF && depth == 0 {
+		return
+	}
+	if err != nil {
+		return parsed, p.errorAt(parsed.Start, err)
+	}
+
+	switch value := token.(type) {
+	case nil:
+		parsed.Type = msgp.NilType
+	case bool:
+		parsed.Type = msgp.BoolType
+		parsed.Value = value
+	case string:
+		parsed.Type = msgp.StrType
+		parsed.Value = value
+	case json.Number:
+		start := parsed.Start
+		parsed = jsonNumber(value)
+		parsed.Start = start
+	case json.Delim:
+		if depth >= p.parser.maxDepth {
+			return parsed, p.errorAt(parsed.Start, ErrMaxDepth)
+		}
+		if value == '[' {
+			parsed.Type = msgp.ArrayType
+			parsed.Value, err = p.parseArray(depth)
+		} else {
+			parsed.Type = msgp.MapType
+			parsed.Value, err = p.parseMap(depth)
+		}
+		if err != nil {
+			return
+		}
+		// the closing delimiter
+		_, err = p.decoder.Token()
+		if err != nil {
+			return parsed, p.errorAt(p.nextOffset(), err)
+		}
+	}
+
+	parsed.End = int(p.decoder.InputOffset())
+	return
+}
+
+// parseArray parses the elements of an array whose opening bracket has been read.
+func (p *jsonParser) parseArray(depth int) ([]MsgpObject, error) {
+	valueArray := []MsgpObject{}
+	for p.decoder.More() {
+		if len(valueArray) >= p.parser.maxContainerLength {
+			return valueArray, p.errorAt(p.nextOffset(), ErrMaxContainerLength)
+		}
+		item, err := p.parseValue(depth + 1)
+		if err != nil {
+			return valueArray, withPathElement(err, IndexElement(len(valueArray)))
+		}
+		valueArray = append(valueArray, item)
+	}
+	return valueArray, nil
+}
+
+// parseMap parses the entries of an object whose opening brace has been read.
+func (p *jsonParser) parseMap(depth int) (MsgpMap, error) {
+	valueMap := MsgpMap{
+		Order:  []MapKey{},
+		Values: map[MapKey]MsgpObject{},
+	}
+	for p.decoder.More() {
+		if len(valueMap.Order) >= p.parser.maxContainerLength {
+			return valueMap, p.errorAt(p.nextOffset(), ErrMaxContainerLength)
+		}
+		offset := p.nextOffset()
+		token, err := p.decoder.Token()
+		if err != nil {
+			return valueMap, p.errorAt(offset, err)
+		}
+		// the decoder only returns strings for keys
+		key := StringKey(token.(string))
+		if _, ok := valueMap.Values[key]; ok {
+			err = p.errorAt(offset, ErrDuplicateKey)
+			return valueMap, withPathElement(err, KeyElement(key))
+		}
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return valueMap, withPathElement(err, KeyElement(key))
+		}
+		valueMap.Order = append(valueMap.Order, key)
+		valueMap.Values[key] = value
+	}
+	return valueMap, nil
+}
+
+// nextOffset returns the offset of the next token in the input, skipping whitespace and the
+// separators between values.
+func (p *jsonParser) nextOffset() int {
+	offset := int(p.decoder.InputOffset())
+	for offset < len(p.data) && strings.IndexByte(" \t\r\n,:", p.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// errorAt returns a *ParseError for the value at offset. If the input ends before the value, the
+// error is io.ErrUnexpectedEOF, as it is for MessagePack.
+func (p *jsonParser) errorAt(offset int, err error) error {
+	typeByte := -1
+	if offset < len(p.data) {
+		typeByte = int(p.data[offset])
+	} else if _, ok := err.(*json.SyntaxError); ok || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return &ParseError{
+		Offset:   offset,
+		TypeByte: typeByte,
+		Err:      err,
+	}
+}
+
+// jsonNumber converts a JSON number to an integer or float object.
+func jsonNumber(number json.Number) MsgpObject {
+	str := number.String()
+	if !strings.ContainsAny(str, ".eE") {
+		if strings.HasPrefix(str, "-") {
+			if value, err := strconv.ParseInt(str, 10, 64); err == nil {
+				return MsgpObject{Type: msgp.IntType, Value: value}
+			}
+		} else if value, err := strconv.ParseUint(str, 10, 64); err == nil {
+			return unsignedObject(value)
+		}
+	}
+
+	// the decoder has checked the syntax, so the only error is a value that is out of range, for
+	// which ParseFloat returns an infinity
+	value, _ := number.Float64()
+	return MsgpObject{Type: msgp.Float64Type, Value: value}
+}
@@ -0,0 +1,303 @@
+package msgpackdiff
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+func TestParseJSON(t *testing.T) {
+	type ParseJSONTest struct {
+		Name     string
+		Input    string
+		Expected []MsgpObject
+	}
+
+	tests := []ParseJSONTest{
+		{
+			Name:  "key order",
+			Input: `{"b": 1, "a": "x", "c": null}`,
+			Expected: []MsgpObject{
+				{
+					Type: msgp.MapType,
+					Value: MsgpMap{
+						Order: []MapKey{StringKey("b"), StringKey("a"), StringKey("c")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("b"): {Type: msgp.IntType, Value: int64(1)},
+							StringKey("a"): {Type: msgp.StrType, Value: "x"},
+							StringKey("c"): {Type: msgp.NilType},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "numbers",
+			Input: `[0, 127, 128, 18446744073709551615, 18446744073709551616, -1, -9223372036854775809, 1.5, 1e2, true]`,
+			Expected: []MsgpObject{
+				{
+					Type: msgp.ArrayType,
+					Value: []MsgpObject{
+						{Type: msgp.IntType, Value: int64(0)},
+						{Type: msgp.IntType, Value: int64(127)},
+						{Type: msgp.UintType, Value: uint64(128)},
+						{Type: msgp.UintType, Value: uint64(math.MaxUint64)},
+						{Type: msgp.Float64Type, Value: float64(18446744073709551616)},
+						{Type: msgp.IntType, Value: int64(-1)},
+						{Type: msgp.Float64Type, Value: float64(-9223372036854775809)},
+						{Type: msgp.Float64Type, Value: 1.5},
+						{Type: msgp.Float64Type, Value: float64(100)},
+						{Type: msgp.BoolType, Value: true},
+					},
+				},
+			},
+		},
+		{
+			Name:  "nested",
+			Input: `{"a": [{}, []]}`,
+			Expected: []MsgpObject{
+				{
+					Type: msgp.MapType,
+					Value: MsgpMap{
+						Order: []MapKey{StringKey("a")},
+						Values: map[MapKey]MsgpObject{
+							StringKey("a"): {
+								Type: msgp.ArrayType,
+								Value: []MsgpObject{
+									{Type: msgp.MapType, Value: MsgpMap{Order: []MapKey{}, Values: map[MapKey]MsgpObject{}}},
+									{Type: msgp.ArrayType, Value: []MsgpObject{}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "stream",
+			Input: "1\n\"a\"\n",
+			Expected: []MsgpObject{
+				{Type: msgp.IntType, Value: int64(1)},
+				{Type: msgp.StrType, Value: "a"},
+			},
+		},
+		{
+			Name:     "empty",
+			Input:    " ",
+			Expected: []MsgpObject{},
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			objects, err := ParseJSON([]byte(test.Input), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			for i := range objects {
+				objects[i] = stripMetadata(objects[i])
+			}
+
+			if !reflect.DeepEqual(objects, test.Expected) {
+				t.Fatalf("Wrong objects: got %v, expected %v\n", objects, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestParseJSONOffsets(t *testing.T) {
+	objects, err := ParseJSON([]byte(`{"a": [1, "b"]}`), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	array := objects[0].Value.(MsgpMap).Values[StringKey("a")]
+	spans := []Span{objects[0].Span(), array.Span()}
+	for _, item := range array.Value.([]MsgpObject) {
+		spans = append(spans, item.Span())
+	}
+
+	expected := []Span{{0, 15}, {6, 14}, {7, 8}, {10, 13}}
+	if !reflect.DeepEqual(spans, expected) {
+		t.Fatalf("Wrong spans: got %v, expected %v\n", spans, expected)
+	}
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	type ErrorTest struct {
+		Name     string
+		Input    string
+		Options  ParseOptions
+		Err      error
+		Offset   int
+		Path     string
+		TypeByte int
+	}
+
+	tests := []ErrorTest{
+		{
+			Name:     "duplicate key",
+			Input:    `{"a": 1, "a": 2}`,
+			Err:      ErrDuplicateKey,
+			Offset:   9,
+			Path:     "a",
+			TypeByte: '"',
+		},
+		{
+			Name:     "truncated",
+			Input:    `{"a": [1, `,
+			Err:      io.ErrUnexpectedEOF,
+			Offset:   10,
+			Path:     "a[1]",
+			TypeByte: -1,
+		},
+		{
+			Name:     "depth",
+			Input:    `[[1]]`,
+			Options:  ParseOptions{MaxDepth: 1},
+			Err:      ErrMaxDepth,
+			Offset:   1,
+			Path:     "[0]",
+			TypeByte: '[',
+		},
+		{
+			Name:     "container length",
+			Input:    `[1, 2]`,
+			Options:  ParseOptions{MaxContainerLength: 1},
+			Err:      ErrMaxContainerLength,
+			Offset:   4,
+			TypeByte: '2',
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			_, err := ParseJSON([]byte(test.Input), test.Options)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a *ParseError, got %v\n", err)
+			}
+
+			if !errors.Is(err, test.Err) {
+				t.Fatalf("Wrong error: got %v, expected %v\n", parseErr.Err, test.Err)
+			}
+
+			if parseErr.Offset != test.Offset {
+				t.Fatalf("Wrong offset: got %d, expected %d\n", parseErr.Offset, test.Offset)
+			}
+
+			if parseErr.Path.String() != test.Path {
+				t.Fatalf("Wrong path: got %s, expected %s\n", parseErr.Path, test.Path)
+			}
+
+			if parseErr.TypeByte != test.TypeByte {
+				t.Fatalf("Wrong type byte: got %d, expected %d\n", parseErr.TypeByte, test.TypeByte)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestCompareJSON(t *testing.T) {
+	type CompareJSONTest struct {
+		Name     string
+		Msgpack  string
+		JSON     string
+		Options  CompareOptions
+		Expected bool
+	}
+
+	// {"amt": 1000, "note": bin("hi"), "fee": 1, "neg": -5, "pi": 1.5}
+	txn := "haNhbXTNA+ikbm90ZcQCaGmjZmVlAaNuZWf7onBpyz/4AAAAAAAA"
+
+	tests := []CompareJSONTest{
+		{
+			Name:     "base64 binary",
+			Msgpack:  txn,
+			JSON:     `{"amt": 1000, "note": "aGk=", "fee": 1, "neg": -5, "pi": 1.5}`,
+			Options:  CompareOptions{Base64Binary: true},
+			Expected: true,
+		},
+		{
+			Name:     "binary is not a string",
+			Msgpack:  txn,
+			JSON:     `{"amt": 1000, "note": "aGk=", "fee": 1, "neg": -5, "pi": 1.5}`,
+			Expected: false,
+		},
+		{
+			Name:     "wrong base64",
+			Msgpack:  txn,
+			JSON:     `{"amt": 1000, "note": "aGl=", "fee": 1, "neg": -5, "pi": 1.5}`,
+			Options:  CompareOptions{Base64Binary: true},
+			Expected: false,
+		},
+		{
+			Name:     "key order",
+			Msgpack:  txn,
+			JSON:     `{"note": "aGk=", "amt": 1000, "fee": 1, "neg": -5, "pi": 1.5}`,
+			Options:  CompareOptions{Base64Binary: true},
+			Expected: false,
+		},
+		{
+			Name:     "ignored key order",
+			Msgpack:  txn,
+			JSON:     `{"note": "aGk=", "amt": 1000, "fee": 1, "neg": -5, "pi": 1.5}`,
+			Options:  CompareOptions{Base64Binary: true, IgnoreOrder: true},
+			Expected: true,
+		},
+		{
+			Name:     "float for integer",
+			Msgpack:  txn,
+			JSON:     `{"amt": 1000.0, "note": "aGk=", "fee": 1, "neg": -5, "pi": 1.5}`,
+			Options:  CompareOptions{Base64Binary: true},
+			Expected: false,
+		},
+		{
+			Name:     "flexible float for integer",
+			Msgpack:  txn,
+			JSON:     `{"amt": 1000.0, "note": "aGk=", "fee": 1, "neg": -5, "pi": 1.5}`,
+			Options:  CompareOptions{Base64Binary: true, FlexibleTypes: true},
+			Expected: true,
+		},
+		{
+			Name:     "binary in array",
+			Msgpack:  "ksQCaGmjZmVl", // [bin("hi"), "fee"]
+			JSON:     `["aGk=", "fee"]`,
+			Options:  CompareOptions{Base64Binary: true},
+			Expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			bin, err := base64.StdEncoding.DecodeString(test.Msgpack)
+			if err != nil {
+				t.Fatalf("Could not decode object \"%v\": %v\n", test.Msgpack, err)
+			}
+
+			objectsA, err := ParseAll(bin, ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			objectsB, err := ParseJSON([]byte(test.JSON), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			result := CompareParsed(objectsA, objectsB, test.Options)
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
@@ -0,0 +1,353 @@
+package msgpackdiff
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"time"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// unorderedAt returns true if the array at path is compared as a multiset. The path starts with the
+// index of the top-level object that the array is in.
+func unorderedAt(path Path, options CompareOptions) bool {
+	if len(path) == 0 {
+		// the top-level objects are aligned by position or with AlignKey
+		return false
+	}
+	if options.UnorderedArrays {
+		return true
+	}
+	for _, pattern := range options.UnorderedPaths {
+		if pattern.Match(path[1:]) {
+			return true
+		}
+	}
+	for _, rule := range options.Rules {
+		if rule.Unordered && rule.Path.Match(path[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareMultisets compares the elements of two arrays regardless of their order. Each element of
+// arrayA is matched with an equal element of arrayB that has not been matched yet, and the elements
+// that are left over on either side are reported as deletions or additions. The path is the path to
+// the arrays, and the reporter must have entered them.
+//
+// Only elements with the same fingerprint are compared with each other, so the comparison takes
+// linear time unless many elements have the same fingerprint. Numbers that are equal within a
+// tolerance cannot be told apart by their fingerprints, so arrays of such numbers take quadratic
+// time.
+//
+// Elements are matched greedily. Since numbers that are equal within a tolerance are not always
+// equal to the same numbers, elements that are exactly equal are matched first, and only the
+// remaining elements are matched within the tolerance. This can still leave elements unmatched
+// when another pairing of the remaining elements would match all of them.
+func compareMultisets(reporter *Reporter, arrayA []MsgpObject, arrayB []MsgpObject, path Path, options CompareOptions) (equal bool) {
+	equal = true
+
+	if options.Brief && !options.skipsObjects() && len(arrayA) != len(arrayB) {
+		return false
+	}
+
+	// the path of every element, since elements at different indices are compared
+	var elementPath Path
+	if len(options.IgnorePaths) != 0 || len(options.Rules) != 0 {
+		elementPath = append(path[:len(path):len(path)], AnyIndex)
+	}
+
+	// ignored elements are treated as already matched
+	matchedB := make([]bool, len(arrayB))
+	for indexB := range arrayB {
+		matchedB[indexB] = reporter.ignoreElementB(indexB, options)
+	}
+	matchedA := make([]bool, len(arrayA))
+	for indexA := range arrayA {
+		reporter.SetIndex(indexA)
+		matchedA[indexA] = reporter.ignore(options)
+	}
+
+	if options.usesTolerance() {
+		matchElements(reporter, arrayA, arrayB, matchedA, matchedB, path, elementPath, options.withoutTolerance())
+	}
+	matchElements(reporter, arrayA, arrayB, matchedA, matchedB, path, elementPath, options)
+
+	for indexA, itemA := range arrayA {
+		if matchedA[indexA] {
+			continue
+		}
+		reporter.SetIndex(indexA)
+		if !reporter.ignoreEmpty(itemA, options) {
+			reporter.LogDeletion(itemA)
+			equal = false
+			if options.Brief {
+				return
+			}
+		}
+	}
+
+	reporter.SetIndex(len(arrayA))
+	for indexB, itemB := range arrayB {
+		if !matchedB[indexB] && !reporter.ignoreEmptyElementB(itemB, indexB, options) {
+			reporter.LogAddition(itemB)
+			equal = false
+			if options.Brief {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// matchElements matches each element of arrayA that is not matched yet with the first equal
+// element of arrayB that is not matched yet, and marks both of them as matched.
+func matchElements(reporter *Reporter, arrayA []MsgpObject, arrayB []MsgpObject, matchedA []bool, matchedB []bool, path Path, elementPath Path, options CompareOptions) {
+	// the unmatched elements of arrayB by their fingerprints
+	buckets := make(map[uint64][]int, len(arrayB))
+	for indexB, item := range arrayB {
+		if matchedB[indexB] {
+			continue
+		}
+		key := fingerprint(item, elementPath, options)
+		buckets[key] = append(buckets[key], indexB)
+	}
+
+	for indexA, itemA := range arrayA {
+		if matchedA[indexA] {
+			continue
+		}
+
+		key := fingerprint(itemA, elementPath, options)
+		bucket := buckets[key]
+
+		for i, indexB := range bucket {
+			elementReporter := Reporter{
+				Brief:  true,
+				prefix: append(path[:len(path):len(path)], IndexElement(indexA)),
+			}
+			if compareObjects(&elementReporter, itemA, arrayB[indexB], options) {
+				buckets[key] = append(bucket[:i:i], bucket[i+1:]...)
+				matchedA[indexA] = true
+				matchedB[indexB] = true
+				reporter.Ignored += elementReporter.Ignored
+				reporter.BinaryStrings += elementReporter.BinaryStrings
+				break
+			}
+		}
+	}
+}
+
+// The first bytes written by fingerprint for each kind of object.
+const (
+	fingerprintNil byte = iota
+	fingerprintBool
+	fingerprintNumber
+	fingerprintString
+	fingerprintBytes
+	fingerprintArray
+	fingerprintMap
+	fingerprintExtension
+	fingerprintTag
+	fingerprintTime
+)
+
+// fingerprint returns a hash of object such that objects that are equal according to
+// compareObjects with options have the same fingerprint. Objects that are not equal usually have
+// different fingerprints. The order of the elements of arrays and maps is not part of the
+// fingerprint, since they may be compared regardless of their order.
+//
+// The path is the path to object, with wildcards for the indices of array elements, which is only
+// needed to leave out the descendants that may be ignored.
+func fingerprint(object MsgpObject, path Path, options CompareOptions) uint64 {
+	h := fnv.New64a()
+	writeFingerprint(h, object, path, options)
+	return h.Sum64()
+}
+
+// writeFingerprint writes the data that the fingerprint of object is the hash of to h.
+func writeFingerprint(h hash.Hash64, object MsgpObject, path Path, options CompareOptions) {
+	options = options.fingerprintOptions(path)
+
+	// the path of a descendant of object, if there are ignored paths or rules
+	childPath := func(element PathElement) Path {
+		if len(options.IgnorePaths) == 0 && len(options.Rules) == 0 {
+			return nil
+		}
+		return append(path[:len(path):len(path)], element)
+	}
+
+	var buf [8]byte
+	writeUint := func(value uint64) {
+		binary.BigEndian.PutUint64(buf[:], value)
+		h.Write(buf[:])
+	}
+
+	switch object.Type {
+	case msgp.NilType:
+		h.Write([]byte{fingerprintNil})
+	case msgp.BoolType:
+		value := byte(0)
+		if object.Value.(bool) {
+			value = 1
+		}
+		h.Write([]byte{fingerprintBool, value})
+	case msgp.IntType, msgp.UintType, msgp.Float32Type, msgp.Float64Type, msgp.Complex64Type, msgp.Complex128Type:
+		h.Write([]byte{fingerprintNumber})
+		writeNumberFingerprint(h, object, options)
+	case msgp.StrType:
+		str := object.Value.(string)
+		if options.BinaryStrings && options.Base64Binary {
+			// a string may be equal to its own bytes and to the binary data it is the base64
+			// encoding of, which are different, so strings cannot be told apart from binary data
+			h.Write([]byte{fingerprintBytes})
+			break
+		}
+		if options.BinaryStrings {
+			// a string is equal to binary data with the same bytes
+			h.Write([]byte{fingerprintBytes})
+			h.Write([]byte(str))
+			break
+		}
+		if options.Base64Binary {
+			// a string is equal to the binary data that it is the base64 encoding of
+			if decoded, err := base64.StdEncoding.Strict().DecodeString(str); err == nil {
+				h.Write([]byte{fingerprintBytes})
+				h.Write(decoded)
+				break
+			}
+		}
+		h.Write([]byte{fingerprintString})
+		h.Write([]byte(str))
+	case msgp.BinType:
+		h.Write([]byte{fingerprintBytes})
+		if !(options.BinaryStrings && options.Base64Binary) {
+			h.Write(object.Value.([]byte))
+		}
+	case msgp.ArrayType:
+		var sum uint64
+		itemPath := childPath(AnyIndex)
+		for _, item := range object.Value.([]MsgpObject) {
+			if (item.IsEmpty() && options.fingerprintOptions(itemPath).IgnoreEmpty) || mayBeIgnored(itemPath, options) {
+				continue
+			}
+			sum += mix(fingerprint(item, itemPath, options))
+		}
+		h.Write([]byte{fingerprintArray})
+		writeUint(sum)
+	case msgp.MapType:
+		var sum uint64
+		valueMap := object.Value.(MsgpMap)
+		for _, key := range valueMap.Order {
+			value := valueMap.Values[key]
+			valuePath := childPath(KeyElement(key))
+			if (value.IsEmpty() && options.fingerprintOptions(valuePath).IgnoreEmpty) || mayBeIgnored(valuePath, options) {
+				continue
+			}
+			entry := fnv.New64a()
+			entry.Write([]byte(key.String()))
+			writeFingerprint(entry, value, valuePath, options)
+			sum += mix(entry.Sum64())
+		}
+		h.Write([]byte{fingerprintMap})
+		writeUint(sum)
+	case msgp.ExtensionType:
+		switch value := object.Value.(type) {
+		case Extension:
+			// extensions with different payloads may be equal if their decoded values are
+			h.Write([]byte{fingerprintExtension, byte(value.Type)})
+		case Tag:
+			h.Write([]byte{fingerprintTag})
+			writeUint(value.Number)
+			// the contents of tags are compared without ignoring any of their descendants
+			contentOptions := options
+			contentOptions.IgnorePaths = nil
+			contentOptions.Rules = nil
+			writeFingerprint(h, value.Content, nil, contentOptions)
+		}
+	case msgp.TimeType:
+		t := object.Value.(time.Time)
+		h.Write([]byte{fingerprintTime})
+		writeUint(uint64(t.Unix()))
+		writeUint(uint64(t.Nanosecond()))
+	}
+}
+
+// integerValue returns the value of a number as a sign and a magnitude, if it is a whole number
+// whose magnitude fits in a uint64.
+func integerValue(object MsgpObject) (negative bool, magnitude uint64, ok bool) {
+	switch object.Type {
+	case msgp.IntType:
+		i := object.Value.(int64)
+		if i < 0 {
+			// this is also correct for math.MinInt64, whose negation overflows to itself
+			return true, uint64(-i), true
+		}
+		return false, uint64(i), true
+	case msgp.UintType:
+		return false, object.Value.(uint64), true
+	}
+
+	value, imaginary, _, ok := numberParts(object)
+	if !ok || imaginary != 0 || value != math.Trunc(value) || math.Abs(value) >= 1<<64 {
+		return false, 0, false
+	}
+	if value < 0 {
+		return true, uint64(-value), true
+	}
+	return false, uint64(value), true
+}
+
+// writeNumberFingerprint writes the part of the fingerprint of a number that follows its kind.
+func writeNumberFingerprint(h hash.Hash64, object MsgpObject, options CompareOptions) {
+	value, imaginary, _, _ := numberParts(object)
+	// NaNs may be equal to each other regardless of their payloads
+	if math.IsNaN(value) {
+		value = math.NaN()
+	}
+	if math.IsNaN(imaginary) {
+		imaginary = math.NaN()
+	}
+
+	if !options.Tolerance.isZero() {
+		// numbers that are close to each other are equal, so they cannot be told apart
+		return
+	}
+
+	var buf [17]byte
+	if options.FlexibleTypes {
+		// numbers of different types are equal if they have exactly the same value, and integers
+		// may not be exactly representable as floats, so whole numbers are written as integers
+		if negative, magnitude, ok := integerValue(object); ok {
+			binary.BigEndian.PutUint64(buf[:], magnitude)
+			if negative {
+				buf[8] = 1
+			}
+			h.Write(buf[:9])
+			return
+		}
+	} else {
+		// numbers of different types are never equal
+		buf[16] = byte(object.Type)
+	}
+	// -0 is equal to 0
+	binary.BigEndian.PutUint64(buf[:], math.Float64bits(value+0))
+	binary.BigEndian.PutUint64(buf[8:], math.Float64bits(imaginary+0))
+	h.Write(buf[:])
+}
+
+// mix scrambles the bits of a fingerprint, so that the sums of the fingerprints of different
+// elements are unlikely to be equal. It is the finalizer of SplitMix64.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
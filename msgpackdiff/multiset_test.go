@@ -0,0 +1,330 @@
+package msgpackdiff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareMultisets(t *testing.T) {
+	type MultisetTest struct {
+		Name     string
+		A        string
+		B        string
+		Paths    []string
+		Keys     []string
+		Options  CompareOptions
+		Expected bool
+		// the type and path of each difference, other than replacements
+		Differences []string
+	}
+
+	tests := []MultisetTest{
+		{
+			Name:     "reordered",
+			A:        `{"signers": ["a", "b", "c"]}`,
+			B:        `{"signers": ["c", "a", "b"]}`,
+			Paths:    []string{"signers"},
+			Expected: true,
+		},
+		{
+			Name:        "one side only",
+			A:           `{"signers": ["a", "b", "c"]}`,
+			B:           `{"signers": ["d", "c", "a"]}`,
+			Paths:       []string{"signers"},
+			Expected:    false,
+			Differences: []string{"deletion [0].signers[1]", "addition [0].signers[3]"},
+		},
+		{
+			Name:        "multiplicity",
+			A:           `{"signers": ["a", "b", "a"]}`,
+			B:           `{"signers": ["b", "a", "b"]}`,
+			Paths:       []string{"signers"},
+			Expected:    false,
+			Differences: []string{"deletion [0].signers[2]", "addition [0].signers[3]"},
+		},
+		{
+			Name:     "nested objects",
+			A:        `{"assets": [{"id": 1, "amt": [1, 2]}, {"id": 2, "amt": [3]}]}`,
+			B:        `{"assets": [{"amt": [3], "id": 2}, {"id": 1, "amt": [1, 2]}]}`,
+			Paths:    []string{"assets"},
+			Options:  CompareOptions{IgnoreOrder: true},
+			Expected: true,
+		},
+		{
+			Name:        "changed nested object",
+			A:           `{"assets": [{"id": 1, "amt": 1}, {"id": 2, "amt": 2}]}`,
+			B:           `{"assets": [{"id": 2, "amt": 2}, {"id": 1, "amt": 3}]}`,
+			Paths:       []string{"assets"},
+			Expected:    false,
+			Differences: []string{"deletion [0].assets[0]", "addition [0].assets[2]"},
+		},
+		{
+			Name:     "flexible types",
+			A:        `[1, 2.5, 100000000]`,
+			B:        `[100000000.0, 2.5, 1.0]`,
+			Options:  CompareOptions{UnorderedArrays: true, FlexibleTypes: true},
+			Expected: true,
+		},
+		{
+			Name:     "strict types",
+			A:        `[1, 2]`,
+			B:        `[2, 1.0]`,
+			Options:  CompareOptions{UnorderedArrays: true, Brief: true},
+			Expected: false,
+		},
+		{
+			Name:     "base64 binary",
+			A:        `{"keys": ["AQI=", "AwQ="]}`,
+			B:        `{"keys": ["AwQ=", "AQI="]}`,
+			Paths:    []string{"keys"},
+			Options:  CompareOptions{Base64Binary: true},
+			Expected: true,
+		},
+		{
+			Name:     "ignore empty",
+			A:        `[{"a": 1, "b": 0}, 0, {"c": 2}]`,
+			B:        `[{"c": 2}, {"a": 1}]`,
+			Options:  CompareOptions{UnorderedArrays: true, IgnoreEmpty: true},
+			Expected: true,
+		},
+		{
+			Name:     "wildcard path",
+			A:        `{"txns": [{"signers": ["a", "b"]}, {"signers": ["c", "d"]}]}`,
+			B:        `{"txns": [{"signers": ["b", "a"]}, {"signers": ["d", "c"]}]}`,
+			Paths:    []string{"txns[*].signers"},
+			Expected: true,
+		},
+		{
+			Name:        "other path",
+			A:           `{"signers": ["a", "b"], "order": [1, 2]}`,
+			B:           `{"signers": ["b", "a"], "order": [2, 1]}`,
+			Paths:       []string{"signers"},
+			Expected:    false,
+			Differences: []string{"deletion [0].order[0]", "addition [0].order[2]"},
+		},
+		{
+			Name:     "top-level arrays",
+			A:        `[1, 2] [3, 4]`,
+			B:        `[2, 1] [4, 3]`,
+			Options:  CompareOptions{UnorderedArrays: true},
+			Expected: true,
+		},
+		{
+			Name:        "array key first",
+			A:           `{"accounts": [{"addr": "A"}, {"addr": "B"}]}`,
+			B:           `{"accounts": [{"addr": "B"}, {"addr": "A"}]}`,
+			Keys:        []string{"accounts[*]=addr"},
+			Options:     CompareOptions{UnorderedArrays: true},
+			Expected:    false,
+			Differences: []string{"move [0].accounts[0]"},
+		},
+		{
+			Name:     "exact matches before close numbers",
+			A:        `[1.1, 1.0]`,
+			B:        `[1.0, 1.2]`,
+			Options:  CompareOptions{UnorderedArrays: true, Tolerance: Tolerance{Absolute: 0.15}},
+			Expected: true,
+		},
+		{
+			Name:     "exact matches before close numbers in rules",
+			A:        `{"amounts": [1.1, 1.0]}`,
+			B:        `{"amounts": [1.0, 1.2]}`,
+			Paths:    []string{"amounts"},
+			Options:  CompareOptions{Rules: []Rule{{Path: Path{KeyElement(StringKey("amounts"))}, Tolerance: &Tolerance{Absolute: 0.15}}}},
+			Expected: true,
+		},
+		{
+			Name:        "close numbers",
+			A:           `[1.1, 1.0, 2.0]`,
+			B:           `[1.0, 1.2, 2.5]`,
+			Options:     CompareOptions{UnorderedArrays: true, Tolerance: Tolerance{Absolute: 0.15}},
+			Expected:    false,
+			Differences: []string{"deletion [0][2]", "addition [0][3]"},
+		},
+		{
+			Name:        "nested arrays keep their order",
+			A:           `{"signers": [["a", "b"], ["c"]]}`,
+			B:           `{"signers": [["c"], ["b", "a"]]}`,
+			Paths:       []string{"signers"},
+			Expected:    false,
+			Differences: []string{"deletion [0].signers[0]", "addition [0].signers[2]"},
+		},
+	}
+
+	diffTypes := map[DifferenceType]string{Deletion: "deletion", Addition: "addition", Move: "move"}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			objectsA, err := ParseJSON([]byte(test.A), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			objectsB, err := ParseJSON([]byte(test.B), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			for _, str := range test.Paths {
+				path, err := ParsePath(str)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v\n", err)
+				}
+				test.Options.UnorderedPaths = append(test.Options.UnorderedPaths, path)
+			}
+			for _, str := range test.Keys {
+				key, err := ParseArrayKey(str)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v\n", err)
+				}
+				test.Options.ArrayKeys = append(test.Options.ArrayKeys, key)
+			}
+
+			result := CompareParsed(objectsA, objectsB, test.Options)
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+
+			if test.Options.Brief {
+				return
+			}
+
+			differences := []string{}
+			for _, diff := range result.Reporter.Differences {
+				if diff.Type != Replacement {
+					differences = append(differences, fmt.Sprintf("%s %s", diffTypes[diff.Type], diffPath(diff)))
+				}
+			}
+			if test.Differences == nil {
+				test.Differences = []string{}
+			}
+			if !reflect.DeepEqual(differences, test.Differences) {
+				t.Fatalf("Wrong differences: got %v, expected %v\n", differences, test.Differences)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestCompareLargeMultisets(t *testing.T) {
+	// comparing every pair of elements of these arrays would take far too long
+	const size = 100000
+
+	var a, b strings.Builder
+	a.WriteString("[")
+	b.WriteString("[")
+	for i := 0; i < size; i++ {
+		if i != 0 {
+			a.WriteString(",")
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&a, `{"id": %d}`, i)
+		fmt.Fprintf(&b, `{"id": %d}`, size-1-i)
+	}
+	a.WriteString("]")
+	b.WriteString(", 0]")
+
+	objectsA, err := ParseJSON([]byte(a.String()), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	objectsB, err := ParseJSON([]byte(b.String()), ParseOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	result := CompareParsed(objectsA, objectsB, CompareOptions{UnorderedArrays: true})
+	if result.Equal {
+		t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, false)
+	}
+	if len(result.Reporter.Differences) != 1 || result.Reporter.Differences[0].Type != Addition {
+		t.Fatalf("Wrong differences: got %v, expected one addition\n", result.Reporter.Differences)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	type FingerprintTest struct {
+		Name    string
+		A       string
+		B       string
+		Options CompareOptions
+	}
+
+	// pairs of objects that are equal with the options, so they must have the same fingerprint
+	tests := []FingerprintTest{
+		{
+			Name:    "map order",
+			A:       `{"a": 1, "b": [1, 2]}`,
+			B:       `{"b": [1, 2], "a": 1}`,
+			Options: CompareOptions{IgnoreOrder: true},
+		},
+		{
+			Name:    "flexible integers",
+			A:       `[1, -3, 65536]`,
+			B:       `[1.0, -3.0, 65536.0]`,
+			Options: CompareOptions{FlexibleTypes: true},
+		},
+		{
+			Name:    "flexible large numbers",
+			A:       `300000000`,
+			B:       `300000000.0`,
+			Options: CompareOptions{FlexibleTypes: true},
+		},
+		{
+			Name:    "flexible integers beyond float precision",
+			A:       `[9007199254740992, -9223372036854775808, 9223372036854775808, 18446744073709549568]`,
+			B:       `[9007199254740992.0, -9223372036854775808.0, 9223372036854775808.0, 18446744073709549568.0]`,
+			Options: CompareOptions{FlexibleTypes: true},
+		},
+		{
+			Name: "negative zero",
+			A:    `-0.0`,
+			B:    `0.0`,
+		},
+		{
+			Name:    "empty values",
+			A:       `{"a": 1, "b": "", "c": []}`,
+			B:       `{"a": 1}`,
+			Options: CompareOptions{IgnoreEmpty: true},
+		},
+		{
+			Name:    "base64",
+			A:       `"AQI="`,
+			B:       `"AQI="`,
+			Options: CompareOptions{Base64Binary: true},
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			objectsA, err := ParseJSON([]byte(test.A), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			objectsB, err := ParseJSON([]byte(test.B), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			options := test.Options
+			options.Brief = true
+			if !CompareParsed(objectsA, objectsB, options).Equal {
+				t.Fatalf("Objects are not equal\n")
+			}
+
+			a := fingerprint(objectsA[0], nil, test.Options)
+			b := fingerprint(objectsB[0], nil, test.Options)
+			if a != b {
+				t.Fatalf("Wrong fingerprint: got %x, expected %x\n", b, a)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+
+	a, _ := ParseJSON([]byte(`[1, 2] [1, 3]`), ParseOptions{})
+	if fingerprint(a[0], nil, CompareOptions{}) == fingerprint(a[1], nil, CompareOptions{}) {
+		t.Fatalf("Different arrays have the same fingerprint\n")
+	}
+}
@@ -13,16 +13,109 @@ import (
 	"github.com/ttacon/chalk"
 )
 
-// MsgpMap represents an ordered map of strings to MsgpObjects
+// MapKey is a key in a MsgpMap. MessagePack allows objects of any type to be map keys, so a MapKey
+// holds the type of the key and a comparable form of its value. Strings, integers, booleans and nil
+// are stored as their Go values, floats are stored as the bits of their value so that NaN keys
+// match and 0 and -0 are different keys, binary keys are stored as a string of their bytes, and all
+// other keys (maps, arrays and extensions) are stored as a string of their MessagePack encoding.
+type MapKey struct {
+	Type  msgp.Type
+	Value interface{}
+}
+
+// StringKey returns the MapKey for the string key.
+func StringKey(key string) MapKey {
+	return MapKey{msgp.StrType, key}
+}
+
+// Object returns the key as a MsgpObject.
+func (key MapKey) Object() (MsgpObject, error) {
+	switch key.Type {
+	case msgp.BinType:
+		return MsgpObject{Type: key.Type, Value: []byte(key.Value.(string))}, nil
+	case msgp.Float32Type:
+		return MsgpObject{Type: key.Type, Value: math.Float32frombits(key.Value.(uint32))}, nil
+	case msgp.Float64Type:
+		return MsgpObject{Type: key.Type, Value: math.Float64frombits(key.Value.(uint64))}, nil
+	case msgp.StrType, msgp.BoolType, msgp.IntType, msgp.UintType, msgp.NilType:
+		return MsgpObject{Type: key.Type, Value: key.Value}, nil
+	}
+	object, _, err := Parse([]byte(key.Value.(string)))
+	return object, err
+}
+
+func (key MapKey) String() string {
+	switch key.Type {
+	case msgp.StrType:
+		return escapeString(key.Value.(string))
+	case msgp.BoolType, msgp.IntType, msgp.UintType:
+		return fmt.Sprintf("%s(%v)", typeName(key.Type), key.Value)
+	}
+	object, err := key.Object()
+	if err != nil {
+		return fmt.Sprintf("%s(base64(%s))", typeName(key.Type), base64.StdEncoding.EncodeToString([]byte(key.Value.(string))))
+	}
+	if key.Type == msgp.Float32Type || key.Type == msgp.Float64Type {
+		return fmt.Sprintf("%s(%v)", typeName(key.Type), object.Value)
+	}
+	return inlineString(object)
+}
+
+// MsgpMap represents an ordered map of MapKeys to MsgpObjects
 type MsgpMap struct {
-	Order  []string
-	Values map[string]MsgpObject
+	Order  []MapKey
+	Values map[MapKey]MsgpObject
+	// The objects of the keys, if the map was parsed from MessagePack. These record how each key
+	// was encoded.
+	Keys map[MapKey]MsgpObject
+}
+
+// Extension is the value of a MessagePack extension object that is not one of the extensions
+// natively supported by the msgp library.
+type Extension struct {
+	// The extension type code.
+	Type int8
+	// The raw payload of the extension.
+	Data []byte
+	// The structured value of the payload if a decoder is registered for the extension type,
+	// otherwise nil.
+	Decoded *MsgpObject
+}
+
+// Tag is the value of a CBOR tag, which has no equivalent in MessagePack. A tag is an object of
+// ExtensionType whose value is a Tag instead of an Extension.
+type Tag struct {
+	// The tag number.
+	Number uint64
+	// The tagged item.
+	Content MsgpObject
 }
 
 // MsgpObject contains a parsed MessagePack object and its type.
 type MsgpObject struct {
 	Type  msgp.Type
 	Value interface{}
+	// The format the object was encoded with, if it was parsed from MessagePack.
+	Format Format
+	// The extension type code a time was encoded with, if it was parsed from MessagePack. This is
+	// -1 for the timestamp extension of the MessagePack specification, msgp.TimeExtension for the
+	// time extension of the msgp library, and 0 for all other objects.
+	TimeExtension int8
+	// The offsets of the first byte of the object and the byte after its last byte in the input it
+	// was parsed from.
+	Start int
+	End   int
+}
+
+// Span is a range of byte offsets in an input.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Span returns the range of offsets that the object occupies in its input.
+func (mo MsgpObject) Span() Span {
+	return Span{mo.Start, mo.End}
 }
 
 // IsEmpty checks if the value of a MessagePack object is the zero value for its type.
@@ -70,6 +163,10 @@ func (mo MsgpObject) IsEmpty() (empty bool) {
 		empty = mo.Value.(complex128) == 0
 	case msgp.TimeType:
 		empty = mo.Value.(time.Time).IsZero()
+	case msgp.ExtensionType:
+		// tags are never empty
+		ext, ok := mo.Value.(Extension)
+		empty = ok && ext.Type == 0 && len(ext.Data) == 0
 	}
 	return
 }
@@ -101,7 +198,7 @@ func (mo MsgpObject) Print(w io.Writer, prefix string, indent int, inline bool)
 		fmt.Fprint(w, "{\n")
 		for index, key := range valueMap.Order {
 			value := valueMap.Values[key]
-			fmt.Fprintf(w, "%s%s%s%s: ", prefix, indentStr, indentation, escapeString(key))
+			fmt.Fprintf(w, "%s%s%s%s: ", prefix, indentStr, indentation, key)
 			value.Print(w, prefix, indent+1, true)
 			if index+1 < len(valueMap.Order) {
 				fmt.Fprint(w, ",\n")
@@ -133,12 +230,29 @@ func (mo MsgpObject) Print(w io.Writer, prefix string, indent int, inline bool)
 		fmt.Fprint(w, escapeString(mo.Value.(string)))
 	case msgp.BinType:
 		fmt.Fprintf(w, "base64(%s)", base64.StdEncoding.EncodeToString(mo.Value.([]byte)))
+	case msgp.ExtensionType:
+		if tag, ok := mo.Value.(Tag); ok {
+			fmt.Fprintf(w, "tag(%d, %s)", tag.Number, inlineString(tag.Content))
+			break
+		}
+		ext := mo.Value.(Extension)
+		if ext.Decoded != nil {
+			fmt.Fprintf(w, "ext(%d, %s)", ext.Type, inlineString(*ext.Decoded))
+		} else {
+			fmt.Fprintf(w, "ext(%d, base64(%s))", ext.Type, base64.StdEncoding.EncodeToString(ext.Data))
+		}
 	default:
 		fmt.Fprintf(w, "%v", mo.Value)
 	}
 }
 
 func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, indent int, inline bool, toplevel bool) {
+	mo.printDiff(w, context, diffs, indent, inline, toplevel, false)
+}
+
+// printDiff prints the differences like PrintDiff. If showOffsets is true, each difference is
+// annotated with the offset of its object in the input it was parsed from.
+func (mo MsgpObject) printDiff(w io.Writer, context int, diffs []Difference, indent int, inline bool, toplevel bool, showOffsets bool) {
 	indentStr := strings.Repeat(indentation, indent)
 	levelZero := false
 	embedded := false
@@ -148,7 +262,7 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 			sign := getSign(diff.Type)
 			endSign := getSignEnd()
 
-			diff.Object.Print(w, sign, indent, false)
+			diff.print(w, sign, indent, false, showOffsets)
 			fmt.Fprint(w, endSign)
 			levelZero = true
 		} else {
@@ -192,7 +306,7 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 				if index >= lastContextIndex {
 					key := valueMap.Order[index]
 					value := valueMap.Values[key]
-					fmt.Fprintf(w, " %s%s%s: ", indentStr, indentation, escapeString(key))
+					fmt.Fprintf(w, " %s%s%s: ", indentStr, indentation, key)
 					value.Print(w, " ", indent+1, true)
 					fmt.Fprint(w, ",\n")
 					lastContextIndex = index + 1
@@ -205,8 +319,15 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 				sign := getSign(diff.Type)
 				endSign := getSignEnd()
 
-				fmt.Fprintf(w, "%s%s%s%s: ", sign, indentStr, indentation, escapeString(layer.CurrentKey))
-				diff.Object.Print(w, sign, indent+1, true)
+				if diff.InKey {
+					// print the format of the key, followed by its unchanged value
+					fmt.Fprintf(w, "%s%s%s%s(%s): ", sign, indentStr, indentation, diff.Object.Format, layer.CurrentKey)
+					diff.Object = valueMap.Values[layer.CurrentKey]
+					diff.ShowFormat = false
+				} else {
+					fmt.Fprintf(w, "%s%s%s%s: ", sign, indentStr, indentation, layer.CurrentKey)
+				}
+				annotation := diff.print(w, sign, indent+1, true, showOffsets)
 
 				moreKeys := layer.CurrentIndex+1 < len(valueMap.Order)
 				if diff.Type == Addition {
@@ -214,9 +335,9 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 				}
 
 				if moreKeys {
-					fmt.Fprintf(w, ",%s\n", endSign)
+					fmt.Fprintf(w, ",%s%s\n", annotation, endSign)
 				} else {
-					fmt.Fprintf(w, "%s\n", endSign)
+					fmt.Fprintf(w, "%s%s\n", annotation, endSign)
 				}
 
 				start++
@@ -243,12 +364,12 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 					subdiffs[i].Path = subdiffs[i].Path[1:]
 				}
 
-				fmt.Fprintf(w, " %s%s%s: ", indentStr, indentation, escapeString(layer.CurrentKey))
+				fmt.Fprintf(w, " %s%s%s: ", indentStr, indentation, layer.CurrentKey)
 				value, ok := valueMap.Values[layer.CurrentKey]
 				if ok {
-					value.PrintDiff(w, context, subdiffs, indent+1, true, false)
+					value.printDiff(w, context, subdiffs, indent+1, true, false, showOffsets)
 				} else {
-					diff.Object.PrintDiff(w, context, subdiffs, indent+1, true, false)
+					diff.Object.printDiff(w, context, subdiffs, indent+1, true, false, showOffsets)
 				}
 
 				if end < len(diffs) || layer.CurrentIndex+1 < len(valueMap.Order) {
@@ -274,7 +395,7 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 				if index >= lastContextIndex && index < len(valueMap.Order) {
 					key := valueMap.Order[index]
 					value := valueMap.Values[key]
-					fmt.Fprintf(w, " %s%s%s: ", indentStr, indentation, escapeString(key))
+					fmt.Fprintf(w, " %s%s%s: ", indentStr, indentation, key)
 					value.Print(w, " ", indent+1, true)
 					if index+1 < len(valueMap.Order) || start < len(diffs) {
 						fmt.Fprint(w, ",")
@@ -295,6 +416,11 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 		}
 
 		fmt.Fprintf(w, " %s}", indentStr)
+		if toplevel {
+			// top-level maps, such as the messages aligned by CompareRPC, end their line like the
+			// values of containers do
+			fmt.Fprintln(w)
+		}
 	case msgp.ArrayType:
 		valueArray := mo.Value.([]MsgpObject)
 
@@ -354,22 +480,29 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 				if !toplevel {
 					fmt.Fprintf(w, indentation)
 				}
-				diff.Object.Print(w, sign, nextLevelIndent, true)
+				annotation := diff.print(w, sign, nextLevelIndent, true, showOffsets)
+				if diff.Type == Move {
+					annotation = fmt.Sprintf("%s (moved to index %d)", annotation, diff.NewIndex)
+				}
 
 				moreElements := layer.CurrentIndex+1 < len(valueArray)
 				if diff.Type == Addition {
 					moreElements = layer.CurrentIndex < len(valueArray) || start+1 < len(diffs)
 				}
+				if diff.Type == Move {
+					// the changes to the contents of the moved element may follow it
+					moreElements = moreElements || start+1 < len(diffs)
+				}
 
 				if !toplevel && moreElements {
-					fmt.Fprintf(w, ",%s\n", endSign)
+					fmt.Fprintf(w, ",%s%s\n", annotation, endSign)
 				} else {
-					fmt.Fprintf(w, "%s\n", endSign)
+					fmt.Fprintf(w, "%s%s\n", annotation, endSign)
 				}
 
 				start++
 
-				if diff.Type == Deletion {
+				if diff.Type == Deletion || diff.Type == Move {
 					lastContextIndex = layer.CurrentIndex + 1
 				}
 
@@ -379,7 +512,8 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 			} else {
 				end := start + 1
 				for j := start + 1; j < len(diffs); j++ {
-					if layer.Object == diffs[j].Path[0].Object {
+					otherLayer := diffs[j].Path[0]
+					if layer.Object == otherLayer.Object && layer.CurrentIndex == otherLayer.CurrentIndex {
 						end = j + 1
 					}
 				}
@@ -395,9 +529,9 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 					fmt.Fprintf(w, indentation)
 				}
 				if layer.CurrentIndex < len(valueArray) {
-					valueArray[layer.CurrentIndex].PrintDiff(w, context, subdiffs, nextLevelIndent, true, false)
+					valueArray[layer.CurrentIndex].printDiff(w, context, subdiffs, nextLevelIndent, true, false, showOffsets)
 				} else {
-					diff.Object.PrintDiff(w, context, subdiffs, nextLevelIndent, true, false)
+					diff.Object.printDiff(w, context, subdiffs, nextLevelIndent, true, false, showOffsets)
 				}
 
 				if !toplevel && (end < len(diffs) || layer.CurrentIndex+1 < len(valueArray)) {
@@ -461,10 +595,65 @@ func (mo MsgpObject) PrintDiff(w io.Writer, context int, diffs []Difference, ind
 	}
 }
 
+// print prints the object of a difference. If the difference is only in the format of the object,
+// the format is printed as well.
+//
+// If showOffsets is true, the first line of the object is annotated with its offset, and if the
+// difference has a delta, it is annotated with the delta. When the object fits on a single line
+// that is not yet terminated, the annotation is returned instead so that the caller can write it
+// at the end of the line.
+func (diff Difference) print(w io.Writer, prefix string, indent int, inline bool, showOffsets bool) (annotation string) {
+	if showOffsets {
+		annotation = fmt.Sprintf(" @0x%x", diff.Offset())
+	}
+	if diff.ShowDelta {
+		annotation += fmt.Sprintf(" (delta %+g)", diff.Delta)
+	}
+	if annotation == "" {
+		diff.printObject(w, prefix, indent, inline)
+		return
+	}
+
+	var str strings.Builder
+	diff.printObject(&str, prefix, indent, inline)
+	printed := str.String()
+
+	if newline := strings.Index(printed, "\n"); newline != -1 {
+		printed = printed[:newline] + annotation + printed[newline:]
+		annotation = ""
+	}
+	fmt.Fprint(w, printed)
+	return
+}
+
+func (diff Difference) printObject(w io.Writer, prefix string, indent int, inline bool) {
+	if !diff.ShowFormat {
+		diff.Object.Print(w, prefix, indent, inline)
+		return
+	}
+
+	if !inline {
+		fmt.Fprint(w, prefix)
+		fmt.Fprint(w, strings.Repeat(indentation, indent))
+		defer fmt.Fprintln(w)
+	}
+
+	if diff.ShowTimeExtension {
+		fmt.Fprintf(w, "%s(%d)(", diff.Object.Format, diff.Object.TimeExtension)
+	} else {
+		fmt.Fprintf(w, "%s(", diff.Object.Format)
+	}
+	diff.Object.Print(w, prefix, indent, true)
+	fmt.Fprint(w, ")")
+}
+
 func getSign(diffType DifferenceType) string {
 	if diffType == Deletion {
 		return chalk.Red.String() + "-"
 	}
+	if diffType == Move {
+		return chalk.Yellow.String() + "~"
+	}
 	return chalk.Green.String() + "+"
 }
 
@@ -475,3 +664,46 @@ func getSignEnd() string {
 func escapeString(str string) string {
 	return strconv.QuoteToASCII(str)
 }
+
+// inlineString returns a single line representation of a MessagePack object.
+func inlineString(mo MsgpObject) string {
+	var str strings.Builder
+	switch mo.Type {
+	case msgp.MapType:
+		valueMap := mo.Value.(MsgpMap)
+		str.WriteString("{")
+		for index, key := range valueMap.Order {
+			if index > 0 {
+				str.WriteString(", ")
+			}
+			fmt.Fprintf(&str, "%s: %s", key, inlineString(valueMap.Values[key]))
+		}
+		str.WriteString("}")
+	case msgp.ArrayType:
+		valueArray := mo.Value.([]MsgpObject)
+		str.WriteString("[")
+		for index, item := range valueArray {
+			if index > 0 {
+				str.WriteString(", ")
+			}
+			str.WriteString(inlineString(item))
+		}
+		str.WriteString("]")
+	default:
+		mo.Print(&str, "", 0, true)
+	}
+	return str.String()
+}
+
+// typeName returns the name of a MessagePack type.
+func typeName(t msgp.Type) string {
+	switch t {
+	case msgp.Complex64Type:
+		return "complex64"
+	case msgp.Complex128Type:
+		return "complex128"
+	case msgp.TimeType:
+		return "time"
+	}
+	return t.String()
+}
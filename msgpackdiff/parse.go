@@ -1,78 +1,311 @@
 package msgpackdiff
 
 import (
-	"encoding/base64"
+	"encoding/binary"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/algorand/msgp/msgp"
 )
 
 // GetBinary gathers the binary content of a string that represents a MessagePack object. The string
-// may be a base64 encoded binary object, or the path to a binary file that contains the object as
-// its only content.
+// may be a base64 encoded binary object, the path to a binary file that contains the object as its
+// only content, or any of the other forms accepted by ReadInput.
 func GetBinary(object string) ([]byte, error) {
-	decoded, err := base64.StdEncoding.DecodeString(object)
-	if err == nil {
-		return decoded, nil
+	bin, _, err := ReadInput(object, InputOptions{})
+	return bin, err
+}
+
+// timestampExtension is the extension type code of the timestamp extension defined in the
+// MessagePack specification.
+const timestampExtension int8 = -1
+
+// decodeTimestamp decodes the payload of a timestamp extension, which may be in the 32, 64 or 96
+// bit form defined in the MessagePack specification.
+func decodeTimestamp(data []byte) (t time.Time, err error) {
+	var sec int64
+	var nsec uint32
+	switch len(data) {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		data64 := binary.BigEndian.Uint64(data)
+		nsec = uint32(data64 >> 34)
+		sec = int64(data64 & 0x00000003ffffffff)
+	case 12:
+		nsec = binary.BigEndian.Uint32(data)
+		sec = int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		err = errors.New("Invalid timestamp length")
+		return
+	}
+	if nsec > 999999999 {
+		err = errors.New("Invalid timestamp nanoseconds")
+		return
 	}
+	t = time.Unix(sec, int64(nsec)).Local()
+	return
+}
 
-	content, err := ioutil.ReadFile(object)
-	if err != nil {
-		return []byte{}, err
+// ExtensionDecoder converts the payload of a MessagePack extension into a structured object.
+type ExtensionDecoder func(data []byte) (MsgpObject, error)
+
+var (
+	extensionDecodersMu sync.RWMutex
+	extensionDecoders   = map[int8]ExtensionDecoder{}
+)
+
+// RegisterExtension registers a decoder for extensions with the type code typ. When Parse
+// encounters an extension of that type, the decoded object is stored in the Decoded field of the
+// Extension and is used in place of the raw payload for comparisons. Passing a nil decoder removes
+// any decoder registered for typ. RegisterExtension is safe to call concurrently with Parse.
+func RegisterExtension(typ int8, decoder ExtensionDecoder) {
+	extensionDecodersMu.Lock()
+	defer extensionDecodersMu.Unlock()
+
+	if decoder == nil {
+		delete(extensionDecoders, typ)
+		return
 	}
+	extensionDecoders[typ] = decoder
+}
+
+// extensionDecoder returns the decoder registered for extensions with the type code typ.
+func extensionDecoder(typ int8) (ExtensionDecoder, bool) {
+	extensionDecodersMu.RLock()
+	defer extensionDecodersMu.RUnlock()
+
+	decoder, ok := extensionDecoders[typ]
+	return decoder, ok
+}
 
-	// attempt to decode from base64
-	maxLen := base64.StdEncoding.DecodedLen(len(content))
-	decoded = make([]byte, maxLen)
+// ParseError is the error returned when a MessagePack object cannot be parsed.
+type ParseError struct {
+	// The offset in the input of the object that could not be parsed.
+	Offset int
+	// The map keys and array indices that lead from the top-level object to the object that could
+	// not be parsed.
+	Path Path
+	// The first byte of the object that could not be parsed, or -1 if the input ended before the
+	// object.
+	TypeByte int
+	// The reason the object could not be parsed.
+	Err error
+}
 
-	n, err := base64.StdEncoding.Decode(decoded, content)
-	if err == nil {
-		return decoded[:n], nil
+func (e *ParseError) Error() string {
+	var str strings.Builder
+	str.WriteString(e.Err.Error())
+	if len(e.Path) != 0 {
+		fmt.Fprintf(&str, " at %s", e.Path)
+	}
+	fmt.Fprintf(&str, " (offset 0x%x", e.Offset)
+	if e.TypeByte >= 0 {
+		fmt.Fprintf(&str, ", type byte 0x%02x", e.TypeByte)
 	}
+	str.WriteString(")")
+	return str.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
 
-	return content, nil
+// withPathElement adds element to the start of the path of err, which must be a *ParseError
+// returned by parser.parseAt for a child of a container.
+func withPathElement(err error, element PathElement) error {
+	parseErr := err.(*ParseError)
+	parseErr.Path = append(Path{element}, parseErr.Path...)
+	return parseErr
 }
 
-// Parse parses a MessagePack encoded binary object into an in-memory data structure.
+// The default limits used when a field of ParseOptions is 0.
+const (
+	DefaultMaxDepth           = 1000
+	DefaultMaxContainerLength = 1 << 24
+)
+
+// ErrDuplicateKey is the error of a ParseError when a map has the same key more than once.
+var ErrDuplicateKey = errors.New("Object has duplicate key")
+
+// The errors of a ParseError when the input exceeds the limits of its ParseOptions.
+var (
+	ErrMaxDepth           = errors.New("Object exceeds the maximum depth")
+	ErrMaxContainerLength = errors.New("Container exceeds the maximum length")
+	ErrAllocationLimit    = errors.New("Container is longer than the remaining input")
+)
+
+// ParseOptions limit the resources used to parse an object, so that parsing hostile input returns an
+// error instead of exhausting memory or the stack.
+type ParseOptions struct {
+	// The maximum number of containers that can be nested inside each other. If 0,
+	// DefaultMaxDepth is used.
+	MaxDepth int
+	// The maximum number of elements in an array or entries in a map. If 0,
+	// DefaultMaxContainerLength is used.
+	MaxContainerLength int
+}
+
+// parser holds the state of a call to ParseWithOptions.
+type parser struct {
+	maxDepth           int
+	maxContainerLength int
+	// The number of elements that containers may still declare. Every element takes at least one
+	// byte to encode, so the elements of all containers in valid input add up to no more than the
+	// length of the input. This bounds the memory allocated for containers by the input length.
+	budget int
+}
+
+func newParser(input []byte, options ParseOptions) *parser {
+	p := parser{
+		maxDepth:           options.MaxDepth,
+		maxContainerLength: options.MaxContainerLength,
+		budget:             len(input),
+	}
+	if p.maxDepth == 0 {
+		p.maxDepth = DefaultMaxDepth
+	}
+	if p.maxContainerLength == 0 {
+		p.maxContainerLength = DefaultMaxContainerLength
+	}
+	return &p
+}
+
+// Parse parses a MessagePack encoded binary object into an in-memory data structure. If the object
+// cannot be parsed, the returned error is a *ParseError.
 func Parse(bytes []byte) (parsed MsgpObject, remaining []byte, err error) {
+	return ParseWithOptions(bytes, ParseOptions{})
+}
+
+// ParseWithOptions is like Parse, but with limits on the resources used given by options.
+func ParseWithOptions(bytes []byte, options ParseOptions) (parsed MsgpObject, remaining []byte, err error) {
+	return newParser(bytes, options).parseAt(bytes, 0, 0)
+}
+
+// ParseAll parses every object in bytes, which may hold any number of MessagePack objects one after
+// another. The limits of options apply to the input as a whole. If an object cannot be parsed, the
+// objects before it are returned along with the error.
+func ParseAll(bytes []byte, options ParseOptions) (objects []MsgpObject, err error) {
+	objects = []MsgpObject{}
+	p := newParser(bytes, options)
+	for remaining := bytes; len(remaining) != 0; {
+		var object MsgpObject
+		object, remaining, err = p.parseAt(remaining, len(bytes)-len(remaining), 0)
+		if err != nil {
+			return
+		}
+		objects = append(objects, object)
+	}
+	return
+}
+
+// allocate reserves room for size elements of a container. It returns an error if the container
+// is too long.
+func (p *parser) allocate(size int) error {
+	if size > p.maxContainerLength {
+		return ErrMaxContainerLength
+	}
+	if size > p.budget {
+		return ErrAllocationLimit
+	}
+	p.budget -= size
+	return nil
+}
+
+// parseAt parses a MessagePack encoded binary object that begins at offset in its input and is
+// nested inside depth containers.
+func (p *parser) parseAt(bytes []byte, offset int, depth int) (parsed MsgpObject, remaining []byte, err error) {
+	input := bytes
+	// offsetOf returns the offset in the input of the start of the slice rest
+	offsetOf := func(rest []byte) int {
+		return offset + len(input) - len(rest)
+	}
+
+	if len(bytes) == 0 {
+		err = &ParseError{
+			Offset:   offset,
+			TypeByte: -1,
+			Err:      msgp.ErrShortBytes,
+		}
+		return
+	}
+
+	parsed.Start = offset
 	parsed.Type = msgp.NextType(bytes)
+	parsed.Format = getFormat(bytes[0])
 	switch parsed.Type {
 	case msgp.StrType:
 		parsed.Value, bytes, err = msgp.ReadStringBytes(bytes)
 	case msgp.BinType:
 		parsed.Value, bytes, err = msgp.ReadBytesBytes(bytes, nil)
 	case msgp.MapType:
+		if depth >= p.maxDepth {
+			err = ErrMaxDepth
+			break
+		}
 		var size int
 		size, _, bytes, err = msgp.ReadMapHeaderBytes(bytes)
+		if err != nil {
+			break
+		}
+		// each entry of the map is a key and a value
+		if err = p.allocate(2 * size); err != nil {
+			break
+		}
 		valueMap := MsgpMap{
-			Order:  make([]string, size),
-			Values: make(map[string]MsgpObject, size),
+			Order:  make([]MapKey, size),
+			Values: make(map[MapKey]MsgpObject, size),
+			Keys:   make(map[MapKey]MsgpObject, size),
 		}
 		for i := 0; i < size; i++ {
-			var key string
-			key, bytes, err = msgp.ReadStringBytes(bytes)
+			var keyObject MsgpObject
+			var remaining []byte
+			keyObject, remaining, err = p.parseAt(bytes, offsetOf(bytes), depth+1)
 			if err != nil {
 				break
 			}
+			key := newMapKey(keyObject, bytes[:len(bytes)-len(remaining)])
 			if _, ok := valueMap.Values[key]; ok {
-				err = errors.New("Object has duplicate key")
+				err = &ParseError{
+					Offset:   offsetOf(bytes),
+					Path:     Path{KeyElement(key)},
+					TypeByte: int(bytes[0]),
+					Err:      ErrDuplicateKey,
+				}
 				break
 			}
+			bytes = remaining
 			valueMap.Order[i] = key
-			valueMap.Values[key], bytes, err = Parse(bytes)
+			valueMap.Keys[key] = keyObject
+			valueMap.Values[key], bytes, err = p.parseAt(bytes, offsetOf(bytes), depth+1)
 			if err != nil {
+				err = withPathElement(err, KeyElement(key))
 				break
 			}
 		}
 		parsed.Value = valueMap
 	case msgp.ArrayType:
+		if depth >= p.maxDepth {
+			err = ErrMaxDepth
+			break
+		}
 		var size int
 		size, _, bytes, err = msgp.ReadArrayHeaderBytes(bytes)
+		if err != nil {
+			break
+		}
+		if err = p.allocate(size); err != nil {
+			break
+		}
 		valueArray := make([]MsgpObject, size)
 		for i := 0; i < size; i++ {
-			valueArray[i], bytes, err = Parse(bytes)
+			valueArray[i], bytes, err = p.parseAt(bytes, offsetOf(bytes), depth+1)
 			if err != nil {
+				err = withPathElement(err, IndexElement(i))
 				break
 			}
 		}
@@ -95,10 +328,88 @@ func Parse(bytes []byte) (parsed MsgpObject, remaining []byte, err error) {
 	case msgp.Complex128Type:
 		parsed.Value, bytes, err = msgp.ReadComplex128Bytes(bytes)
 	case msgp.TimeType:
+		parsed.TimeExtension = msgp.TimeExtension
 		parsed.Value, bytes, err = msgp.ReadTimeBytes(bytes)
+	case msgp.ExtensionType:
+		var raw msgp.RawExtension
+		raw.Type, err = peekExtensionType(bytes)
+		if err != nil {
+			break
+		}
+		bytes, err = msgp.ReadExtensionBytes(bytes, &raw)
+		if err != nil {
+			break
+		}
+		if raw.Type == timestampExtension {
+			parsed.Type = msgp.TimeType
+			parsed.TimeExtension = timestampExtension
+			parsed.Value, err = decodeTimestamp(raw.Data)
+			break
+		}
+		ext := Extension{
+			Type: raw.Type,
+			Data: raw.Data,
+		}
+		if decoder, ok := extensionDecoder(ext.Type); ok {
+			var decoded MsgpObject
+			decoded, err = decoder(ext.Data)
+			if err != nil {
+				break
+			}
+			ext.Decoded = &decoded
+		}
+		parsed.Value = ext
 	default:
 		err = errors.New("Invalid MessagePack type")
 	}
+	if err != nil {
+		if _, ok := err.(*ParseError); !ok {
+			err = &ParseError{
+				Offset:   offset,
+				TypeByte: int(input[0]),
+				Err:      err,
+			}
+		}
+		return
+	}
+	parsed.End = offsetOf(bytes)
 	remaining = bytes
 	return
 }
+
+// newMapKey creates the MapKey for a parsed object that appears as a key in a map. The encoded
+// argument must be the MessagePack encoding of the object.
+func newMapKey(object MsgpObject, encoded []byte) MapKey {
+	switch object.Type {
+	case msgp.BinType:
+		return MapKey{object.Type, string(object.Value.([]byte))}
+	case msgp.Float32Type:
+		return MapKey{object.Type, math.Float32bits(object.Value.(float32))}
+	case msgp.Float64Type:
+		return MapKey{object.Type, math.Float64bits(object.Value.(float64))}
+	case msgp.StrType, msgp.BoolType, msgp.IntType, msgp.UintType, msgp.NilType:
+		return MapKey{object.Type, object.Value}
+	}
+	return MapKey{object.Type, string(encoded)}
+}
+
+// peekExtensionType returns the type code of the extension object at the start of bytes.
+func peekExtensionType(bytes []byte) (int8, error) {
+	var offset int
+	switch bytes[0] {
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext 1, 2, 4, 8, 16
+		offset = 1
+	case 0xc7: // ext 8
+		offset = 2
+	case 0xc8: // ext 16
+		offset = 3
+	case 0xc9: // ext 32
+		offset = 5
+	default:
+		return 0, msgp.TypeError{Method: msgp.ExtensionType, Encoded: msgp.NextType(bytes)}
+	}
+	if len(bytes) <= offset {
+		return 0, msgp.ErrShortBytes
+	}
+	return int8(bytes[offset]), nil
+}
@@ -3,13 +3,60 @@ package msgpackdiff
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/algorand/msgp/msgp"
 )
 
+// stripMetadata returns a copy of object with the encoding and offsets of it and all of its children
+// cleared, so that it can be compared to an expected object.
+func stripMetadata(object MsgpObject) MsgpObject {
+	object.Format = FormatUnknown
+	object.TimeExtension = 0
+	object.Start = 0
+	object.End = 0
+	switch object.Type {
+	case msgp.MapType:
+		valueMap := object.Value.(MsgpMap)
+		stripped := MsgpMap{
+			Order:  valueMap.Order,
+			Values: make(map[MapKey]MsgpObject, len(valueMap.Values)),
+		}
+		for key, value := range valueMap.Values {
+			stripped.Values[key] = stripMetadata(value)
+		}
+		object.Value = stripped
+	case msgp.ArrayType:
+		valueArray := object.Value.([]MsgpObject)
+		stripped := make([]MsgpObject, len(valueArray))
+		for i, item := range valueArray {
+			stripped[i] = stripMetadata(item)
+		}
+		object.Value = stripped
+	case msgp.ExtensionType:
+		if tag, ok := object.Value.(Tag); ok {
+			tag.Content = stripMetadata(tag.Content)
+			object.Value = tag
+			break
+		}
+		ext := object.Value.(Extension)
+		if ext.Decoded != nil {
+			decoded := stripMetadata(*ext.Decoded)
+			ext.Decoded = &decoded
+		}
+		object.Value = ext
+	}
+	return object
+}
+
 func TestGetBinary(t *testing.T) {
 	type GetBinaryTest struct {
 		Name     string
@@ -22,11 +69,30 @@ func TestGetBinary(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	dir, err := ioutil.TempDir("", "msgpackdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// writeFile writes content to a new file and returns its path, which is not valid base64
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		err := ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	pi := []byte{129, 162, 112, 105, 203, 64, 9, 33, 251, 84, 68, 45, 24} // {"pi": 3.141592653589793}
+	bin := []byte{196, 3, 251, 255, 191}                                  // bin8 whose base64 has / and its URL-safe base64 has _
+
 	tests := []GetBinaryTest{
 		{
 			Name:     "inline base64",
-			Input:    "gaJwactACSH7VEQtGA==", // {"pi": 3.141592653589793}
-			Expected: []byte{129, 162, 112, 105, 203, 64, 9, 33, 251, 84, 68, 45, 24},
+			Input:    "gaJwactACSH7VEQtGA==",
+			Expected: pi,
 		},
 		{
 			Name:     "binary file",
@@ -38,6 +104,76 @@ func TestGetBinary(t *testing.T) {
 			Input:    "../test/algo_txn_base64",
 			Expected: algoTxn,
 		},
+		{
+			Name:     "inline hex",
+			Input:    "81a27069cb400921fb54442d18",
+			Expected: pi,
+		},
+		{
+			Name:     "inline hex with spaces",
+			Input:    "81 a2 70 69 cb 40 09 21 fb 54 44 2d 18",
+			Expected: pi,
+		},
+		{
+			Name:     "inline hex with 0x",
+			Input:    "0x81a27069CB400921FB54442D18",
+			Expected: pi,
+		},
+		{
+			Name:     "inline hex bytes with 0x",
+			Input:    "0x81, 0xa2, 0x70, 0x69, 0xcb, 0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18",
+			Expected: pi,
+		},
+		{
+			Name:     "inline raw base64",
+			Input:    "gaJwactACSH7VEQtGA",
+			Expected: pi,
+		},
+		{
+			Name:     "inline base64 with /",
+			Input:    "xAP7/78=",
+			Expected: bin,
+		},
+		{
+			Name:     "inline base64url",
+			Input:    "xAP7_78=",
+			Expected: bin,
+		},
+		{
+			Name:     "inline raw base64url",
+			Input:    "xAP7_78",
+			Expected: bin,
+		},
+		{
+			Name:     "hex file",
+			Input:    writeFile("hex.txt", "81a27069cb400921fb54442d18\n"),
+			Expected: pi,
+		},
+		{
+			Name:     "hex dump file",
+			Input:    writeFile("hex-dump.txt", "81 a2 70 69 cb 40 09 21\nfb 54 44 2d 18\n"),
+			Expected: pi,
+		},
+		{
+			Name:     "hex file with 0x",
+			Input:    writeFile("hex-0x.txt", "0x81a27069cb400921fb54442d18\n"),
+			Expected: pi,
+		},
+		{
+			Name:     "raw base64 file",
+			Input:    writeFile("raw-base64.txt", "gaJwactACSH7VEQtGA\n"),
+			Expected: pi,
+		},
+		{
+			Name:     "base64url file",
+			Input:    writeFile("base64url.txt", "xAP7_78=\n"),
+			Expected: bin,
+		},
+		{
+			Name:     "raw base64url file",
+			Input:    writeFile("raw-base64url.txt", "xAP7_78\n"),
+			Expected: bin,
+		},
 	}
 
 	for _, test := range tests {
@@ -67,10 +203,10 @@ func TestParse(t *testing.T) {
 			Name:  "{}",
 			Input: "gA==",
 			Expected: MsgpObject{
-				msgp.MapType,
-				MsgpMap{
-					[]string{},
-					map[string]MsgpObject{},
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order:  []MapKey{},
+					Values: map[MapKey]MsgpObject{},
 				},
 			},
 		},
@@ -78,109 +214,109 @@ func TestParse(t *testing.T) {
 			Name:  "[]",
 			Input: "kA==",
 			Expected: MsgpObject{
-				msgp.ArrayType,
-				[]MsgpObject{},
+				Type:  msgp.ArrayType,
+				Value: []MsgpObject{},
 			},
 		},
 		{
 			Name:  "\"\"",
 			Input: "oA==",
 			Expected: MsgpObject{
-				msgp.StrType,
-				"",
+				Type:  msgp.StrType,
+				Value: "",
 			},
 		},
 		{
 			Name:  "base64()",
 			Input: "xAA=",
 			Expected: MsgpObject{
-				msgp.BinType,
-				[]byte{},
+				Type:  msgp.BinType,
+				Value: []byte{},
 			},
 		},
 		{
 			Name:  "null",
 			Input: "wA==",
 			Expected: MsgpObject{
-				msgp.NilType,
-				nil,
+				Type:  msgp.NilType,
+				Value: nil,
 			},
 		},
 		{
 			Name:  "true",
 			Input: "ww==",
 			Expected: MsgpObject{
-				msgp.BoolType,
-				true,
+				Type:  msgp.BoolType,
+				Value: true,
 			},
 		},
 		{
 			Name:  "false",
 			Input: "wg==",
 			Expected: MsgpObject{
-				msgp.BoolType,
-				false,
+				Type:  msgp.BoolType,
+				Value: false,
 			},
 		},
 		{
 			Name:  "0",
 			Input: "AA==",
 			Expected: MsgpObject{
-				msgp.IntType,
-				int64(0),
+				Type:  msgp.IntType,
+				Value: int64(0),
 			},
 		},
 		{
 			Name:  "123456789",
 			Input: "zgdbzRU=",
 			Expected: MsgpObject{
-				msgp.UintType,
-				uint64(123456789),
+				Type:  msgp.UintType,
+				Value: uint64(123456789),
 			},
 		},
 		{
 			Name:  "0.5",
 			Input: "yj8AAAA=",
 			Expected: MsgpObject{
-				msgp.Float32Type,
-				float32(0.5),
+				Type:  msgp.Float32Type,
+				Value: float32(0.5),
 			},
 		},
 		{
 			Name:  "0.99999999",
 			Input: "yz/v///6oZxH",
 			Expected: MsgpObject{
-				msgp.Float64Type,
-				float64(0.99999999),
+				Type:  msgp.Float64Type,
+				Value: float64(0.99999999),
 			},
 		},
 		{
 			Name:  "\"just_a_string\"",
 			Input: "rWp1c3RfYV9zdHJpbmc=",
 			Expected: MsgpObject{
-				msgp.StrType,
-				"just_a_string",
+				Type:  msgp.StrType,
+				Value: "just_a_string",
 			},
 		},
 		{
 			Name:  "base64(anVzdCBhIGJpbmFyeSBzdHJpbmc=)",
 			Input: "xBRqdXN0IGEgYmluYXJ5IHN0cmluZw==", // binary("just a binary string")
 			Expected: MsgpObject{
-				msgp.BinType,
-				[]byte{106, 117, 115, 116, 32, 97, 32, 98, 105, 110, 97, 114, 121, 32, 115, 116, 114, 105, 110, 103},
+				Type:  msgp.BinType,
+				Value: []byte{106, 117, 115, 116, 32, 97, 32, 98, 105, 110, 97, 114, 121, 32, 115, 116, 114, 105, 110, 103},
 			},
 		},
 		{
 			Name:  "[1,2,3,4,5]",
 			Input: "lQECAwQF",
 			Expected: MsgpObject{
-				msgp.ArrayType,
-				[]MsgpObject{
-					{msgp.IntType, int64(1)},
-					{msgp.IntType, int64(2)},
-					{msgp.IntType, int64(3)},
-					{msgp.IntType, int64(4)},
-					{msgp.IntType, int64(5)},
+				Type: msgp.ArrayType,
+				Value: []MsgpObject{
+					{Type: msgp.IntType, Value: int64(1)},
+					{Type: msgp.IntType, Value: int64(2)},
+					{Type: msgp.IntType, Value: int64(3)},
+					{Type: msgp.IntType, Value: int64(4)},
+					{Type: msgp.IntType, Value: int64(5)},
 				},
 			},
 		},
@@ -188,12 +324,12 @@ func TestParse(t *testing.T) {
 			Name:  "[[1],[2],[\"three\"],4]",
 			Input: "lJEBkQKRpXRocmVlBA==",
 			Expected: MsgpObject{
-				msgp.ArrayType,
-				[]MsgpObject{
-					{msgp.ArrayType, []MsgpObject{{msgp.IntType, int64(1)}}},
-					{msgp.ArrayType, []MsgpObject{{msgp.IntType, int64(2)}}},
-					{msgp.ArrayType, []MsgpObject{{msgp.StrType, "three"}}},
-					{msgp.IntType, int64(4)},
+				Type: msgp.ArrayType,
+				Value: []MsgpObject{
+					{Type: msgp.ArrayType, Value: []MsgpObject{{Type: msgp.IntType, Value: int64(1)}}},
+					{Type: msgp.ArrayType, Value: []MsgpObject{{Type: msgp.IntType, Value: int64(2)}}},
+					{Type: msgp.ArrayType, Value: []MsgpObject{{Type: msgp.StrType, Value: "three"}}},
+					{Type: msgp.IntType, Value: int64(4)},
 				},
 			},
 		},
@@ -201,11 +337,11 @@ func TestParse(t *testing.T) {
 			Name:  "{\"a\":1}",
 			Input: "gaFhAQ==",
 			Expected: MsgpObject{
-				msgp.MapType,
-				MsgpMap{
-					[]string{"a"},
-					map[string]MsgpObject{
-						"a": {msgp.IntType, int64(1)},
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("a")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("a"): {Type: msgp.IntType, Value: int64(1)},
 					},
 				},
 			},
@@ -214,12 +350,12 @@ func TestParse(t *testing.T) {
 			Name:  "{\"longer_key\":2,\"null_key\":null}",
 			Input: "gqpsb25nZXJfa2V5AqhudWxsX2tlecA=",
 			Expected: MsgpObject{
-				msgp.MapType,
-				MsgpMap{
-					[]string{"longer_key", "null_key"},
-					map[string]MsgpObject{
-						"longer_key": {msgp.IntType, int64(2)},
-						"null_key":   {msgp.NilType, nil},
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("longer_key"), StringKey("null_key")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("longer_key"): {Type: msgp.IntType, Value: int64(2)},
+						StringKey("null_key"):   {Type: msgp.NilType, Value: nil},
 					},
 				},
 			},
@@ -228,11 +364,11 @@ func TestParse(t *testing.T) {
 			Name:  "{\"pi\":3.141592653589793}",
 			Input: "gaJwactACSH7VEQtGA==",
 			Expected: MsgpObject{
-				msgp.MapType,
-				MsgpMap{
-					[]string{"pi"},
-					map[string]MsgpObject{
-						"pi": {msgp.Float64Type, float64(3.141592653589793)},
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("pi")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("pi"): {Type: msgp.Float64Type, Value: float64(3.141592653589793)},
 					},
 				},
 			},
@@ -241,11 +377,11 @@ func TestParse(t *testing.T) {
 			Name:  "{\"32 bit float\":1.5}",
 			Input: "gawzMiBiaXQgZmxvYXTKP8AAAA==",
 			Expected: MsgpObject{
-				msgp.MapType,
-				MsgpMap{
-					[]string{"32 bit float"},
-					map[string]MsgpObject{
-						"32 bit float": {msgp.Float32Type, float32(1.5)},
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("32 bit float")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("32 bit float"): {Type: msgp.Float32Type, Value: float32(1.5)},
 					},
 				},
 			},
@@ -254,12 +390,12 @@ func TestParse(t *testing.T) {
 			Name:  "{\"first_null_key\":null,\"second_null_key\":null}",
 			Input: "gq5maXJzdF9udWxsX2tlecCvc2Vjb25kX251bGxfa2V5wA==",
 			Expected: MsgpObject{
-				msgp.MapType,
-				MsgpMap{
-					[]string{"first_null_key", "second_null_key"},
-					map[string]MsgpObject{
-						"first_null_key":  {msgp.NilType, nil},
-						"second_null_key": {msgp.NilType, nil},
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("first_null_key"), StringKey("second_null_key")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("first_null_key"):  {Type: msgp.NilType, Value: nil},
+						StringKey("second_null_key"): {Type: msgp.NilType, Value: nil},
 					},
 				},
 			},
@@ -268,25 +404,25 @@ func TestParse(t *testing.T) {
 			Name:  "{\"txn\":{\"amt\":5000000,\"fee\":1000,\"fv\":6000000,\"gen\":\"mainnet-v1.0\",\"gh\":\"wGHE2Pwdvd7S12BL5FaOP20EGYesN73ktiC1qzkkit8=\",\"lv\":6001000,\"note\":\"SGVsbG8gV29ybGQ=\",\"rcv\":\"GD64YIY3TWGDMCNPP553DZPPR6LDUSFQOIJVFDPPXWEG3FVOJCCDBBHU5A\",\"snd\":\"EW64GC6F24M7NDSC5R3ES4YUVE3ZXXNMARJHDCCCLIHZU6TBEOC7XRSBG4\",\"type\":\"pay\"}}",
 			Input: "gaN0eG6Ko2FtdM4ATEtAo2ZlZc0D6KJmds4AW42Ao2dlbqxtYWlubmV0LXYxLjCiZ2jZLHdHSEUyUHdkdmQ3UzEyQkw1RmFPUDIwRUdZZXNONzNrdGlDMXF6a2tpdDg9omx2zgBbkWikbm90ZbBTR1ZzYkc4Z1YyOXliR1E9o3Jjdtk6R0Q2NFlJWTNUV0dETUNOUFA1NTNEWlBQUjZMRFVTRlFPSUpWRkRQUFhXRUczRlZPSkNDREJCSFU1QaNzbmTZOkVXNjRHQzZGMjRNN05EU0M1UjNFUzRZVVZFM1pYWE5NQVJKSERDQ0NMSUhaVTZUQkVPQzdYUlNCRzSkdHlwZaNwYXk=",
 			Expected: MsgpObject{
-				msgp.MapType,
-				MsgpMap{
-					[]string{"txn"},
-					map[string]MsgpObject{
-						"txn": {
-							msgp.MapType,
-							MsgpMap{
-								[]string{"amt", "fee", "fv", "gen", "gh", "lv", "note", "rcv", "snd", "type"},
-								map[string]MsgpObject{
-									"amt":  {msgp.UintType, uint64(5000000)},
-									"fee":  {msgp.UintType, uint64(1000)},
-									"fv":   {msgp.UintType, uint64(6000000)},
-									"gen":  {msgp.StrType, "mainnet-v1.0"},
-									"gh":   {msgp.StrType, "wGHE2Pwdvd7S12BL5FaOP20EGYesN73ktiC1qzkkit8="},
-									"lv":   {msgp.UintType, uint64(6001000)},
-									"note": {msgp.StrType, "SGVsbG8gV29ybGQ="},
-									"rcv":  {msgp.StrType, "GD64YIY3TWGDMCNPP553DZPPR6LDUSFQOIJVFDPPXWEG3FVOJCCDBBHU5A"},
-									"snd":  {msgp.StrType, "EW64GC6F24M7NDSC5R3ES4YUVE3ZXXNMARJHDCCCLIHZU6TBEOC7XRSBG4"},
-									"type": {msgp.StrType, "pay"},
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("txn")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("txn"): {
+							Type: msgp.MapType,
+							Value: MsgpMap{
+								Order: []MapKey{StringKey("amt"), StringKey("fee"), StringKey("fv"), StringKey("gen"), StringKey("gh"), StringKey("lv"), StringKey("note"), StringKey("rcv"), StringKey("snd"), StringKey("type")},
+								Values: map[MapKey]MsgpObject{
+									StringKey("amt"):  {Type: msgp.UintType, Value: uint64(5000000)},
+									StringKey("fee"):  {Type: msgp.UintType, Value: uint64(1000)},
+									StringKey("fv"):   {Type: msgp.UintType, Value: uint64(6000000)},
+									StringKey("gen"):  {Type: msgp.StrType, Value: "mainnet-v1.0"},
+									StringKey("gh"):   {Type: msgp.StrType, Value: "wGHE2Pwdvd7S12BL5FaOP20EGYesN73ktiC1qzkkit8="},
+									StringKey("lv"):   {Type: msgp.UintType, Value: uint64(6001000)},
+									StringKey("note"): {Type: msgp.StrType, Value: "SGVsbG8gV29ybGQ="},
+									StringKey("rcv"):  {Type: msgp.StrType, Value: "GD64YIY3TWGDMCNPP553DZPPR6LDUSFQOIJVFDPPXWEG3FVOJCCDBBHU5A"},
+									StringKey("snd"):  {Type: msgp.StrType, Value: "EW64GC6F24M7NDSC5R3ES4YUVE3ZXXNMARJHDCCCLIHZU6TBEOC7XRSBG4"},
+									StringKey("type"): {Type: msgp.StrType, Value: "pay"},
 								},
 							},
 						},
@@ -294,6 +430,71 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name:  "{1:\"a\",2:\"b\"}",
+			Input: "ggGhYQKhYg==",
+			Expected: MsgpObject{
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{{msgp.IntType, int64(1)}, {msgp.IntType, int64(2)}},
+					Values: map[MapKey]MsgpObject{
+						{msgp.IntType, int64(1)}: {Type: msgp.StrType, Value: "a"},
+						{msgp.IntType, int64(2)}: {Type: msgp.StrType, Value: "b"},
+					},
+				},
+			},
+		},
+		{
+			Name:  "{0.0:1,-0.0:2}",
+			Input: "gssAAAAAAAAAAAHLgAAAAAAAAAAC",
+			Expected: MsgpObject{
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{{msgp.Float64Type, uint64(0)}, {msgp.Float64Type, uint64(1) << 63}},
+					Values: map[MapKey]MsgpObject{
+						{msgp.Float64Type, uint64(0)}:       {Type: msgp.IntType, Value: int64(1)},
+						{msgp.Float64Type, uint64(1) << 63}: {Type: msgp.IntType, Value: int64(2)},
+					},
+				},
+			},
+		},
+		{
+			Name:  "{base64(YQ==):true}",
+			Input: "gcQBYcM=",
+			Expected: MsgpObject{
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{{msgp.BinType, "a"}},
+					Values: map[MapKey]MsgpObject{
+						{msgp.BinType, "a"}: {Type: msgp.BoolType, Value: true},
+					},
+				},
+			},
+		},
+		{
+			Name:  "{[1]:true}",
+			Input: "gZEBww==",
+			Expected: MsgpObject{
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{{msgp.ArrayType, "\x91\x01"}},
+					Values: map[MapKey]MsgpObject{
+						{msgp.ArrayType, "\x91\x01"}: {Type: msgp.BoolType, Value: true},
+					},
+				},
+			},
+		},
+		{
+			Name:  "ext(42, base64(AQ==))",
+			Input: "1CoB",
+			Expected: MsgpObject{
+				Type: msgp.ExtensionType,
+				Value: Extension{
+					Type: 42,
+					Data: []byte{1},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -312,6 +513,7 @@ func TestParse(t *testing.T) {
 				t.Fatalf("Wrong type: got %v, expected %v\n", result.Type, test.Expected.Type)
 			}
 
+			result = stripMetadata(result)
 			if !reflect.DeepEqual(result.Value, test.Expected.Value) {
 				t.Fatalf("Objects unequal: got %+v, expected %+v\n", result.Value, test.Expected.Value)
 			}
@@ -320,20 +522,408 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestMapKeyObject(t *testing.T) {
+	key := MapKey{msgp.Float64Type, math.Float64bits(math.NaN())}
+	object, err := key.Object()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if object.Type != msgp.Float64Type || !math.IsNaN(object.Value.(float64)) {
+		t.Fatalf("Wrong object: got %v, expected NaN\n", object)
+	}
+
+	key = MapKey{msgp.ArrayType, "\x92\x01"} // [1, with the second element missing
+	_, err = key.Object()
+	if err == nil {
+		t.Fatalf("Expected an error for key %v\n", key)
+	}
+}
+
 func TestParseErrors(t *testing.T) {
-	t.Run("duplicate key", func(t *testing.T) {
-		input := "gqNrZXkBo2tleQI="
+	type ErrorTest struct {
+		Name     string
+		Input    string
+		Expected ParseError
+		Message  string
+	}
 
-		decoded, err := base64.StdEncoding.DecodeString(input)
-		if err != nil {
-			t.Fatalf("Could not decode input \"%v\": %v\n", input, err)
+	tests := []ErrorTest{
+		{
+			Name:  "duplicate key",
+			Input: "gqNrZXkBo2tleQI=", // {"key":1,"key":2}
+			Expected: ParseError{
+				Offset:   6,
+				Path:     Path{KeyElement(StringKey("key"))},
+				TypeByte: 0xa3,
+			},
+			Message: "Object has duplicate key at key (offset 0x6, type byte 0xa3)",
+		},
+		{
+			Name:  "invalid type",
+			Input: "gaFhkgHB", // {"a":[1,<0xc1>]}
+			Expected: ParseError{
+				Offset:   5,
+				Path:     Path{KeyElement(StringKey("a")), IndexElement(1)},
+				TypeByte: 0xc1,
+			},
+			Message: "Invalid MessagePack type at a[1] (offset 0x5, type byte 0xc1)",
+		},
+		{
+			Name:  "truncated",
+			Input: "kgE=", // [1,
+			Expected: ParseError{
+				Offset:   2,
+				Path:     Path{IndexElement(1)},
+				TypeByte: -1,
+			},
+			Message: "msgp: too few bytes left to read object at [1] (offset 0x2)",
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			decoded, err := base64.StdEncoding.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			_, _, err = Parse(decoded)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a ParseError, got %v\n", err)
+			}
+
+			if parseErr.Offset != test.Expected.Offset {
+				t.Errorf("Wrong offset: got %d, expected %d\n", parseErr.Offset, test.Expected.Offset)
+			}
+			if !reflect.DeepEqual(parseErr.Path, test.Expected.Path) {
+				t.Errorf("Wrong path: got %v, expected %v\n", parseErr.Path, test.Expected.Path)
+			}
+			if parseErr.TypeByte != test.Expected.TypeByte {
+				t.Errorf("Wrong type byte: got %d, expected %d\n", parseErr.TypeByte, test.Expected.TypeByte)
+			}
+			if parseErr.Error() != test.Message {
+				t.Errorf("Wrong message: got %q, expected %q\n", parseErr.Error(), test.Message)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	type FormatTest struct {
+		Name     string
+		Input    string
+		Expected Format
+	}
+
+	tests := []FormatTest{
+		{
+			Name:     "fixint",
+			Input:    "AQ==", // 1
+			Expected: FormatPositiveFixint,
+		},
+		{
+			Name:     "negative fixint",
+			Input:    "/w==", // -1
+			Expected: FormatNegativeFixint,
+		},
+		{
+			Name:     "uint8",
+			Input:    "zAE=", // 1
+			Expected: FormatUint8,
+		},
+		{
+			Name:     "uint64",
+			Input:    "zwAAAAAAAAAB", // 1
+			Expected: FormatUint64,
+		},
+		{
+			Name:     "fixstr",
+			Input:    "oWE=", // "a"
+			Expected: FormatFixstr,
+		},
+		{
+			Name:     "str8",
+			Input:    "2QFh", // "a"
+			Expected: FormatStr8,
+		},
+		{
+			Name:     "nil",
+			Input:    "wA==", // null
+			Expected: FormatNil,
+		},
+		{
+			Name:     "map16",
+			Input:    "3gAA", // {}
+			Expected: FormatMap16,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			decoded, err := base64.StdEncoding.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			result, _, err := Parse(decoded)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if result.Format != test.Expected {
+				t.Fatalf("Wrong format: got %v, expected %v\n", result.Format, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	type LimitTest struct {
+		Name     string
+		Input    []byte
+		Options  ParseOptions
+		Expected error
+	}
+
+	deep := append(bytes.Repeat([]byte{0x91}, DefaultMaxDepth), 0xc0) // [[[...null...]]]
+
+	tests := []LimitTest{
+		{
+			Name:     "default depth",
+			Input:    deep,
+			Expected: nil,
+		},
+		{
+			Name:     "default depth exceeded",
+			Input:    append([]byte{0x91}, deep...),
+			Expected: ErrMaxDepth,
+		},
+		{
+			Name:     "depth",
+			Input:    []byte{0x91, 0x91, 0xc0}, // [[null]]
+			Options:  ParseOptions{MaxDepth: 2},
+			Expected: nil,
+		},
+		{
+			Name:     "depth exceeded",
+			Input:    []byte{0x91, 0x91, 0x91, 0xc0}, // [[[null]]]
+			Options:  ParseOptions{MaxDepth: 2},
+			Expected: ErrMaxDepth,
+		},
+		{
+			Name:     "container length",
+			Input:    []byte{0x93, 0x01, 0x02, 0x03}, // [1,2,3]
+			Options:  ParseOptions{MaxContainerLength: 3},
+			Expected: nil,
+		},
+		{
+			Name:     "container length exceeded",
+			Input:    []byte{0x93, 0x01, 0x02, 0x03}, // [1,2,3]
+			Options:  ParseOptions{MaxContainerLength: 2},
+			Expected: ErrMaxContainerLength,
+		},
+		{
+			Name:     "default container length exceeded",
+			Input:    []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, // array32 with 4294967295 elements
+			Expected: ErrMaxContainerLength,
+		},
+		{
+			Name:     "map longer than input",
+			Input:    []byte{0xde, 0xff, 0xff}, // map16 with 65535 entries
+			Expected: ErrAllocationLimit,
+		},
+		{
+			Name:     "nested arrays longer than input",
+			Input:    []byte{0x92, 0x9f, 0x9f}, // [[...15 elements...], [...15 elements...]]
+			Expected: ErrAllocationLimit,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			_, _, err := ParseWithOptions(test.Input, test.Options)
+			if test.Expected == nil {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v\n", err)
+				}
+				return
+			}
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(err, test.Expected) {
+				t.Fatalf("Wrong error: got %v, expected %v\n", err, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestParseOffsets(t *testing.T) {
+	decoded, _ := base64.StdEncoding.DecodeString("gqFhkgHNASyhYqN4eXo=") // {"a":[1,300],"b":"xyz"}
+
+	result, _, err := Parse(decoded)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	valueMap := result.Value.(MsgpMap)
+	array := valueMap.Values[StringKey("a")]
+	elements := array.Value.([]MsgpObject)
+
+	tests := []struct {
+		Name     string
+		Object   MsgpObject
+		Expected Span
+	}{
+		{"map", result, Span{0, 14}},
+		{"array", array, Span{3, 8}},
+		{"fixint", elements[0], Span{4, 5}},
+		{"uint16", elements[1], Span{5, 8}},
+		{"string", valueMap.Values[StringKey("b")], Span{10, 14}},
+	}
+
+	for _, test := range tests {
+		if test.Object.Span() != test.Expected {
+			t.Errorf("Wrong offsets for %s: got %v, expected %v\n", test.Name, test.Object.Span(), test.Expected)
 		}
+	}
+}
+
+func TestParseTimestamps(t *testing.T) {
+	type TimestampTest struct {
+		Name     string
+		Input    string
+		Expected time.Time
+		Format   Format
+	}
+
+	tests := []TimestampTest{
+		{
+			Name:     "32 bit",
+			Input:    "1v9fXhAA",
+			Expected: time.Unix(1600000000, 0),
+			Format:   FormatFixext4,
+		},
+		{
+			Name:     "64 bit",
+			Input:    "1/8AAAAAX14QAA==",
+			Expected: time.Unix(1600000000, 0),
+			Format:   FormatFixext8,
+		},
+		{
+			Name:     "64 bit with nanoseconds",
+			Input:    "1/8AAAfQX14QAA==",
+			Expected: time.Unix(1600000000, 500),
+			Format:   FormatFixext8,
+		},
+		{
+			Name:     "96 bit",
+			Input:    "xwz/AAAAAAAAAABfXhAA",
+			Expected: time.Unix(1600000000, 0),
+			Format:   FormatExt8,
+		},
+		{
+			Name:     "96 bit before epoch",
+			Input:    "xwz/AAAAAP//////////",
+			Expected: time.Unix(-1, 0),
+			Format:   FormatExt8,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			decoded, err := base64.StdEncoding.DecodeString(test.Input)
+			if err != nil {
+				t.Fatalf("Could not decode input \"%v\": %v\n", test.Input, err)
+			}
+
+			result, _, err := Parse(decoded)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			if result.Type != msgp.TimeType {
+				t.Fatalf("Wrong type: got %v, expected %v\n", result.Type, msgp.TimeType)
+			}
+
+			if !result.Value.(time.Time).Equal(test.Expected) {
+				t.Fatalf("Wrong time: got %v, expected %v\n", result.Value, test.Expected)
+			}
 
-		_, _, err = Parse(decoded)
-		if err == nil || err.Error() != "Object has duplicate key" {
-			t.Fatal("No error for duplicate key\n")
+			if result.Format != test.Format {
+				t.Fatalf("Wrong format: got %v, expected %v\n", result.Format, test.Format)
+			}
 		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestParseRegisteredExtension(t *testing.T) {
+	RegisterExtension(42, func(data []byte) (MsgpObject, error) {
+		decoded, _, err := Parse(data)
+		return decoded, err
 	})
+	defer RegisterExtension(42, nil)
+
+	input := "1iqBoWEB" // ext(42, {"a":1})
+
+	decoded, err := base64.StdEncoding.DecodeString(input)
+	if err != nil {
+		t.Fatalf("Could not decode input \"%v\": %v\n", input, err)
+	}
+
+	result, _, err := Parse(decoded)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	expected := MsgpObject{
+		Type: msgp.ExtensionType,
+		Value: Extension{
+			Type: 42,
+			Data: []byte{0x81, 0xa1, 0x61, 0x01},
+			Decoded: &MsgpObject{
+				Type: msgp.MapType,
+				Value: MsgpMap{
+					Order: []MapKey{StringKey("a")},
+					Values: map[MapKey]MsgpObject{
+						StringKey("a"): {Type: msgp.IntType, Value: int64(1)},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(stripMetadata(result), expected) {
+		t.Fatalf("Objects unequal: got %+v, expected %+v\n", result, expected)
+	}
+}
+
+func TestRegisterExtensionConcurrently(t *testing.T) {
+	defer RegisterExtension(43, nil)
+
+	input := []byte{0xd4, 0x2b, 0x01} // ext(43, base64(AQ==))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterExtension(43, func(data []byte) (MsgpObject, error) {
+				return MsgpObject{Type: msgp.BinType, Value: data}, nil
+			})
+			RegisterExtension(43, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			if _, _, err := Parse(input); err != nil {
+				t.Errorf("Unexpected error: %v\n", err)
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 func TestParseObjectStream(t *testing.T) {
@@ -359,42 +949,42 @@ func TestParseObjectStream(t *testing.T) {
 	}
 
 	expectedFirstObject := MsgpObject{
-		msgp.MapType,
-		MsgpMap{
-			Order: []string{"id", "data"},
-			Values: map[string]MsgpObject{
-				"id": {
-					msgp.IntType,
-					int64(0),
+		Type: msgp.MapType,
+		Value: MsgpMap{
+			Order: []MapKey{StringKey("id"), StringKey("data")},
+			Values: map[MapKey]MsgpObject{
+				StringKey("id"): {
+					Type:  msgp.IntType,
+					Value: int64(0),
 				},
-				"data": {
-					msgp.IntType,
-					int64(7),
+				StringKey("data"): {
+					Type:  msgp.IntType,
+					Value: int64(7),
 				},
 			},
 		},
 	}
-	if !reflect.DeepEqual(firstObject, expectedFirstObject) {
+	if !reflect.DeepEqual(stripMetadata(firstObject), expectedFirstObject) {
 		t.Errorf("Incorrect first object: got %+v, expected %+v\n", firstObject, expectedFirstObject)
 	}
 
 	expectedSecondObject := MsgpObject{
-		msgp.MapType,
-		MsgpMap{
-			Order: []string{"id", "data"},
-			Values: map[string]MsgpObject{
-				"id": {
-					msgp.IntType,
-					int64(1),
+		Type: msgp.MapType,
+		Value: MsgpMap{
+			Order: []MapKey{StringKey("id"), StringKey("data")},
+			Values: map[MapKey]MsgpObject{
+				StringKey("id"): {
+					Type:  msgp.IntType,
+					Value: int64(1),
 				},
-				"data": {
-					msgp.StrType,
-					"hello",
+				StringKey("data"): {
+					Type:  msgp.StrType,
+					Value: "hello",
 				},
 			},
 		},
 	}
-	if !reflect.DeepEqual(secondObject, expectedSecondObject) {
+	if !reflect.DeepEqual(stripMetadata(secondObject), expectedSecondObject) {
 		t.Errorf("Incorrect second object: got %+v, expected %+v\n", secondObject, expectedSecondObject)
 	}
 }
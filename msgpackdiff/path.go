@@ -0,0 +1,234 @@
+package msgpackdiff
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// PathElement is one step of a Path. It is either a key of a map or an index of an array.
+type PathElement struct {
+	// The key of the element if it is in a map.
+	Key MapKey
+	// The index of the element if it is in an array.
+	Index int
+	// True if the element is in an array, false if it is in a map.
+	InArray bool
+	// True if the element matches any index of an array or any key of a map in Path.Match.
+	Wildcard bool
+	// True if the element is a wildcard that matches any number of elements in Path.Match,
+	// including none.
+	Recursive bool
+}
+
+// KeyElement creates a PathElement for the value of key in a map.
+func KeyElement(key MapKey) PathElement {
+	return PathElement{Key: key}
+}
+
+// IndexElement creates a PathElement for the element at index in an array.
+func IndexElement(index int) PathElement {
+	return PathElement{Index: index, InArray: true}
+}
+
+// AnyIndex is a PathElement that matches every element of an array, written as [*].
+var AnyIndex = PathElement{InArray: true, Wildcard: true}
+
+// AnyKey is a PathElement that matches the value of every key of a map, written as *.
+var AnyKey = PathElement{Wildcard: true}
+
+// AnyPath is a PathElement that matches any number of keys and indices, including none, written
+// as **.
+var AnyPath = PathElement{Wildcard: true, Recursive: true}
+
+// Path is a sequence of map keys and array indices that leads from an object to one of its
+// descendants.
+type Path []PathElement
+
+// String formats the path like a JavaScript property accessor, for example txn.note[3]. String keys
+// that are not identifiers are quoted in brackets, as are keys of other types.
+func (p Path) String() string {
+	var str strings.Builder
+	for i, element := range p {
+		switch {
+		case element.InArray && element.Wildcard:
+			str.WriteString("[*]")
+		case element.Recursive:
+			if i > 0 {
+				str.WriteString(".")
+			}
+			str.WriteString("**")
+		case element.Wildcard:
+			if i > 0 {
+				str.WriteString(".")
+			}
+			str.WriteString("*")
+		case element.InArray:
+			fmt.Fprintf(&str, "[%d]", element.Index)
+		case isIdentifierKey(element.Key):
+			if i > 0 {
+				str.WriteString(".")
+			}
+			str.WriteString(element.Key.Value.(string))
+		default:
+			fmt.Fprintf(&str, "[%s]", element.Key)
+		}
+	}
+	return str.String()
+}
+
+// isIdentifierKey returns true if key is a string that can be written in a path without quotes.
+func isIdentifierKey(key MapKey) bool {
+	if key.Type != msgp.StrType {
+		return false
+	}
+	str := key.Value.(string)
+	if len(str) == 0 {
+		return false
+	}
+	for i, c := range str {
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && (i == 0 || !isDigit) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParsePath parses a path in the form returned by Path.String, for example txns[3].sig or
+// ["a b"][int(1)]. Keys may be identifiers, quoted strings, or integers written as int(n) or
+// uint(n). A number alone in brackets is an array index. The wildcards [*], * and ** are AnyIndex,
+// AnyKey and AnyPath.
+func ParsePath(str string) (Path, error) {
+	path := Path{}
+	for i := 0; i < len(str); {
+		switch {
+		case str[i] == '[':
+			end := closingBracket(str, i)
+			if end < 0 {
+				return nil, fmt.Errorf("Unclosed bracket in path %q", str)
+			}
+			element, err := parseBracketElement(str[i+1 : end])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid element %s in path %q", str[i:end+1], str)
+			}
+			path = append(path, element)
+			i = end + 1
+		case i == 0 || str[i] == '.':
+			if str[i] == '.' {
+				i++
+			}
+			start := i
+			for i < len(str) && str[i] != '.' && str[i] != '[' {
+				i++
+			}
+			if str[start:i] == "*" {
+				path = append(path, AnyKey)
+				continue
+			}
+			if str[start:i] == "**" {
+				path = append(path, AnyPath)
+				continue
+			}
+			key := StringKey(str[start:i])
+			if !isIdentifierKey(key) {
+				return nil, fmt.Errorf("Invalid key %q in path %q", str[start:i], str)
+			}
+			path = append(path, KeyElement(key))
+		default:
+			return nil, fmt.Errorf("Invalid path %q", str)
+		}
+	}
+	return path, nil
+}
+
+// closingBracket returns the index of the bracket that closes the bracket at index start of str,
+// skipping over quoted strings, or -1 if there is none.
+func closingBracket(str string, start int) int {
+	quoted := false
+	for i := start + 1; i < len(str); i++ {
+		switch {
+		case quoted && str[i] == '\\':
+			i++
+		case str[i] == '"':
+			quoted = !quoted
+		case !quoted && str[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseBracketElement parses the contents of the brackets of a path element, which is a quoted
+// string key, an int(n) or uint(n) key, or an array index.
+func parseBracketElement(str string) (PathElement, error) {
+	switch {
+	case str == "*":
+		return AnyIndex, nil
+	case strings.HasPrefix(str, `"`):
+		key, err := strconv.Unquote(str)
+		return KeyElement(StringKey(key)), err
+	case strings.HasPrefix(str, "int(") && strings.HasSuffix(str, ")"):
+		value, err := strconv.ParseInt(str[len("int("):len(str)-1], 10, 64)
+		return KeyElement(MapKey{msgp.IntType, value}), err
+	case strings.HasPrefix(str, "uint(") && strings.HasSuffix(str, ")"):
+		value, err := strconv.ParseUint(str[len("uint("):len(str)-1], 10, 64)
+		return KeyElement(MapKey{msgp.UintType, value}), err
+	}
+	index, err := strconv.Atoi(str)
+	if err == nil && index < 0 {
+		err = errors.New("Negative index")
+	}
+	return IndexElement(index), err
+}
+
+// Lookup returns the descendant of object that the path leads to. If the path does not lead to an
+// object or has a wildcard, false is returned.
+func (p Path) Lookup(object MsgpObject) (MsgpObject, bool) {
+	for _, element := range p {
+		switch {
+		case element.Wildcard:
+			return MsgpObject{}, false
+		case element.InArray && object.Type == msgp.ArrayType:
+			valueArray := object.Value.([]MsgpObject)
+			if element.Index >= len(valueArray) {
+				return MsgpObject{}, false
+			}
+			object = valueArray[element.Index]
+		case !element.InArray && object.Type == msgp.MapType:
+			value, ok := object.Value.(MsgpMap).Values[element.Key]
+			if !ok {
+				return MsgpObject{}, false
+			}
+			object = value
+		default:
+			return MsgpObject{}, false
+		}
+	}
+	return object, true
+}
+
+// Match returns true if other leads to the same object as p, where the wildcards of p match any
+// index or key of the same kind of container, and AnyPath matches any number of elements. The
+// wildcards of other only match wildcards.
+func (p Path) Match(other Path) bool {
+	if len(p) != 0 && p[0].Recursive {
+		return p[1:].Match(other) || (len(other) != 0 && p.Match(other[1:]))
+	}
+	if len(p) == 0 || len(other) == 0 {
+		return len(p) == len(other)
+	}
+	element, otherElement := p[0], other[0]
+	switch {
+	case element.InArray != otherElement.InArray:
+		return false
+	case element.Wildcard:
+	case element != otherElement:
+		return false
+	}
+	return p[1:].Match(other[1:])
+}
@@ -0,0 +1,205 @@
+package msgpackdiff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+func TestPathString(t *testing.T) {
+	type PathTest struct {
+		Name     string
+		Path     Path
+		Expected string
+	}
+
+	tests := []PathTest{
+		{
+			Name:     "empty",
+			Path:     Path{},
+			Expected: "",
+		},
+		{
+			Name:     "keys",
+			Path:     Path{KeyElement(StringKey("txn")), KeyElement(StringKey("fee"))},
+			Expected: "txn.fee",
+		},
+		{
+			Name:     "index",
+			Path:     Path{KeyElement(StringKey("txns")), IndexElement(3), KeyElement(StringKey("sig"))},
+			Expected: "txns[3].sig",
+		},
+		{
+			Name:     "top-level index",
+			Path:     Path{IndexElement(0), KeyElement(StringKey("a"))},
+			Expected: "[0].a",
+		},
+		{
+			Name:     "quoted key",
+			Path:     Path{KeyElement(StringKey("a b")), KeyElement(StringKey("1x"))},
+			Expected: `["a b"]["1x"]`,
+		},
+		{
+			Name:     "int key",
+			Path:     Path{KeyElement(MapKey{Type: msgp.IntType, Value: int64(1)})},
+			Expected: "[int(1)]",
+		},
+		{
+			Name:     "wildcards",
+			Path:     Path{KeyElement(StringKey("txns")), AnyIndex, AnyKey, AnyKey},
+			Expected: "txns[*].*.*",
+		},
+		{
+			Name:     "recursive wildcards",
+			Path:     Path{AnyPath, KeyElement(StringKey("sig")), AnyPath},
+			Expected: "**.sig.**",
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			actual := test.Path.String()
+			if actual != test.Expected {
+				t.Fatalf("Wrong string: got %s, expected %s\n", actual, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	type ParsePathTest struct {
+		Name     string
+		Path     string
+		Expected Path
+	}
+
+	tests := []ParsePathTest{
+		{
+			Name:     "empty",
+			Path:     "",
+			Expected: Path{},
+		},
+		{
+			Name:     "keys",
+			Path:     "txn.fee",
+			Expected: Path{KeyElement(StringKey("txn")), KeyElement(StringKey("fee"))},
+		},
+		{
+			Name:     "index",
+			Path:     "txns[3].sig",
+			Expected: Path{KeyElement(StringKey("txns")), IndexElement(3), KeyElement(StringKey("sig"))},
+		},
+		{
+			Name:     "top-level index",
+			Path:     "[0].a",
+			Expected: Path{IndexElement(0), KeyElement(StringKey("a"))},
+		},
+		{
+			Name:     "quoted key",
+			Path:     `["a b"]["x]\"y"]`,
+			Expected: Path{KeyElement(StringKey("a b")), KeyElement(StringKey(`x]"y`))},
+		},
+		{
+			Name:     "wildcards",
+			Path:     "*.txns[*]",
+			Expected: Path{AnyKey, KeyElement(StringKey("txns")), AnyIndex},
+		},
+		{
+			Name:     "recursive wildcards",
+			Path:     "**.txns[*].**",
+			Expected: Path{AnyPath, KeyElement(StringKey("txns")), AnyIndex, AnyPath},
+		},
+		{
+			Name:     "integer keys",
+			Path:     "[int(-1)][uint(2)]",
+			Expected: Path{KeyElement(MapKey{Type: msgp.IntType, Value: int64(-1)}), KeyElement(MapKey{Type: msgp.UintType, Value: uint64(2)})},
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			actual, err := ParsePath(test.Path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			if !reflect.DeepEqual(actual, test.Expected) {
+				t.Fatalf("Wrong path: got %v, expected %v\n", actual, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+
+	invalid := []string{"a..b", "a[1", "a[-1]", "a[x]", "1a", "a b"}
+	for _, path := range invalid {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("No error for %s\n", path)
+		}
+	}
+}
+
+func TestPathLookup(t *testing.T) {
+	// {"txns": [{"fee": 1}]}
+	object, _, err := Parse([]byte("\x81\xa4txns\x91\x81\xa3fee\x01"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	fee, ok := Path{KeyElement(StringKey("txns")), IndexElement(0), KeyElement(StringKey("fee"))}.Lookup(object)
+	if !ok || fee.Value != int64(1) {
+		t.Fatalf("Wrong value: got %v, expected 1\n", fee)
+	}
+
+	missing := []Path{
+		{KeyElement(StringKey("fee"))},
+		{KeyElement(StringKey("txns")), IndexElement(1)},
+		{KeyElement(StringKey("txns")), KeyElement(StringKey("fee"))},
+	}
+	for _, path := range missing {
+		if _, ok := path.Lookup(object); ok {
+			t.Errorf("Found a value at %s\n", path)
+		}
+	}
+}
+
+func TestPathMatch(t *testing.T) {
+	type MatchTest struct {
+		Pattern  string
+		Path     string
+		Expected bool
+	}
+
+	tests := []MatchTest{
+		{Pattern: "txns[0].fee", Path: "txns[0].fee", Expected: true},
+		{Pattern: "txns[0].fee", Path: "txns[1].fee", Expected: false},
+		{Pattern: "txns[*].fee", Path: "txns[1].fee", Expected: true},
+		{Pattern: "txns[*].fee", Path: "txns.x.fee", Expected: false},
+		{Pattern: "txns.*", Path: "txns.fee", Expected: true},
+		{Pattern: "txns.*", Path: "txns[0]", Expected: false},
+		{Pattern: "txns.*", Path: "txns.fee.x", Expected: false},
+		{Pattern: "txns[0]", Path: "txns[*]", Expected: false},
+		{Pattern: "*.sig", Path: "sig", Expected: false},
+		{Pattern: "*.sig", Path: "txn.sig", Expected: true},
+		{Pattern: "*.sig", Path: "txns[0].sig", Expected: false},
+		{Pattern: "**.sig", Path: "sig", Expected: true},
+		{Pattern: "**.sig", Path: "txn.sig", Expected: true},
+		{Pattern: "**.sig", Path: "txns[0].msig.sig", Expected: true},
+		{Pattern: "**.sig", Path: "txns[0].sig.x", Expected: false},
+		{Pattern: "txns.**", Path: "txns", Expected: true},
+		{Pattern: "txns.**", Path: "txns[2][3]", Expected: true},
+		{Pattern: "a.**.b.**.c", Path: "a.x.b.y[0].c", Expected: true},
+		{Pattern: "a.**.b.**.c", Path: "a.c.b", Expected: false},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			pattern, _ := ParsePath(test.Pattern)
+			path, _ := ParsePath(test.Path)
+			if pattern.Match(path) != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", !test.Expected, test.Expected)
+			}
+		}
+		t.Run(test.Pattern+" "+test.Path, runTest)
+	}
+}
@@ -1,39 +1,86 @@
 package msgpackdiff
 
+import "github.com/algorand/msgp/msgp"
+
 type DifferenceType int
 
 const (
 	Deletion DifferenceType = iota
 	Addition
 	Replacement
+	// Move is an element of an array that is at a different position in side B, relative to the
+	// elements that are in both sides.
+	Move
 )
 
 type Layer struct {
-	Object       *MsgpObject
+	Object *MsgpObject
+	// The container from side B that corresponds to Object.
+	Other        *MsgpObject
 	CurrentIndex int
-	CurrentKey   string
+	CurrentKey   MapKey
 }
 
 type Difference struct {
 	Type   DifferenceType
 	Object MsgpObject
 	Path   []Layer
+	// True if the objects only differ in their format.
+	ShowFormat bool
+	// True if the objects are times with different extension types, which are shown with their
+	// formats.
+	ShowTimeExtension bool
+	// True if Object is the key of the map at the end of Path rather than its value. Only used for
+	// differences in the format of keys.
+	InKey bool
+	// The offsets of the difference in side A and side B. If the difference is a deletion or an
+	// addition, the side that does not have the object has the offsets of the container that the
+	// object is missing from.
+	Offsets [2]Span
+	// The index of the object in side B if the difference is a move.
+	NewIndex int
+	// True if the difference is the replacement of a number with another number, at least one of
+	// which is floating-point, in which case Delta is the new number minus the old one.
+	ShowDelta bool
+	Delta     float64
+}
+
+// Offset returns the offset of the object of the difference in the side that it comes from.
+func (diff Difference) Offset() int {
+	if diff.Type == Deletion || diff.Type == Move {
+		return diff.Offsets[0].Start
+	}
+	return diff.Offsets[1].Start
 }
 
 type Reporter struct {
-	Brief       bool
+	Brief bool
+	// Annotates the differences in the printed report with their offsets when true.
+	ShowOffsets bool
 	Path        []Layer
 	Differences []Difference
+	// The number of objects that were left out of the comparison because their paths matched
+	// CompareOptions.IgnorePaths.
+	Ignored int
+	// The number of strings that were equal to binary data only because they have the same bytes
+	// and CompareOptions.BinaryStrings is true. These are not differences, but the encoders of the
+	// objects disagree on their types.
+	BinaryStrings int
+
+	// the path to the container of the first layer of Path, for reporters that compare the
+	// descendants of another reporter's objects
+	prefix Path
 }
 
-func (r *Reporter) EnterMap(mapObject MsgpObject) {
+func (r *Reporter) EnterMap(mapA MsgpObject, mapB MsgpObject) {
 	mapLayer := Layer{
-		Object: &mapObject,
+		Object: &mapA,
+		Other:  &mapB,
 	}
 	r.Path = append(r.Path, mapLayer)
 }
 
-func (r *Reporter) SetKey(index int, key string) {
+func (r *Reporter) SetKey(index int, key MapKey) {
 	r.Path[len(r.Path)-1].CurrentIndex = index
 	r.Path[len(r.Path)-1].CurrentKey = key
 }
@@ -42,9 +89,10 @@ func (r *Reporter) LeaveMap() {
 	r.Path = r.Path[:len(r.Path)-1]
 }
 
-func (r *Reporter) EnterArray(arrayObject MsgpObject) {
+func (r *Reporter) EnterArray(arrayA MsgpObject, arrayB MsgpObject) {
 	arrayLayer := Layer{
-		Object: &arrayObject,
+		Object: &arrayA,
+		Other:  &arrayB,
 	}
 	r.Path = append(r.Path, arrayLayer)
 }
@@ -57,39 +105,119 @@ func (r *Reporter) LeaveArray() {
 	r.Path = r.Path[:len(r.Path)-1]
 }
 
+// currentPath returns the path to the current object from the object that the comparison started
+// at.
+func (r *Reporter) currentPath() Path {
+	path := append(Path{}, r.prefix...)
+	for _, layer := range r.Path {
+		if layer.Object.Type == msgp.MapType {
+			path = append(path, KeyElement(layer.CurrentKey))
+		} else {
+			path = append(path, IndexElement(layer.CurrentIndex))
+		}
+	}
+	return path
+}
+
+// containerSpans returns the offsets of the current containers in side A and side B.
+func (r *Reporter) containerSpans() (spans [2]Span) {
+	if len(r.Path) != 0 {
+		layer := r.Path[len(r.Path)-1]
+		spans[0] = layer.Object.Span()
+		spans[1] = layer.Other.Span()
+	}
+	return
+}
+
 func (r *Reporter) LogDeletion(deleted MsgpObject) {
+	offsets := r.containerSpans()
+	offsets[0] = deleted.Span()
 	d := Difference{
-		Type:   Deletion,
-		Object: deleted,
-		Path:   append([]Layer(nil), r.Path...),
+		Type:    Deletion,
+		Object:  deleted,
+		Path:    append([]Layer(nil), r.Path...),
+		Offsets: offsets,
 	}
 	r.Differences = append(r.Differences, d)
 }
 
 func (r *Reporter) LogAddition(added MsgpObject) {
+	offsets := r.containerSpans()
+	offsets[1] = added.Span()
 	d := Difference{
-		Type:   Addition,
-		Object: added,
-		Path:   append([]Layer(nil), r.Path...),
+		Type:    Addition,
+		Object:  added,
+		Path:    append([]Layer(nil), r.Path...),
+		Offsets: offsets,
 	}
 	r.Differences = append(r.Differences, d)
 }
 
 func (r *Reporter) LogChange(old MsgpObject, new MsgpObject) {
 	path := append([]Layer(nil), r.Path...)
+	offsets := [2]Span{old.Span(), new.Span()}
 	deletion := Difference{
-		Type:   Deletion,
-		Object: old,
-		Path:   path,
+		Type:    Deletion,
+		Object:  old,
+		Path:    path,
+		Offsets: offsets,
 	}
 	replacement := Difference{
-		Type:   Replacement,
-		Object: new,
-		Path:   path,
+		Type:    Replacement,
+		Object:  new,
+		Path:    path,
+		Offsets: offsets,
 	}
+	replacement.Delta, replacement.ShowDelta = numberDelta(old, new)
 	r.Differences = append(r.Differences, deletion, replacement)
 }
 
+func (r *Reporter) LogFormatChange(old MsgpObject, new MsgpObject) {
+	path := append([]Layer(nil), r.Path...)
+	offsets := [2]Span{old.Span(), new.Span()}
+	showTimeExtension := old.TimeExtension != 0 && new.TimeExtension != 0 && old.TimeExtension != new.TimeExtension
+	deletion := Difference{
+		Type:              Deletion,
+		Object:            old,
+		Path:              path,
+		ShowFormat:        true,
+		ShowTimeExtension: showTimeExtension,
+		Offsets:           offsets,
+	}
+	replacement := Difference{
+		Type:              Replacement,
+		Object:            new,
+		Path:              path,
+		ShowFormat:        true,
+		ShowTimeExtension: showTimeExtension,
+		Offsets:           offsets,
+	}
+	r.Differences = append(r.Differences, deletion, replacement)
+}
+
+// LogMove logs that the current element of the current array is at newIndex in side B, where it
+// is moved.
+func (r *Reporter) LogMove(object MsgpObject, moved MsgpObject, newIndex int) {
+	d := Difference{
+		Type:     Move,
+		Object:   object,
+		Path:     append([]Layer(nil), r.Path...),
+		Offsets:  [2]Span{object.Span(), moved.Span()},
+		NewIndex: newIndex,
+	}
+	r.Differences = append(r.Differences, d)
+}
+
+// LogKeyFormatChange logs that the current key of the current map is encoded with different formats
+// in side A and side B.
+func (r *Reporter) LogKeyFormatChange(old MsgpObject, new MsgpObject) {
+	start := len(r.Differences)
+	r.LogFormatChange(old, new)
+	for i := start; i < len(r.Differences); i++ {
+		r.Differences[i].InKey = true
+	}
+}
+
 func (r *Reporter) NumDifferences() int {
 	total := 0
 	for _, diff := range r.Differences {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ttacon/chalk"
 )
@@ -1576,3 +1577,295 @@ func TestTwoObjectsNoContext(t *testing.T) {
 		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
 	}
 }
+
+func TestIntKeys(t *testing.T) {
+	a, _ := GetBinary("ggGhYQKhYg==") // {1:"a",2:"b"}
+	b, _ := GetBinary("ggGhYQKhYw==") // {1:"a",2:"c"}
+
+	result, _ := Compare(a, b, CompareOptions{})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	expected := fmt.Sprintf(` {
+   int(1): "a",
+%s-  int(2): "b"%s
+%s+  int(2): "c"%s
+ }
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestExtension(t *testing.T) {
+	a, _ := GetBinary("1CoB") // ext(42, base64(AQ==))
+	b, _ := GetBinary("1CsB") // ext(43, base64(AQ==))
+
+	result, _ := Compare(a, b, CompareOptions{})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	expected := fmt.Sprintf(`%s-ext(42, base64(AQ==))%s
+%s+ext(43, base64(AQ==))%s
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestTimestampFormats(t *testing.T) {
+	a, _ := GetBinary("1v9fXhAA")             // timestamp32(1600000000)
+	b, _ := GetBinary("xwz/AAAAAAAAAABfXhAA") // timestamp96(1600000000)
+
+	result, _ := Compare(a, b, CompareOptions{StrictTimestamps: true})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	instant := time.Unix(1600000000, 0).Local()
+	expected := fmt.Sprintf(`%s-fixext4(%v)%s
+%s+ext8(%v)%s
+`, chalk.Red.String(), instant, chalk.ResetColor.String(), chalk.Green.String(), instant, chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestTimeExtensionFormats(t *testing.T) {
+	a, _ := GetBinary("xwwFAAAAAF9eEAAAAAAA") // msgp time(1600000000)
+	b, _ := GetBinary("xwz/AAAAAAAAAABfXhAA") // timestamp96(1600000000)
+
+	result, _ := Compare(a, b, CompareOptions{StrictEncoding: true})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	instant := time.Unix(1600000000, 0).Local()
+	expected := fmt.Sprintf(`%s-ext8(5)(%v)%s
+%s+ext8(-1)(%v)%s
+`, chalk.Red.String(), instant, chalk.ResetColor.String(), chalk.Green.String(), instant, chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	a, _ := GetBinary("gqFhkgHNASyhYqN4eXo=") // {"a":[1,300],"b":"xyz"}
+	b, _ := GetBinary("gqFhkgHNAS2hY4GheAE=") // {"a":[1,301],"c":{"x":1}}
+
+	result, _ := Compare(a, b, CompareOptions{ShowOffsets: true})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	expected := fmt.Sprintf(` {
+   "a": [
+     1,
+%s-    300 @0x5%s
+%s+    301 @0x5%s
+   ],
+%s-  "b": "xyz" @0xa%s
+%s+  "c": { @0xa
+%s+    "x": 1
+%s+  }%s
+ }
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String(),
+		chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.Green.String(),
+		chalk.Green.String(), chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestStrictEncoding(t *testing.T) {
+	a, _ := GetBinary("gqFhAaFizAI=")             // {fixstr("a"):1,"b":uint8(2)}
+	b, _ := GetBinary("gtkBYQGhYs8AAAAAAAAAAg==") // {str8("a"):1,"b":uint64(2)}
+
+	result, _ := Compare(a, b, CompareOptions{StrictEncoding: true})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	expected := fmt.Sprintf(` {
+%s-  fixstr("a"): 1,%s
+%s+  str8("a"): 1,%s
+%s-  "b": uint8(2)%s
+%s+  "b": uint64(2)%s
+ }
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String(),
+		chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestStrictEncodingNumberKinds(t *testing.T) {
+	a, _ := GetBinary("gaFhAQ==")         // {"a":fixint(1)}
+	b, _ := GetBinary("gaFhyz/wAAAAAAAA") // {"a":float64(1)}
+
+	// an integer and a float are different values unless flexible types is enabled
+	result, _ := Compare(a, b, CompareOptions{StrictEncoding: true})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	expected := fmt.Sprintf(` {
+%s-  "a": 1%s
+%s+  "a": 1 (delta +0)%s
+ }
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+
+	result, _ = Compare(a, b, CompareOptions{StrictEncoding: true, FlexibleTypes: true})
+
+	if result.Equal {
+		t.Error("Wrong result with flexible types")
+	}
+
+	builder.Reset()
+	result.PrintReport(&builder, 3)
+
+	expected = fmt.Sprintf(` {
+%s-  "a": fixint(1)%s
+%s+  "a": float64(1)%s
+ }
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String())
+	actual = builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report with flexible types:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestStrictEncodingContainerFormats(t *testing.T) {
+	a, _ := GetBinary("kQE=")     // fixarray([1])
+	b, _ := GetBinary("3AABAg==") // array16([2])
+
+	result, _ := Compare(a, b, CompareOptions{StrictEncoding: true})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	// the formats of the arrays are not reported, since their contents are different
+	expected := fmt.Sprintf(` [
+%s-  1%s
+%s+  2%s
+ ]
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestBinaryStringsStrictEncoding(t *testing.T) {
+	a, _ := GetBinary("gaFhomhp")     // {"a":fixstr("hi")}
+	b, _ := GetBinary("gaFhxAJoaQ==") // {"a":bin8(base64(aGk=))}
+
+	result, _ := Compare(a, b, CompareOptions{BinaryStrings: true, StrictEncoding: true})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	expected := fmt.Sprintf(` {
+%s-  "a": fixstr("hi")%s
+%s+  "a": bin8(base64(aGk=))%s
+ }
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
+
+func TestChangedArrayElements(t *testing.T) {
+	a, _ := GetBinary("k4GhYQGBoWECgaFhAw==") // [{"a":1},{"a":2},{"a":3}]
+	b, _ := GetBinary("k4GhYQWBoWECgaFhBg==") // [{"a":5},{"a":2},{"a":6}]
+
+	result, _ := Compare(a, b, CompareOptions{})
+
+	if result.Equal {
+		t.Error("Wrong result")
+	}
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	// the changes of each element are shown in that element
+	expected := fmt.Sprintf(` [
+   {
+%s-    "a": 1%s
+%s+    "a": 5%s
+   },
+   {
+     "a": 2
+   },
+   {
+%s-    "a": 3%s
+%s+    "a": 6%s
+   }
+ ]
+`, chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String(),
+		chalk.Red.String(), chalk.ResetColor.String(), chalk.Green.String(), chalk.ResetColor.String())
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}
@@ -0,0 +1,150 @@
+package msgpackdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Rule is a set of policies for comparing the objects at the paths that match Path. Rules are
+// usually written in a rules file, which is read by ParseRules.
+//
+// Every policy applies to the objects that Path matches and to their descendants, except for
+// Unordered, which only applies to the arrays that Path matches. The policies that are not set
+// are inherited from the enclosing objects, or from the CompareOptions.
+type Rule struct {
+	// The path that the rule applies to from each top-level object. It may contain wildcards.
+	Path Path
+	// Leaves the objects out of the comparison like CompareOptions.IgnorePaths.
+	Ignore bool
+	// Compares the arrays as multisets like CompareOptions.UnorderedPaths.
+	Unordered bool
+	// Overrides CompareOptions.FlexibleTypes if not nil.
+	FlexibleTypes *bool
+	// Overrides CompareOptions.IgnoreEmpty if not nil.
+	IgnoreEmpty *bool
+	// Overrides CompareOptions.Tolerance if not nil.
+	Tolerance *Tolerance
+}
+
+// Rules are the rules of a rules file. When several rules match the same path, the later rules
+// take precedence. A rule that matches a path takes precedence over the rules that only match its
+// ancestors, wherever it is in the file, since the policies of the ancestors are only inherited.
+type Rules []Rule
+
+// ruleJSON is a Rule as it is written in a rules file.
+type ruleJSON struct {
+	Path          string     `json:"path"`
+	Ignore        bool       `json:"ignore"`
+	Unordered     bool       `json:"unordered"`
+	FlexibleTypes *bool      `json:"flexibleTypes"`
+	IgnoreEmpty   *bool      `json:"ignoreEmpty"`
+	Tolerance     *Tolerance `json:"tolerance"`
+}
+
+// ParseRules parses a rules file, which is a JSON array of rules like
+//
+//	[
+//	  {"path": "txn.fv", "ignore": true},
+//	  {"path": "txns[*].signers", "unordered": true},
+//	  {"path": "prices", "tolerance": {"absolute": 1e-9, "relative": 1e-12, "ulps": 4}},
+//	  {"path": "amounts", "flexibleTypes": true},
+//	  {"path": "meta", "ignoreEmpty": true}
+//	]
+//
+// Each path is in the form accepted by ParsePath. Fields that are not policies are an error, so
+// that misspelled policies are not silently left out.
+func ParseRules(data []byte) (Rules, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var parsed []ruleJSON
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("Invalid rules: %w", err)
+	}
+
+	rules := make(Rules, len(parsed))
+	for i, rule := range parsed {
+		path, err := ParsePath(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("Rule %d: %w", i, err)
+		}
+		if rule.Tolerance != nil {
+			if err := rule.Tolerance.validate(); err != nil {
+				return nil, fmt.Errorf("Rule %d: %w", i, err)
+			}
+		}
+		rules[i] = Rule{
+			Path:          path,
+			Ignore:        rule.Ignore,
+			Unordered:     rule.Unordered,
+			FlexibleTypes: rule.FlexibleTypes,
+			IgnoreEmpty:   rule.IgnoreEmpty,
+			Tolerance:     rule.Tolerance,
+		}
+	}
+	return rules, nil
+}
+
+// LoadRules reads and parses the rules file at filename.
+func LoadRules(filename string) (Rules, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(data)
+}
+
+// at returns the options for comparing the object at path, which are options with the policies of
+// the rules that match path. The path starts with the index of the top-level object that the
+// object is in.
+func (options CompareOptions) at(path Path) CompareOptions {
+	if len(path) == 0 {
+		return options
+	}
+	for _, rule := range options.Rules {
+		if rule.Path.Match(path[1:]) {
+			options = rule.apply(options)
+		}
+	}
+	return options
+}
+
+// apply returns options with the policies of the rule that are inherited by descendants.
+func (rule Rule) apply(options CompareOptions) CompareOptions {
+	if rule.FlexibleTypes != nil {
+		options.FlexibleTypes = *rule.FlexibleTypes
+	}
+	if rule.IgnoreEmpty != nil {
+		options.IgnoreEmpty = *rule.IgnoreEmpty
+	}
+	if rule.Tolerance != nil {
+		options.Tolerance = *rule.Tolerance
+	}
+	return options
+}
+
+// fingerprintOptions is like at, but for the paths used by fingerprint, which have wildcards for
+// the indices of array elements. Any policy that may apply to the object at path is applied in the
+// way that makes the most objects equal, so that the fingerprints of equal objects are the same.
+func (options CompareOptions) fingerprintOptions(path Path) CompareOptions {
+	if len(path) == 0 {
+		return options
+	}
+	for _, rule := range options.Rules {
+		if !rule.Path.overlaps(path[1:]) {
+			continue
+		}
+		if rule.FlexibleTypes != nil {
+			options.FlexibleTypes = options.FlexibleTypes || *rule.FlexibleTypes
+		}
+		if rule.IgnoreEmpty != nil {
+			options.IgnoreEmpty = options.IgnoreEmpty || *rule.IgnoreEmpty
+		}
+		if rule.Tolerance != nil && !rule.Tolerance.isZero() {
+			options.Tolerance = *rule.Tolerance
+		}
+	}
+	return options
+}
@@ -0,0 +1,226 @@
+package msgpackdiff
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]byte(`[
+		{"path": "txn.fv", "ignore": true},
+		{"path": "txns[*].signers", "unordered": true},
+		{"path": "prices", "tolerance": {"absolute": 0.5, "relative": 1e-9, "ulps": 4}},
+		{"path": "amounts", "flexibleTypes": false, "ignoreEmpty": true}
+	]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+
+	no := false
+	yes := true
+	expected := Rules{
+		{Path: Path{KeyElement(StringKey("txn")), KeyElement(StringKey("fv"))}, Ignore: true},
+		{Path: Path{KeyElement(StringKey("txns")), AnyIndex, KeyElement(StringKey("signers"))}, Unordered: true},
+		{Path: Path{KeyElement(StringKey("prices"))}, Tolerance: &Tolerance{Absolute: 0.5, Relative: 1e-9, ULPs: 4}},
+		{Path: Path{KeyElement(StringKey("amounts"))}, FlexibleTypes: &no, IgnoreEmpty: &yes},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Fatalf("Wrong rules: got %v, expected %v\n", rules, expected)
+	}
+
+	invalid := []string{
+		`{"path": "a", "ignore": true}`,
+		`[{"path": "a", "ignor": true}]`,
+		`[{"path": "a b", "ignore": true}]`,
+		`[{"path": "a", "tolerance": {"absolute": -1}}]`,
+		`[{"path": "a", "tolerance": {"relative": -1}}]`,
+		`[{"path": "a", "tolerance": {"ulps": -1}}]`,
+		`[{"path": "a", "ignore": "yes"}]`,
+	}
+	for _, str := range invalid {
+		if _, err := ParseRules([]byte(str)); err == nil {
+			t.Errorf("No error for %s\n", str)
+		}
+	}
+}
+
+func TestCompareRules(t *testing.T) {
+	type RulesTest struct {
+		Name     string
+		A        string
+		B        string
+		Rules    string
+		Options  CompareOptions
+		Expected bool
+		// the type and path of each difference, other than replacements
+		Differences []string
+	}
+
+	tests := []RulesTest{
+		{
+			Name:     "ignore",
+			A:        `{"txn": {"fv": 1, "amt": 2}}`,
+			B:        `{"txn": {"fv": 2, "amt": 2}}`,
+			Rules:    `[{"path": "txn.fv", "ignore": true}]`,
+			Expected: true,
+		},
+		{
+			Name:        "unordered",
+			A:           `{"signers": ["a", "b"], "order": [1, 2]}`,
+			B:           `{"signers": ["b", "a"], "order": [2, 1]}`,
+			Rules:       `[{"path": "signers", "unordered": true}]`,
+			Expected:    false,
+			Differences: []string{"deletion [0].order[0]", "addition [0].order[2]"},
+		},
+		{
+			Name:        "tolerance",
+			A:           `{"prices": [1.5, 2.25], "other": 1.5}`,
+			B:           `{"prices": [1.5001, 2.25], "other": 1.5001}`,
+			Rules:       `[{"path": "prices", "tolerance": {"absolute": 0.001}}]`,
+			Expected:    false,
+			Differences: []string{"deletion [0].other"},
+		},
+		{
+			Name:        "outside tolerance",
+			A:           `{"prices": [1.5, 2.25]}`,
+			B:           `{"prices": [1.6, 2.25]}`,
+			Rules:       `[{"path": "prices", "tolerance": {"absolute": 0.001}}]`,
+			Expected:    false,
+			Differences: []string{"deletion [0].prices[0]", "addition [0].prices[0]"},
+		},
+		{
+			Name:        "flexible types",
+			A:           `{"amounts": {"a": 1, "b": 2}, "other": 1}`,
+			B:           `{"amounts": {"a": 1.0, "b": 2.0}, "other": 1.0}`,
+			Rules:       `[{"path": "amounts", "flexibleTypes": true}]`,
+			Expected:    false,
+			Differences: []string{"deletion [0].other"},
+		},
+		{
+			Name:        "flexible array elements",
+			A:           `{"amounts": [1, 2], "other": [1]}`,
+			B:           `{"amounts": [1.0, 2.0], "other": [1.0]}`,
+			Rules:       `[{"path": "amounts[*]", "flexibleTypes": true}]`,
+			Expected:    false,
+			Differences: []string{"deletion [0].other[0]", "addition [0].other[1]"},
+		},
+		{
+			Name:        "overridden option",
+			A:           `{"amounts": {"a": 1}, "other": 1}`,
+			B:           `{"amounts": {"a": 1.0}, "other": 1.0}`,
+			Rules:       `[{"path": "amounts", "flexibleTypes": false}]`,
+			Options:     CompareOptions{FlexibleTypes: true},
+			Expected:    false,
+			Differences: []string{"deletion [0].amounts.a"},
+		},
+		{
+			Name:        "ignore empty",
+			A:           `{"meta": {"a": 1, "b": ""}, "other": {"c": []}}`,
+			B:           `{"meta": {"a": 1}, "other": {}}`,
+			Rules:       `[{"path": "meta", "ignoreEmpty": true}]`,
+			Expected:    false,
+			Differences: []string{"deletion [0].other.c"},
+		},
+		{
+			Name:        "ignore empty leaf",
+			A:           `{"txn": {"amt": 1, "note": "", "fee": ""}}`,
+			B:           `{"txn": {"amt": 1}}`,
+			Rules:       `[{"path": "txn.note", "ignoreEmpty": true}]`,
+			Expected:    false,
+			Differences: []string{"deletion [0].txn.fee"},
+		},
+		{
+			Name:        "ignore empty leaf with ignored order",
+			A:           `{"txn": {"amt": 1}}`,
+			B:           `{"txn": {"note": "", "fee": "", "amt": 1}}`,
+			Rules:       `[{"path": "txn.note", "ignoreEmpty": true}]`,
+			Options:     CompareOptions{IgnoreOrder: true},
+			Expected:    false,
+			Differences: []string{"addition [0].txn.fee"},
+		},
+		{
+			Name:     "ignore empty array elements",
+			A:        `{"list": [1, [], 2]}`,
+			B:        `{"list": [1, 2, {}]}`,
+			Rules:    `[{"path": "list[*]", "ignoreEmpty": true}]`,
+			Expected: true,
+		},
+		{
+			Name:     "ignore empty leaf in unordered arrays",
+			A:        `{"txns": [{"id": 1, "note": ""}, {"id": 2}]}`,
+			B:        `{"txns": [{"id": 2}, {"id": 1}]}`,
+			Rules:    `[{"path": "txns", "unordered": true}, {"path": "txns[*].note", "ignoreEmpty": true}]`,
+			Expected: true,
+		},
+		{
+			Name:     "later rules take precedence",
+			A:        `{"amounts": {"a": 1, "b": 2}}`,
+			B:        `{"amounts": {"a": 1.0, "b": 2.0}}`,
+			Rules:    `[{"path": "*", "flexibleTypes": false}, {"path": "amounts", "flexibleTypes": true}]`,
+			Expected: true,
+		},
+		{
+			Name:        "deeper rules take precedence",
+			A:           `{"amounts": {"a": 1, "b": 2}}`,
+			B:           `{"amounts": {"a": 1.0, "b": 2.0}}`,
+			Rules:       `[{"path": "amounts.a", "flexibleTypes": true}, {"path": "amounts", "flexibleTypes": false}]`,
+			Expected:    false,
+			Differences: []string{"deletion [0].amounts.b"},
+		},
+		{
+			Name:     "unordered with tolerance",
+			A:        `{"prices": [1.5, 2.25, 3]}`,
+			B:        `{"prices": [2.2501, 3, 1.4999]}`,
+			Rules:    `[{"path": "prices", "unordered": true, "tolerance": {"absolute": 0.001}}]`,
+			Expected: true,
+		},
+		{
+			Name:     "unordered with flexible elements",
+			A:        `{"txns": [{"amt": 1}, {"amt": 2}]}`,
+			B:        `{"txns": [{"amt": 2.0}, {"amt": 1.0}]}`,
+			Rules:    `[{"path": "txns", "unordered": true}, {"path": "txns[*].amt", "flexibleTypes": true}]`,
+			Expected: true,
+		},
+	}
+
+	diffTypes := map[DifferenceType]string{Deletion: "deletion", Addition: "addition", Move: "move"}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			objectsA, err := ParseJSON([]byte(test.A), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			objectsB, err := ParseJSON([]byte(test.B), ParseOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			test.Options.Rules, err = ParseRules([]byte(test.Rules))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			result := CompareParsed(objectsA, objectsB, test.Options)
+			if result.Equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", result.Equal, test.Expected)
+			}
+
+			differences := []string{}
+			for _, diff := range result.Reporter.Differences {
+				if diff.Type != Replacement {
+					differences = append(differences, fmt.Sprintf("%s %s", diffTypes[diff.Type], diffPath(diff)))
+				}
+			}
+			if test.Differences == nil {
+				test.Differences = []string{}
+			}
+			if !reflect.DeepEqual(differences, test.Differences) {
+				t.Fatalf("Wrong differences: got %v, expected %v\n", differences, test.Differences)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
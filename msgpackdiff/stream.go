@@ -0,0 +1,235 @@
+package msgpackdiff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// StreamComparison compares the top-level objects of two MessagePack streams one pair at a time.
+type StreamComparison struct {
+	// True if every pair of objects compared so far has been equal.
+	Equal bool
+	// The number of top-level objects that have been compared so far.
+	Index int
+
+	readers [2]*bufio.Reader
+	// the offset of the next object in each stream
+	offsets [2]int
+	options CompareOptions
+}
+
+// CompareReaders begins a comparison of the MessagePack objects in the streams a and b. Unlike
+// Compare, the streams are not read up front. Each call to Next on the returned StreamComparison
+// reads and compares the next top-level object from each stream, so the memory used is bounded by
+// the size of the largest object rather than the size of the streams. Since only one pair of
+// objects is available at a time, the top-level objects are compared by their position in the
+// streams, rather than aligned like they are by Compare.
+func CompareReaders(a io.Reader, b io.Reader, options CompareOptions) *StreamComparison {
+	return &StreamComparison{
+		Equal:   true,
+		readers: [2]*bufio.Reader{bufio.NewReader(a), bufio.NewReader(b)},
+		options: options,
+	}
+}
+
+// Next reads the next top-level object from each stream and compares them. The objects of the
+// returned result are arrays that contain the objects that were read, so that PrintReport shows
+// them in the same way as a report from Compare. If one stream ends before the other, the
+// remaining objects of the longer stream are reported as deletions or additions. Once both streams
+// have ended, Next returns io.EOF.
+func (s *StreamComparison) Next() (result CompareResult, err error) {
+	result.Reporter.Brief = s.options.Brief
+	result.Reporter.ShowOffsets = s.options.ShowOffsets
+
+	for i, reader := range s.readers {
+		objects := []MsgpObject{}
+
+		start := s.offsets[i]
+
+		var raw []byte
+		raw, err = readObject(reader)
+		if err != nil && err != io.EOF {
+			err = fmt.Errorf("Failed to read %s object: %w", sides[i], readError(raw, start, err))
+			return
+		}
+		if err == nil {
+			var object MsgpObject
+			object, _, err = newParser(raw, s.options.ParseOptions).parseAt(raw, start, 0)
+			if err != nil {
+				err = fmt.Errorf("Failed to parse %s object: %w", sides[i], err)
+				return
+			}
+			objects = append(objects, object)
+			s.offsets[i] += len(raw)
+		}
+
+		result.Objects[i] = MsgpObject{
+			Type:  msgp.ArrayType,
+			Value: objects,
+			Start: start,
+			End:   s.offsets[i],
+		}
+	}
+
+	if len(result.Objects[0].Value.([]MsgpObject)) == 0 && len(result.Objects[1].Value.([]MsgpObject)) == 0 {
+		err = io.EOF
+		return
+	}
+	err = nil
+
+	result.Equal = compareObjects(&result.Reporter, result.Objects[0], result.Objects[1], s.options)
+	s.Equal = s.Equal && result.Equal
+	s.Index++
+
+	return
+}
+
+// sides are the names of the two streams in error messages.
+var sides = [2]string{"first", "second"}
+
+// readError converts an error from readObject into a *ParseError, where raw is the part of the
+// object at offset that was read before the error. An invalid type is reported at the byte that
+// has it, and a stream that ends early is reported at the start of the object. Errors that do not
+// come from the content of the stream are returned unchanged.
+func readError(raw []byte, offset int, err error) error {
+	parseErr := &ParseError{
+		Offset:   offset,
+		TypeByte: -1,
+		Err:      err,
+	}
+	switch {
+	case err == errInvalidType:
+		parseErr.Offset += len(raw) - 1
+		parseErr.TypeByte = int(raw[len(raw)-1])
+	case err == io.ErrUnexpectedEOF:
+		parseErr.TypeByte = int(raw[0])
+	default:
+		return err
+	}
+	return parseErr
+}
+
+// errInvalidType is the error returned by readObject for a byte that does not start an object.
+var errInvalidType = errors.New("Invalid MessagePack type")
+
+// readObject reads the MessagePack encoding of the next object in r. If r has no more objects,
+// io.EOF is returned. If r ends partway through an object, io.ErrUnexpectedEOF is returned. When
+// an error is returned, the bytes of the object that were read before the error are returned with
+// it.
+func readObject(r *bufio.Reader) ([]byte, error) {
+	var raw bytes.Buffer
+
+	// the number of objects that still need to be read, including the elements of containers
+	pending := 1
+	for pending > 0 {
+		lead, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && raw.Len() != 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return raw.Bytes(), err
+		}
+		raw.WriteByte(lead)
+		pending--
+
+		// the number of bytes after the lead byte that hold the length of the object, and the
+		// number of bytes that follow the lead byte and length
+		lengthSize := 0
+		extra := 0
+
+		format := getFormat(lead)
+		switch format {
+		case FormatPositiveFixint, FormatNegativeFixint, FormatNil, FormatFalse, FormatTrue:
+		case FormatFixmap:
+			pending += 2 * int(lead&0x0f)
+		case FormatFixarray:
+			pending += int(lead & 0x0f)
+		case FormatFixstr:
+			extra = int(lead & 0x1f)
+		case FormatUint8, FormatInt8:
+			extra = 1
+		case FormatUint16, FormatInt16:
+			extra = 2
+		case FormatUint32, FormatInt32, FormatFloat32:
+			extra = 4
+		case FormatUint64, FormatInt64, FormatFloat64:
+			extra = 8
+		case FormatBin8, FormatStr8:
+			lengthSize = 1
+		case FormatBin16, FormatStr16, FormatArray16, FormatMap16:
+			lengthSize = 2
+		case FormatBin32, FormatStr32, FormatArray32, FormatMap32:
+			lengthSize = 4
+		case FormatExt8:
+			lengthSize = 1
+			extra = 1
+		case FormatExt16:
+			lengthSize = 2
+			extra = 1
+		case FormatExt32:
+			lengthSize = 4
+			extra = 1
+		case FormatFixext1:
+			extra = 2
+		case FormatFixext2:
+			extra = 3
+		case FormatFixext4:
+			extra = 5
+		case FormatFixext8:
+			extra = 9
+		case FormatFixext16:
+			extra = 17
+		default:
+			return raw.Bytes(), errInvalidType
+		}
+
+		if lengthSize != 0 {
+			if _, err := io.CopyN(&raw, r, int64(lengthSize)); err != nil {
+				return raw.Bytes(), unexpectedEOF(err)
+			}
+			encoded := raw.Bytes()[raw.Len()-lengthSize:]
+
+			var length uint64
+			switch lengthSize {
+			case 1:
+				length = uint64(encoded[0])
+			case 2:
+				length = uint64(binary.BigEndian.Uint16(encoded))
+			case 4:
+				length = uint64(binary.BigEndian.Uint32(encoded))
+			}
+
+			switch format {
+			case FormatArray16, FormatArray32:
+				pending += int(length)
+			case FormatMap16, FormatMap32:
+				pending += 2 * int(length)
+			default:
+				extra += int(length)
+			}
+		}
+
+		if extra != 0 {
+			if _, err := io.CopyN(&raw, r, int64(extra)); err != nil {
+				return raw.Bytes(), unexpectedEOF(err)
+			}
+		}
+	}
+
+	return raw.Bytes(), nil
+}
+
+// unexpectedEOF converts io.EOF into io.ErrUnexpectedEOF, since reaching the end of a stream in the
+// middle of an object is an error.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
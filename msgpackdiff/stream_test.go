@@ -0,0 +1,152 @@
+package msgpackdiff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestReadObject(t *testing.T) {
+	algoTxn, err := ioutil.ReadFile("../test/algo_txn_binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := []string{
+		"wA==",                             // null
+		"zwAAAAAAAAAB",                     // uint64(1)
+		"lJEBkQKRpXRocmVlBA==",             // [[1],[2],["three"],4]
+		"gqpsb25nZXJfa2V5AqhudWxsX2tlecA=", // {"longer_key":2,"null_key":null}
+		"xBRqdXN0IGEgYmluYXJ5IHN0cmluZw==", // binary("just a binary string")
+		"1iqBoWEB",                         // ext(42, {"a":1})
+		"xwz/AAAAAAAAAABfXhAA",             // timestamp96(1600000000)
+		"3gABoWGRAQ==",                     // map16{"a":[1]}
+		base64.StdEncoding.EncodeToString(algoTxn),
+	}
+
+	for _, input := range inputs {
+		decoded, err := base64.StdEncoding.DecodeString(input)
+		if err != nil {
+			t.Fatalf("Could not decode input \"%v\": %v\n", input, err)
+		}
+
+		// append a second object to make sure only the first is read
+		stream := append(append([]byte{}, decoded...), 0xc0)
+		reader := bufio.NewReader(bytes.NewReader(stream))
+
+		raw, err := readObject(reader)
+		if err != nil {
+			t.Fatalf("Unexpected error for input \"%v\": %v\n", input, err)
+		}
+
+		if !bytes.Equal(raw, decoded) {
+			t.Fatalf("Wrong object for input \"%v\": got %v, expected %v\n", input, raw, decoded)
+		}
+
+		raw, err = readObject(reader)
+		if err != nil || !bytes.Equal(raw, []byte{0xc0}) {
+			t.Fatalf("Wrong second object for input \"%v\": got %v, %v\n", input, raw, err)
+		}
+
+		_, err = readObject(reader)
+		if err != io.EOF {
+			t.Fatalf("Expected EOF for input \"%v\", got %v\n", input, err)
+		}
+
+		truncated := bufio.NewReader(bytes.NewReader(decoded[:len(decoded)-1]))
+		if len(decoded) > 1 {
+			_, err = readObject(truncated)
+			if err != io.ErrUnexpectedEOF {
+				t.Fatalf("Expected unexpected EOF for truncated input \"%v\", got %v\n", input, err)
+			}
+		}
+	}
+}
+
+func TestCompareReaders(t *testing.T) {
+	a, _ := GetBinary("gqJpZACkZGF0YQeComlkAaRkYXRhpWhlbGxvgqJpZAKkZGF0YVA=") // {"id":0,"data":7}{"id":1,"data":"hello"}{"id":2,"data":80}
+	b, _ := GetBinary("gqJpZACkZGF0YQeComlkAaRkYXRhpXdvcmxk")                 // {"id":0,"data":7}{"id":1,"data":"world"}
+
+	comparison := CompareReaders(bytes.NewReader(a), bytes.NewReader(b), CompareOptions{})
+
+	var result CompareResult
+	var err error
+
+	expected := []bool{true, false, false}
+	for i, expectedEqual := range expected {
+		result, err = comparison.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error for object %d: %v\n", i, err)
+		}
+
+		if result.Equal != expectedEqual {
+			t.Fatalf("Wrong result for object %d: got %v, expected %v\n", i, result.Equal, expectedEqual)
+		}
+	}
+
+	differences := result.Reporter.Differences
+	if len(differences) != 1 || differences[0].Type != Deletion {
+		t.Fatalf("Extra object was not reported as a deletion: %+v\n", differences)
+	}
+
+	if _, err := comparison.Next(); err != io.EOF {
+		t.Fatalf("Expected EOF, got %v\n", err)
+	}
+
+	if comparison.Equal {
+		t.Fatal("Wrong overall result")
+	}
+
+	if comparison.Index != 3 {
+		t.Fatalf("Wrong number of objects: got %d, expected 3\n", comparison.Index)
+	}
+}
+
+func TestCompareReadersErrors(t *testing.T) {
+	type ErrorTest struct {
+		Name     string
+		First    []byte
+		Second   []byte
+		Expected string
+	}
+
+	tests := []ErrorTest{
+		{
+			Name:     "invalid type",
+			First:    []byte{0xc0, 0x92, 0x01, 0x02},
+			Second:   []byte{0xc0, 0x92, 0x01, 0xc1},
+			Expected: "Failed to read second object: Invalid MessagePack type (offset 0x3, type byte 0xc1)",
+		},
+		{
+			Name:     "truncated",
+			First:    []byte{0xc0, 0x92, 0x01},
+			Second:   []byte{0xc0, 0x92, 0x01, 0x02},
+			Expected: "Failed to read first object: unexpected EOF (offset 0x1, type byte 0x92)",
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			comparison := CompareReaders(bytes.NewReader(test.First), bytes.NewReader(test.Second), CompareOptions{})
+
+			if _, err := comparison.Next(); err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+
+			_, err := comparison.Next()
+			if err == nil || err.Error() != test.Expected {
+				t.Fatalf("Wrong error: got %v, expected %v\n", err, test.Expected)
+			}
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Error is not a *ParseError: %v\n", err)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
@@ -0,0 +1,168 @@
+package msgpackdiff
+
+import (
+	"errors"
+	"math"
+
+	"github.com/algorand/msgp/msgp"
+)
+
+// Tolerance is how much two floating-point numbers may differ while still being equal. Numbers are
+// equal if they are within any of the tolerances that are not zero. NaNs and infinities are only
+// equal to themselves.
+type Tolerance struct {
+	// The largest absolute difference between equal numbers.
+	Absolute float64 `json:"absolute"`
+	// The largest difference between equal numbers relative to the larger of their magnitudes.
+	Relative float64 `json:"relative"`
+	// The largest number of units in the last place between equal numbers, that is, the number of
+	// representable numbers of their type that are between them, plus one.
+	ULPs uint64 `json:"ulps"`
+}
+
+// validate returns an error if the tolerance is negative.
+func (t Tolerance) validate() error {
+	if !(t.Absolute >= 0) || !(t.Relative >= 0) {
+		return errors.New("Tolerance must not be negative")
+	}
+	return nil
+}
+
+// isZero returns true if only identical numbers are equal with the tolerance.
+func (t Tolerance) isZero() bool {
+	return t == Tolerance{}
+}
+
+// usesTolerance returns true if numbers may be equal within a tolerance with options, either the
+// tolerance of options or the tolerance of one of its rules.
+func (options CompareOptions) usesTolerance() bool {
+	if !options.Tolerance.isZero() {
+		return true
+	}
+	for _, rule := range options.Rules {
+		if rule.Tolerance != nil && !rule.Tolerance.isZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutTolerance returns options where only identical numbers are equal, including in the
+// objects that its rules apply to.
+func (options CompareOptions) withoutTolerance() CompareOptions {
+	options.Tolerance = Tolerance{}
+	rules := make([]Rule, len(options.Rules))
+	for i, rule := range options.Rules {
+		rule.Tolerance = nil
+		rules[i] = rule
+	}
+	options.Rules = rules
+	return options
+}
+
+// within returns true if the tolerance is not zero and a and b are equal with it. If single is
+// true, the numbers are single precision, which is the precision that ULPs are counted in.
+func (t Tolerance) within(a float64, b float64, single bool) bool {
+	if t.isZero() {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	if math.IsNaN(a) || math.IsNaN(b) || math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return false
+	}
+
+	diff := math.Abs(a - b)
+	return diff <= t.Absolute ||
+		diff <= t.Relative*math.Max(math.Abs(a), math.Abs(b)) ||
+		(t.ULPs != 0 && ulpDistance(a, b, single) <= t.ULPs)
+}
+
+// ulpDistance returns the number of units in the last place between the finite numbers a and b,
+// counted in single precision if single is true.
+func ulpDistance(a float64, b float64, single bool) uint64 {
+	var orderedA, orderedB int64
+	if single {
+		orderedA = orderedBits(uint64(math.Float32bits(float32(a))), 32)
+		orderedB = orderedBits(uint64(math.Float32bits(float32(b))), 32)
+	} else {
+		orderedA = orderedBits(math.Float64bits(a), 64)
+		orderedB = orderedBits(math.Float64bits(b), 64)
+	}
+	if orderedA < orderedB {
+		orderedA, orderedB = orderedB, orderedA
+	}
+	// the difference fits in a uint64 even if it does not fit in an int64
+	return uint64(orderedA) - uint64(orderedB)
+}
+
+// orderedBits maps the bits of an IEEE 754 number of the given size to an integer, such that
+// consecutive numbers map to consecutive integers and both zeros map to 0.
+func orderedBits(bits uint64, size uint) int64 {
+	sign := uint64(1) << (size - 1)
+	if bits&sign != 0 {
+		return -int64(bits &^ sign)
+	}
+	return int64(bits)
+}
+
+// numberParts returns the real and imaginary parts of a number, and whether it is a
+// floating-point or complex number. If object is not a number, ok is false.
+func numberParts(object MsgpObject) (re float64, im float64, floating bool, ok bool) {
+	ok = true
+	switch object.Type {
+	case msgp.IntType:
+		re = float64(object.Value.(int64))
+	case msgp.UintType:
+		re = float64(object.Value.(uint64))
+	case msgp.Float32Type:
+		re = float64(object.Value.(float32))
+		floating = true
+	case msgp.Float64Type:
+		re = object.Value.(float64)
+		floating = true
+	case msgp.Complex64Type:
+		c := object.Value.(complex64)
+		re, im = float64(real(c)), float64(imag(c))
+		floating = true
+	case msgp.Complex128Type:
+		c := object.Value.(complex128)
+		re, im = real(c), imag(c)
+		floating = true
+	default:
+		ok = false
+	}
+	return
+}
+
+// numbersClose returns true if a and b are numbers, at least one of which is floating-point or
+// complex, whose real and imaginary parts are equal with the tolerance t.
+func numbersClose(a MsgpObject, b MsgpObject, t Tolerance) bool {
+	if t.isZero() {
+		return false
+	}
+	realA, imagA, floatA, okA := numberParts(a)
+	realB, imagB, floatB, okB := numberParts(b)
+	if !okA || !okB || (!floatA && !floatB) {
+		return false
+	}
+	single := isSinglePrecision(a.Type) || isSinglePrecision(b.Type)
+	return t.within(realA, realB, single) && t.within(imagA, imagB, single)
+}
+
+// isSinglePrecision returns true if numbers of type t are made of single precision floats.
+func isSinglePrecision(t msgp.Type) bool {
+	return t == msgp.Float32Type || t == msgp.Complex64Type
+}
+
+// numberDelta returns b minus a if both are numbers and at least one of them is floating-point,
+// so that the difference between them can be shown in reports.
+func numberDelta(a MsgpObject, b MsgpObject) (delta float64, ok bool) {
+	realA, imagA, floatA, okA := numberParts(a)
+	realB, imagB, floatB, okB := numberParts(b)
+	if !okA || !okB || (!floatA && !floatB) || imagA != 0 || imagB != 0 {
+		return 0, false
+	}
+	return realB - realA, true
+}
@@ -0,0 +1,245 @@
+package msgpackdiff
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/algorand/msgp/msgp"
+	"github.com/ttacon/chalk"
+)
+
+func TestToleranceEqual(t *testing.T) {
+	type ToleranceTest struct {
+		Name      string
+		A         float64
+		B         float64
+		Single    bool
+		Tolerance Tolerance
+		Expected  bool
+	}
+
+	tests := []ToleranceTest{
+		{
+			Name:      "identical",
+			A:         1.5,
+			B:         1.5,
+			Tolerance: Tolerance{ULPs: 1},
+			Expected:  true,
+		},
+		{
+			Name:     "identical without tolerance",
+			A:        1.5,
+			B:        1.5,
+			Expected: false,
+		},
+		{
+			Name:     "no tolerance",
+			A:        1.5,
+			B:        math.Nextafter(1.5, 2),
+			Expected: false,
+		},
+		{
+			Name:      "within absolute",
+			A:         1.5,
+			B:         1.5001,
+			Tolerance: Tolerance{Absolute: 0.001},
+			Expected:  true,
+		},
+		{
+			Name:      "outside absolute",
+			A:         1.5,
+			B:         1.502,
+			Tolerance: Tolerance{Absolute: 0.001},
+			Expected:  false,
+		},
+		{
+			Name:      "within relative",
+			A:         1e20,
+			B:         1.0000001e20,
+			Tolerance: Tolerance{Relative: 1e-6},
+			Expected:  true,
+		},
+		{
+			Name:      "outside relative",
+			A:         1e-20,
+			B:         2e-20,
+			Tolerance: Tolerance{Relative: 1e-6},
+			Expected:  false,
+		},
+		{
+			Name:      "within ULPs",
+			A:         0.1,
+			B:         math.Nextafter(math.Nextafter(0.1, 1), 1),
+			Tolerance: Tolerance{ULPs: 2},
+			Expected:  true,
+		},
+		{
+			Name:      "outside ULPs",
+			A:         0.1,
+			B:         math.Nextafter(math.Nextafter(math.Nextafter(0.1, 1), 1), 1),
+			Tolerance: Tolerance{ULPs: 2},
+			Expected:  false,
+		},
+		{
+			Name:      "ULPs across zero",
+			A:         -math.SmallestNonzeroFloat64,
+			B:         math.SmallestNonzeroFloat64,
+			Tolerance: Tolerance{ULPs: 2},
+			Expected:  true,
+		},
+		{
+			Name:      "single precision ULPs",
+			A:         float64(float32(0.1)),
+			B:         float64(math.Nextafter32(float32(0.1), 1)),
+			Single:    true,
+			Tolerance: Tolerance{ULPs: 1},
+			Expected:  true,
+		},
+		{
+			Name:      "ULPs of opposite extremes",
+			A:         -math.MaxFloat64,
+			B:         math.MaxFloat64,
+			Tolerance: Tolerance{ULPs: math.MaxUint64},
+			Expected:  true,
+		},
+		{
+			Name:      "NaN",
+			A:         math.NaN(),
+			B:         math.NaN(),
+			Tolerance: Tolerance{Absolute: 1, ULPs: 1},
+			Expected:  false,
+		},
+		{
+			Name:      "infinity",
+			A:         math.Inf(1),
+			B:         math.MaxFloat64,
+			Tolerance: Tolerance{Relative: 1, ULPs: 1},
+			Expected:  false,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			equal := test.Tolerance.within(test.A, test.B, test.Single)
+			if equal != test.Expected {
+				t.Fatalf("Wrong result: got %v, expected %v\n", equal, test.Expected)
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestCompareTolerance(t *testing.T) {
+	type CompareToleranceTest struct {
+		Name     string
+		A        MsgpObject
+		B        MsgpObject
+		Options  CompareOptions
+		Expected bool
+	}
+
+	tests := []CompareToleranceTest{
+		{
+			Name:     "float32",
+			A:        MsgpObject{Type: msgp.Float32Type, Value: float32(1.5)},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: math.Nextafter32(1.5, 2)},
+			Options:  CompareOptions{Tolerance: Tolerance{ULPs: 1}},
+			Expected: true,
+		},
+		{
+			Name:     "float64",
+			A:        MsgpObject{Type: msgp.Float64Type, Value: 1.5},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: 1.5001},
+			Options:  CompareOptions{Tolerance: Tolerance{Absolute: 0.001}},
+			Expected: true,
+		},
+		{
+			Name:     "complex128",
+			A:        MsgpObject{Type: msgp.Complex128Type, Value: complex(1.5, 2)},
+			B:        MsgpObject{Type: msgp.Complex128Type, Value: complex(1.5001, 2.0001)},
+			Options:  CompareOptions{Tolerance: Tolerance{Absolute: 0.001}},
+			Expected: true,
+		},
+		{
+			Name:     "integers",
+			A:        MsgpObject{Type: msgp.UintType, Value: uint64(1000)},
+			B:        MsgpObject{Type: msgp.UintType, Value: uint64(1001)},
+			Options:  CompareOptions{Tolerance: Tolerance{Relative: 0.1}},
+			Expected: false,
+		},
+		{
+			Name:     "different types",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(2)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: 2.0001},
+			Options:  CompareOptions{Tolerance: Tolerance{Absolute: 0.001}},
+			Expected: false,
+		},
+		{
+			Name:     "flexible types",
+			A:        MsgpObject{Type: msgp.IntType, Value: int64(2)},
+			B:        MsgpObject{Type: msgp.Float64Type, Value: 2.0001},
+			Options:  CompareOptions{FlexibleTypes: true, Tolerance: Tolerance{Absolute: 0.001}},
+			Expected: true,
+		},
+		{
+			Name:     "flexible float32 ULPs",
+			A:        MsgpObject{Type: msgp.Float64Type, Value: 0.1},
+			B:        MsgpObject{Type: msgp.Float32Type, Value: math.Nextafter32(float32(0.1), 1)},
+			Options:  CompareOptions{FlexibleTypes: true, Tolerance: Tolerance{ULPs: 1}},
+			Expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		runTest := func(t *testing.T) {
+			for _, brief := range []bool{false, true} {
+				options := test.Options
+				options.Brief = brief
+				result := CompareParsed([]MsgpObject{test.A}, []MsgpObject{test.B}, options)
+				if result.Equal != test.Expected {
+					t.Fatalf("Wrong result with brief %v: got %v, expected %v\n", brief, result.Equal, test.Expected)
+				}
+
+				// elements of arrays are matched with lcsObjects
+				array := func(object MsgpObject) MsgpObject {
+					return MsgpObject{Type: msgp.ArrayType, Value: []MsgpObject{object}}
+				}
+				result = CompareParsed([]MsgpObject{array(test.A)}, []MsgpObject{array(test.B)}, options)
+				if result.Equal != test.Expected {
+					t.Fatalf("Wrong result in array with brief %v: got %v, expected %v\n", brief, result.Equal, test.Expected)
+				}
+			}
+		}
+		t.Run(test.Name, runTest)
+	}
+}
+
+func TestDeltaReport(t *testing.T) {
+	a, _ := ParseJSON([]byte(`{"fee": 1000, "rate": 1.5, "name": "a"}`), ParseOptions{})
+	b, _ := ParseJSON([]byte(`{"fee": 1001, "rate": 1.25, "name": "b"}`), ParseOptions{})
+
+	result := CompareParsed(a, b, CompareOptions{})
+
+	var builder strings.Builder
+	result.PrintReport(&builder, 3)
+
+	red := chalk.Red.String()
+	green := chalk.Green.String()
+	reset := chalk.ResetColor.String()
+	expected := fmt.Sprintf(` {
+%s-  "fee": 1000,%s
+%s+  "fee": 1001,%s
+%s-  "rate": 1.5,%s
+%s+  "rate": 1.25, (delta -0.25)%s
+%s-  "name": "a"%s
+%s+  "name": "b"%s
+ }
+`, red, reset, green, reset, red, reset, green, reset, red, reset, green, reset)
+	actual := builder.String()
+
+	if expected != actual {
+		t.Fatalf("Invalid report:\nExpected:\n%s\nGot:\n%s\n", expected, actual)
+	}
+}